@@ -36,6 +36,23 @@ type TaskManagerClient interface {
 	// FetchLogs returns the data written to stdout and stderr by the task. The
 	// log data from each stream is treated as an opaque series of bytes
 	FetchLogs(ctx context.Context, in *FetchLogsRequest, opts ...grpc.CallOption) (*FetchLogsResponse, error)
+	// FollowLogs streams the data written to stdout and stderr by the task,
+	// starting from the beginning, as it is written. Each message holds only
+	// the data written since the previous one. The stream ends once the task
+	// has exited and all of its output has been sent. Requires the same
+	// permission as FetchLogs.
+	FollowLogs(ctx context.Context, in *FollowLogsRequest, opts ...grpc.CallOption) (TaskManager_FollowLogsClient, error)
+	// ShareTask grants other users, or groups of users, permission to
+	// perform the listed actions on a task. Grants are cumulative; sharing
+	// a task again adds to the existing grants rather than replacing them.
+	// By default only the task owner may share a task.
+	ShareTask(ctx context.Context, in *ShareTaskRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	// QueryTasks fetches the current state of every task matching the
+	// selector, in the same way as QueryTask
+	QueryTasks(ctx context.Context, in *QueryTasksRequest, opts ...grpc.CallOption) (*QueryTasksResponse, error)
+	// SignalTasks requests that every task matching the selector exit, in
+	// the same way as SignalTask
+	SignalTasks(ctx context.Context, in *SignalTasksRequest, opts ...grpc.CallOption) (*SignalTasksResponse, error)
 }
 
 type taskManagerClient struct {
@@ -82,6 +99,65 @@ func (c *taskManagerClient) FetchLogs(ctx context.Context, in *FetchLogsRequest,
 	return out, nil
 }
 
+func (c *taskManagerClient) FollowLogs(ctx context.Context, in *FollowLogsRequest, opts ...grpc.CallOption) (TaskManager_FollowLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TaskManager_serviceDesc.Streams[0], "/levity.TaskManager/FollowLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &taskManagerFollowLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TaskManager_FollowLogsClient interface {
+	Recv() (*FetchLogsResponse, error)
+	grpc.ClientStream
+}
+
+type taskManagerFollowLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *taskManagerFollowLogsClient) Recv() (*FetchLogsResponse, error) {
+	m := new(FetchLogsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *taskManagerClient) ShareTask(ctx context.Context, in *ShareTaskRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/levity.TaskManager/ShareTask", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskManagerClient) QueryTasks(ctx context.Context, in *QueryTasksRequest, opts ...grpc.CallOption) (*QueryTasksResponse, error) {
+	out := new(QueryTasksResponse)
+	err := c.cc.Invoke(ctx, "/levity.TaskManager/QueryTasks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskManagerClient) SignalTasks(ctx context.Context, in *SignalTasksRequest, opts ...grpc.CallOption) (*SignalTasksResponse, error) {
+	out := new(SignalTasksResponse)
+	err := c.cc.Invoke(ctx, "/levity.TaskManager/SignalTasks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TaskManagerServer is the server API for TaskManager service.
 // All implementations must embed UnimplementedTaskManagerServer
 // for forward compatibility
@@ -104,6 +180,23 @@ type TaskManagerServer interface {
 	// FetchLogs returns the data written to stdout and stderr by the task. The
 	// log data from each stream is treated as an opaque series of bytes
 	FetchLogs(context.Context, *FetchLogsRequest) (*FetchLogsResponse, error)
+	// FollowLogs streams the data written to stdout and stderr by the task,
+	// starting from the beginning, as it is written. Each message holds only
+	// the data written since the previous one. The stream ends once the task
+	// has exited and all of its output has been sent. Requires the same
+	// permission as FetchLogs.
+	FollowLogs(*FollowLogsRequest, TaskManager_FollowLogsServer) error
+	// ShareTask grants other users, or groups of users, permission to
+	// perform the listed actions on a task. Grants are cumulative; sharing
+	// a task again adds to the existing grants rather than replacing them.
+	// By default only the task owner may share a task.
+	ShareTask(context.Context, *ShareTaskRequest) (*empty.Empty, error)
+	// QueryTasks fetches the current state of every task matching the
+	// selector, in the same way as QueryTask
+	QueryTasks(context.Context, *QueryTasksRequest) (*QueryTasksResponse, error)
+	// SignalTasks requests that every task matching the selector exit, in
+	// the same way as SignalTask
+	SignalTasks(context.Context, *SignalTasksRequest) (*SignalTasksResponse, error)
 	mustEmbedUnimplementedTaskManagerServer()
 }
 
@@ -123,6 +216,18 @@ func (UnimplementedTaskManagerServer) SignalTask(context.Context, *SignalTaskReq
 func (UnimplementedTaskManagerServer) FetchLogs(context.Context, *FetchLogsRequest) (*FetchLogsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FetchLogs not implemented")
 }
+func (UnimplementedTaskManagerServer) FollowLogs(*FollowLogsRequest, TaskManager_FollowLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method FollowLogs not implemented")
+}
+func (UnimplementedTaskManagerServer) ShareTask(context.Context, *ShareTaskRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShareTask not implemented")
+}
+func (UnimplementedTaskManagerServer) QueryTasks(context.Context, *QueryTasksRequest) (*QueryTasksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryTasks not implemented")
+}
+func (UnimplementedTaskManagerServer) SignalTasks(context.Context, *SignalTasksRequest) (*SignalTasksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignalTasks not implemented")
+}
 func (UnimplementedTaskManagerServer) mustEmbedUnimplementedTaskManagerServer() {}
 
 // UnsafeTaskManagerServer may be embedded to opt out of forward compatibility for this service.
@@ -208,6 +313,81 @@ func _TaskManager_FetchLogs_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TaskManager_FollowLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FollowLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TaskManagerServer).FollowLogs(m, &taskManagerFollowLogsServer{stream})
+}
+
+type TaskManager_FollowLogsServer interface {
+	Send(*FetchLogsResponse) error
+	grpc.ServerStream
+}
+
+type taskManagerFollowLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *taskManagerFollowLogsServer) Send(m *FetchLogsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TaskManager_ShareTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShareTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskManagerServer).ShareTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/levity.TaskManager/ShareTask",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskManagerServer).ShareTask(ctx, req.(*ShareTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskManager_QueryTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryTasksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskManagerServer).QueryTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/levity.TaskManager/QueryTasks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskManagerServer).QueryTasks(ctx, req.(*QueryTasksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskManager_SignalTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignalTasksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskManagerServer).SignalTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/levity.TaskManager/SignalTasks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskManagerServer).SignalTasks(ctx, req.(*SignalTasksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _TaskManager_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "levity.TaskManager",
 	HandlerType: (*TaskManagerServer)(nil),
@@ -228,7 +408,25 @@ var _TaskManager_serviceDesc = grpc.ServiceDesc{
 			MethodName: "FetchLogs",
 			Handler:    _TaskManager_FetchLogs_Handler,
 		},
+		{
+			MethodName: "ShareTask",
+			Handler:    _TaskManager_ShareTask_Handler,
+		},
+		{
+			MethodName: "QueryTasks",
+			Handler:    _TaskManager_QueryTasks_Handler,
+		},
+		{
+			MethodName: "SignalTasks",
+			Handler:    _TaskManager_SignalTasks_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "FollowLogs",
+			Handler:       _TaskManager_FollowLogs_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/levity.proto",
 }
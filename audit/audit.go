@@ -0,0 +1,395 @@
+// Package audit records every request made to the levity daemon: who made
+// it, from where, what they asked for and what the outcome was.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/syslog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/authn"
+	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Record describes a single API request. Records are written as JSON, one
+// per line.
+type Record struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Action string    `json:"action,omitempty"`
+
+	// Who made the request, and from where. The user is empty if the request
+	// could not be authenticated, and the certificate fingerprint is empty
+	// if the client did not present a certificate (e.g. if they
+	// authenticated with a bearer token instead).
+	User        string   `json:"user,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Certificate string   `json:"certificate_sha256,omitempty"`
+	Peer        string   `json:"peer,omitempty"`
+
+	// What they asked for. The command details are only recorded for
+	// requests to start a task, and the selector only for requests that
+	// select several tasks at once.
+	TaskID     string    `json:"task_id,omitempty"`
+	Selector   *Selector `json:"selector,omitempty"`
+	Binary     string    `json:"binary,omitempty"`
+	Args       []string  `json:"args,omitempty"`
+	WorkingDir string    `json:"working_dir,omitempty"`
+
+	// The outcome. Requests that select several tasks at once record the
+	// tasks they acted on in TaskIDs, and those they couldn't act on (e.g.
+	// because they don't exist, or belong to someone else) in Failures.
+	Code       string        `json:"code"`
+	Error      string        `json:"error,omitempty"`
+	TaskIDs    []string      `json:"task_ids,omitempty"`
+	Failures   []TaskFailure `json:"failures,omitempty"`
+	DurationMS float64       `json:"duration_ms"`
+}
+
+// Selector records how a batch request selected its tasks
+type Selector struct {
+	TaskIDs  []string          `json:"task_ids,omitempty"`
+	All      bool              `json:"all,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Statuses []string          `json:"statuses,omitempty"`
+}
+
+// TaskFailure records why a batch request couldn't act on one of the tasks
+// it selected
+type TaskFailure struct {
+	TaskID  string `json:"task_id"`
+	Code    string `json:"code"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Sink is somewhere to write audit records to
+type Sink interface {
+	Write(record *Record) error
+}
+
+// FileSink appends audit records to a file
+type FileSink struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+// OpenFile opens (or creates) an audit log file for appending. The file is
+// only readable by its owner.
+func OpenFile(filename string) (*FileSink, error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+// Write implements Sink for a FileSink
+func (s *FileSink) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// SyslogSink sends audit records to the local syslog daemon
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+// OpenSyslog connects to the local syslog daemon. Records are logged to the
+// `authpriv` facility, which is normally only readable by privileged users.
+func OpenSyslog() (*SyslogSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, path.Base(os.Args[0]))
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer: w}, nil
+}
+
+// Write implements Sink for a SyslogSink
+func (s *SyslogSink) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.writer.Info(string(line))
+}
+
+// actions maps TaskManager methods onto the actions they perform
+var actions = map[string]task.Action{
+	"StartTask":   task.ActionStart,
+	"QueryTask":   task.ActionQuery,
+	"QueryTasks":  task.ActionQuery,
+	"FetchLogs":   task.ActionLogs,
+	"FollowLogs":  task.ActionLogs,
+	"SignalTask":  task.ActionSignal,
+	"SignalTasks": task.ActionSignal,
+	"ShareTask":   task.ActionShare,
+}
+
+type recordKey struct{}
+
+// newRecord starts an audit record for a request, filling in everything
+// that can be known about the caller before the request is handled
+func newRecord(ctx context.Context, method string) *Record {
+	r := &Record{
+		Time:   time.Now().UTC(),
+		Method: method,
+		Action: string(actions[path.Base(method)]),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			r.Peer = p.Addr.String()
+		}
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+			r.Certificate = authn.Fingerprint(tlsInfo.State.PeerCertificates[0])
+		}
+	}
+
+	return r
+}
+
+// describeRequest records the details of what the caller asked for
+func (r *Record) describeRequest(req interface{}) {
+	if withTask, ok := req.(interface{ GetTaskId() *api.TaskHandle }); ok {
+		r.TaskID = withTask.GetTaskId().GetId()
+	}
+
+	if batch, ok := req.(interface{ GetSelector() *api.TaskSelector }); ok {
+		r.Selector = newSelector(batch.GetSelector())
+	}
+
+	if start, ok := req.(*api.StartTaskRequest); ok {
+		// NB: The environment is deliberately left out, as it's a common
+		//     way of passing secrets to a process
+		r.Binary = start.GetBinary()
+		r.Args = start.GetArgs()
+		r.WorkingDir = start.GetWorkingDir()
+	}
+}
+
+// finish records the outcome of a request
+func (r *Record) finish(resp interface{}, err error) {
+	// The ID of a newly-started task is only known once it has started
+	if withTask, ok := resp.(interface{ GetTaskId() *api.TaskHandle }); ok && r.TaskID == "" {
+		r.TaskID = withTask.GetTaskId().GetId()
+	}
+
+	switch batch := resp.(type) {
+	case *api.QueryTasksResponse:
+		for _, t := range batch.GetTasks() {
+			r.recordTask(t.GetTaskId().GetId(), t.GetError())
+		}
+
+	case *api.SignalTasksResponse:
+		for _, t := range batch.GetTasks() {
+			r.recordTask(t.GetTaskId().GetId(), t.GetError())
+		}
+	}
+
+	st := status.Convert(err)
+	r.Code = st.Code().String()
+	if err != nil {
+		r.Error = st.Message()
+	}
+	r.DurationMS = float64(time.Since(r.Time)) / float64(time.Millisecond)
+}
+
+// recordTask records the outcome of a batch request for a single task
+func (r *Record) recordTask(id string, err *api.TaskError) {
+	if err == nil {
+		r.TaskIDs = append(r.TaskIDs, id)
+		return
+	}
+
+	r.Failures = append(r.Failures, TaskFailure{
+		TaskID:  id,
+		Code:    codes.Code(err.GetCode()).String(),
+		Reason:  err.GetReason(),
+		Message: err.GetMessage(),
+	})
+}
+
+func newSelector(sel *api.TaskSelector) *Selector {
+	if sel == nil {
+		return nil
+	}
+
+	result := &Selector{
+		All:    sel.GetAll(),
+		Labels: sel.GetLabels(),
+	}
+	for _, handle := range sel.GetTaskIds() {
+		result.TaskIDs = append(result.TaskIDs, handle.GetId())
+	}
+	for _, status := range sel.GetStatuses() {
+		result.Statuses = append(result.Statuses, status.String())
+	}
+	return result
+}
+
+// Logger writes an audit record for every request it intercepts
+type Logger struct {
+	sink Sink
+}
+
+// New creates a Logger that writes its records to the given sink
+func New(sink Sink) *Logger {
+	return &Logger{sink: sink}
+}
+
+func (l *Logger) write(r *Record) {
+	if err := l.sink.Write(r); err != nil {
+		// Not much else we can do; the request has already been handled
+		log.Printf("Failed to write audit record for %s request by %q: %v", r.Method, r.User, err)
+	}
+}
+
+// isIgnored determines whether a method belongs to one of the services
+// that aren't audited
+func isIgnored(method string, ignoredServices []string) bool {
+	for _, service := range ignoredServices {
+		if strings.HasPrefix(method, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor audits every unary request, apart from those to any
+// of the named services (e.g. the health checking service, which is polled
+// too frequently to be worth auditing). It must be installed ahead of the
+// authentication interceptor, so that rejected requests are audited as well,
+// and be paired with `UserInterceptor` to record who made the request.
+func (l *Logger) UnaryServerInterceptor(ignoredServices ...string) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if isIgnored(info.FullMethod, ignoredServices) {
+			return handler(ctx, req)
+		}
+
+		r := newRecord(ctx, info.FullMethod)
+		r.describeRequest(req)
+
+		resp, err := handler(context.WithValue(ctx, recordKey{}, r), req)
+
+		r.finish(resp, err)
+		l.write(r)
+		return resp, err
+	}
+}
+
+// recordedStream wraps a server stream, replacing its context with one
+// carrying the audit record
+type recordedStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	record    *Record
+	described bool
+}
+
+func (s *recordedStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg records the details of the request from the first message the
+// client sends
+func (s *recordedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && !s.described {
+		s.record.describeRequest(m)
+		s.described = true
+	}
+	return err
+}
+
+// StreamServerInterceptor audits every streaming request, in the same way as
+// `UnaryServerInterceptor`. Only the first message received from the client
+// is examined for the details of the request.
+func (l *Logger) StreamServerInterceptor(ignoredServices ...string) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		if isIgnored(info.FullMethod, ignoredServices) {
+			return handler(srv, stream)
+		}
+
+		r := newRecord(stream.Context(), info.FullMethod)
+		ctx := context.WithValue(stream.Context(), recordKey{}, r)
+
+		err := handler(srv, &recordedStream{ServerStream: stream, ctx: ctx, record: r})
+
+		r.finish(nil, err)
+		l.write(r)
+		return err
+	}
+}
+
+// recordUser adds the authenticated user to the request's audit record, if
+// it has one
+func recordUser(ctx context.Context) {
+	r, ok := ctx.Value(recordKey{}).(*Record)
+	if !ok {
+		return
+	}
+
+	if u, ok := user.FromContext(ctx); ok {
+		r.User = u.Login()
+		r.Groups = u.Groups()
+		r.Roles = u.Roles()
+	}
+}
+
+// UserInterceptor records the authenticated user in the audit record for a
+// unary request. It must be installed *after* the authentication
+// interceptor.
+func UserInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+
+	recordUser(ctx)
+	return handler(ctx, req)
+}
+
+// StreamUserInterceptor records the authenticated user in the audit record
+// for a streaming request. It must be installed *after* the authentication
+// interceptor.
+func StreamUserInterceptor(
+	srv interface{},
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+
+	recordUser(stream.Context())
+	return handler(srv, stream)
+}
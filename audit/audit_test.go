@@ -0,0 +1,215 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type memorySink struct {
+	records []*Record
+}
+
+func (s *memorySink) Write(r *Record) error {
+	s.records = append(s.records, r)
+	return nil
+}
+
+// intercept runs a request through the audit interceptors in the same order
+// as the daemon, with a fake authentication step in the middle that
+// authenticates the given user (or rejects the request if nil)
+func intercept(
+	l *Logger, u *user.User, method string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234},
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+
+	authenticate := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if u == nil {
+			return nil, status.Error(codes.Unauthenticated, "Who are you?")
+		}
+		return UserInterceptor(user.NewContext(ctx, u), req, info, handler)
+	}
+
+	return l.UnaryServerInterceptor()(ctx, req, info, authenticate)
+}
+
+func TestLogger_StartTask(t *testing.T) {
+	require := require.New(t)
+	sink := &memorySink{}
+	uut := New(sink)
+	alice := user.NewWithClaims("alice", []string{"builders"}, nil)
+
+	// When a request to start a task is audited
+	req := &api.StartTaskRequest{
+		Binary:      "make",
+		Args:        []string{"-j4", "test"},
+		Environment: map[string]string{"SECRET": "hunter2"},
+	}
+	_, err := intercept(uut, alice, "/levity.TaskManager/StartTask", req,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &api.StartTaskResponse{TaskId: &api.TaskHandle{Id: "1234"}}, nil
+		})
+	require.NoError(err)
+
+	// Expect that the record describes who started what, from where
+	require.Len(sink.records, 1)
+	r := sink.records[0]
+	require.Equal("/levity.TaskManager/StartTask", r.Method)
+	require.Equal("start", r.Action)
+	require.Equal("alice", r.User)
+	require.Equal([]string{"builders"}, r.Groups)
+	require.Equal("192.0.2.1:1234", r.Peer)
+	require.Equal("make", r.Binary)
+	require.Equal([]string{"-j4", "test"}, r.Args)
+	require.Equal("1234", r.TaskID)
+	require.Equal("OK", r.Code)
+	require.Empty(r.Error)
+
+	// ... and that the environment, which may contain secrets, is not
+	// recorded
+	line, err := json.Marshal(r)
+	require.NoError(err)
+	require.NotContains(string(line), "hunter2")
+}
+
+func TestLogger_Failures(t *testing.T) {
+	require := require.New(t)
+	sink := &memorySink{}
+	uut := New(sink)
+	req := &api.QueryTaskRequest{TaskId: &api.TaskHandle{Id: "1234"}}
+
+	// When a request is rejected by the handler
+	_, err := intercept(uut, user.New("bob"), "/levity.TaskManager/QueryTask", req,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.PermissionDenied, "Access denied")
+		})
+	require.Error(err)
+
+	// ... or fails authentication
+	_, err = intercept(uut, nil, "/levity.TaskManager/QueryTask", req,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			require.FailNow("Handler should not be called")
+			return nil, nil
+		})
+	require.Error(err)
+
+	// Expect that both are audited, with the outcome
+	require.Len(sink.records, 2)
+	require.Equal("bob", sink.records[0].User)
+	require.Equal("1234", sink.records[0].TaskID)
+	require.Equal("PermissionDenied", sink.records[0].Code)
+	require.Equal("Access denied", sink.records[0].Error)
+
+	require.Empty(sink.records[1].User)
+	require.Equal("1234", sink.records[1].TaskID)
+	require.Equal("Unauthenticated", sink.records[1].Code)
+}
+
+func TestLogger_SignalTasks(t *testing.T) {
+	require := require.New(t)
+	sink := &memorySink{}
+	uut := New(sink)
+
+	// When a request to signal several tasks at once is audited, where one
+	// of them couldn't be signalled
+	req := &api.SignalTasksRequest{Selector: &api.TaskSelector{
+		TaskIds:  []*api.TaskHandle{{Id: "1234"}, {Id: "5678"}, {Id: "9abc"}},
+		Labels:   map[string]string{"job": "build"},
+		Statuses: []api.TaskStatusCode{api.TaskStatusCode_Running},
+	}}
+	_, err := intercept(uut, user.New("alice"), "/levity.TaskManager/SignalTasks", req,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &api.SignalTasksResponse{Tasks: []*api.SignalResult{
+				{TaskId: &api.TaskHandle{Id: "1234"}},
+				{TaskId: &api.TaskHandle{Id: "5678"}, Error: &api.TaskError{
+					Code:    int32(codes.PermissionDenied),
+					Message: "Access denied",
+					Reason:  "ACCESS_DENIED",
+				}},
+				{TaskId: &api.TaskHandle{Id: "9abc"}},
+			}}, nil
+		})
+	require.NoError(err)
+
+	// Expect that the record describes how the tasks were selected
+	require.Len(sink.records, 1)
+	r := sink.records[0]
+	require.Equal("signal", r.Action)
+	require.Empty(r.TaskID)
+	require.Equal(&Selector{
+		TaskIDs:  []string{"1234", "5678", "9abc"},
+		Labels:   map[string]string{"job": "build"},
+		Statuses: []string{"Running"},
+	}, r.Selector)
+
+	// ... which tasks were signalled, and which weren't, and why
+	require.Equal([]string{"1234", "9abc"}, r.TaskIDs)
+	require.Equal([]TaskFailure{{
+		TaskID:  "5678",
+		Code:    "PermissionDenied",
+		Reason:  "ACCESS_DENIED",
+		Message: "Access denied",
+	}}, r.Failures)
+}
+
+func TestLogger_IgnoredServices(t *testing.T) {
+	require := require.New(t)
+	sink := &memorySink{}
+	uut := New(sink).UnaryServerInterceptor("grpc.health.v1.Health")
+
+	// When a request to an ignored service is handled
+	called := false
+	_, err := uut(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		})
+
+	// Expect that it is handled, but not audited
+	require.NoError(err)
+	require.True(called)
+	require.Empty(sink.records)
+}
+
+func TestFileSink(t *testing.T) {
+	require := require.New(t)
+	filename := path.Join(t.TempDir(), "audit.log")
+
+	uut, err := OpenFile(filename)
+	require.NoError(err)
+	require.NoError(uut.Write(&Record{Method: "one", Code: "OK"}))
+	require.NoError(uut.Write(&Record{Method: "two", Code: "NotFound"}))
+
+	info, err := os.Stat(filename)
+	require.NoError(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	f, err := os.Open(filename)
+	require.NoError(err)
+	defer f.Close()
+
+	var methods []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		require.NoError(json.Unmarshal(scanner.Bytes(), &r))
+		methods = append(methods, r.Method)
+	}
+	require.Equal([]string{"one", "two"}, methods)
+}
@@ -0,0 +1,77 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ErrNoCredentials indicates that a request did not carry the kind of
+// credentials an Authenticator understands, so the next authenticator in a
+// chain should be tried.
+var ErrNoCredentials = errors.New("No credentials supplied")
+
+// Unauthenticated is an error type indicating that a request carried
+// credentials, but they were not acceptable.
+type Unauthenticated struct {
+	reason string
+}
+
+func (e *Unauthenticated) Error() string {
+	return fmt.Sprintf("Authentication failed: %s", e.reason)
+}
+
+// Authenticator identifies the user making a request, from the credentials
+// carried in the request context.
+type Authenticator interface {
+	// Authenticate returns the user making the request, ErrNoCredentials if
+	// the request carried no credentials of the kind the Authenticator
+	// handles, or some other error if the credentials were rejected.
+	Authenticate(ctx context.Context) (*user.User, error)
+}
+
+// Chain tries a series of Authenticators in order, using the first one that
+// finds credentials it understands. A request with credentials that are
+// rejected is *not* passed on to the rest of the chain.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator for a Chain
+func (c Chain) Authenticate(ctx context.Context) (*user.User, error) {
+	for _, authenticator := range c {
+		u, err := authenticator.Authenticate(ctx)
+		if err == ErrNoCredentials {
+			continue
+		}
+		return u, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// Authenticate identifies the user from the client certificate presented
+// when the connection was established. Note that the certificate has
+// already been verified by the TLS stack by the time we get here, but it is
+// checked for revocation again in case it has been revoked since.
+func (m *CertificateMapper) Authenticate(ctx context.Context) (*user.User, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	cert := tlsInfo.State.PeerCertificates[0]
+	if m.Revocations != nil {
+		if err := m.Revocations.Check(cert); err != nil {
+			return nil, &Unauthenticated{reason: err.Error()}
+		}
+	}
+
+	return m.User(cert), nil
+}
@@ -0,0 +1,85 @@
+package authn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func withPeerCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	state := tls.ConnectionState{}
+	if cert != nil {
+		state.PeerCertificates = []*x509.Certificate{cert}
+	}
+	return peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+func TestChain(t *testing.T) {
+	require := require.New(t)
+	signer := newEd25519Signer(t, "ed")
+	cert := makeCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}})
+
+	// Given a chain that accepts either client certificates or tokens
+	uut := Chain{&CertificateMapper{}, newTokenAuthenticator(t, signer)}
+
+	// Expect that a client certificate is used if presented
+	u, err := uut.Authenticate(withPeerCertificate(context.Background(), cert))
+	require.NoError(err)
+	require.Equal("alice", u.Login())
+
+	// ... and that a token is used if not
+	ctx := metadata.NewIncomingContext(
+		withPeerCertificate(context.Background(), nil),
+		metadata.Pairs(AuthorizationHeader, "Bearer "+signer.sign(t, validClaims())))
+	u, err = uut.Authenticate(ctx)
+	require.NoError(err)
+	require.Equal("ci-runner", u.Login())
+
+	// ... that an invalid token is rejected outright
+	ctx = metadata.NewIncomingContext(
+		withPeerCertificate(context.Background(), nil),
+		metadata.Pairs(AuthorizationHeader, "Bearer not-a-token"))
+	_, err = uut.Authenticate(ctx)
+	require.IsType(&Unauthenticated{}, err)
+
+	// ... and that a request with no credentials at all is rejected
+	_, err = uut.Authenticate(withPeerCertificate(context.Background(), nil))
+	require.Equal(ErrNoCredentials, err)
+}
+
+func TestCertificateMapper_RevokedAfterConnecting(t *testing.T) {
+	require := require.New(t)
+	ca := newTestCA(t, "ClientCA")
+	cert := ca.issue(t, "chuck", 7)
+
+	// Given a certificate mapper that checks an (empty) deny-list
+	denyListPath := filepath.Join(t.TempDir(), "deny-list")
+	require.NoError(ioutil.WriteFile(denyListPath, nil, 0600))
+	revocations, err := NewRevocations(nil, nil, denyListPath)
+	require.NoError(err)
+	uut := &CertificateMapper{Revocations: revocations}
+
+	// ... and a connection with a certificate that was good when it was
+	// established
+	ctx := withPeerCertificate(context.Background(), cert)
+	u, err := uut.Authenticate(ctx)
+	require.NoError(err)
+	require.Equal("chuck", u.Login())
+
+	// When the certificate is deny-listed
+	require.NoError(ioutil.WriteFile(denyListPath, []byte("serial:07\n"), 0600))
+	require.NoError(revocations.Reload())
+
+	// Expect that further requests on the same connection are rejected
+	_, err = uut.Authenticate(ctx)
+	require.IsType(&Unauthenticated{}, err)
+}
@@ -0,0 +1,158 @@
+// Package authn maps the credentials presented by a client onto a levity
+// user.
+package authn
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tcsc/levity/user"
+)
+
+// ClaimSource extracts a list of claims (e.g. group names) from a client
+// certificate.
+type ClaimSource interface {
+	Claims(cert *x509.Certificate) []string
+}
+
+type organisationalUnits struct{}
+
+func (organisationalUnits) Claims(cert *x509.Certificate) []string {
+	return cert.Subject.OrganizationalUnit
+}
+
+type organisations struct{}
+
+func (organisations) Claims(cert *x509.Certificate) []string {
+	return cert.Subject.Organization
+}
+
+// uriClaims extracts claims from the certificate's subject alternative name
+// URIs that start with a given prefix. The claim is the remainder of the URI
+// after the prefix, e.g. with the prefix `levity://group/`, the URI
+// `levity://group/oncall` yields the claim `oncall`.
+type uriClaims struct {
+	prefix string
+}
+
+func (s uriClaims) Claims(cert *x509.Certificate) []string {
+	var result []string
+	for _, uri := range cert.URIs {
+		text := uri.String()
+		if strings.HasPrefix(text, s.prefix) && len(text) > len(s.prefix) {
+			result = append(result, text[len(s.prefix):])
+		}
+	}
+	return result
+}
+
+// extensionClaims extracts claims from a custom certificate extension. The
+// extension value may either be a single ASN.1 string, or a SEQUENCE of them.
+type extensionClaims struct {
+	oid asn1.ObjectIdentifier
+}
+
+func (s extensionClaims) Claims(cert *x509.Certificate) []string {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(s.oid) {
+			continue
+		}
+
+		var values []string
+		if rest, err := asn1.Unmarshal(ext.Value, &values); err == nil && len(rest) == 0 {
+			return values
+		}
+
+		var value string
+		if rest, err := asn1.Unmarshal(ext.Value, &value); err == nil && len(rest) == 0 {
+			return []string{value}
+		}
+	}
+	return nil
+}
+
+// ParseClaimSource creates a ClaimSource from a textual description, which
+// is one of:
+//   - `ou`: the subject's organisational units,
+//   - `o`: the subject's organisations,
+//   - `uri:PREFIX`: subject alternative name URIs starting with PREFIX, or
+//   - `oid:OID`: a custom extension, identified by a dotted-decimal OID.
+func ParseClaimSource(spec string) (ClaimSource, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	switch {
+	case spec == "ou":
+		return organisationalUnits{}, nil
+
+	case spec == "o":
+		return organisations{}, nil
+
+	case len(parts) == 2 && parts[0] == "uri" && parts[1] != "":
+		return uriClaims{prefix: parts[1]}, nil
+
+	case len(parts) == 2 && parts[0] == "oid":
+		oid, err := parseOID(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return extensionClaims{oid: oid}, nil
+	}
+
+	return nil, fmt.Errorf("invalid claim source %q", spec)
+}
+
+func parseOID(text string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	for _, part := range strings.Split(text, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID %q", text)
+		}
+		oid = append(oid, n)
+	}
+
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", text)
+	}
+
+	return oid, nil
+}
+
+// CertificateMapper maps a client certificate onto a levity user. The login
+// name is taken from the certificate's subject common name, and group and
+// role claims from the configured sources.
+type CertificateMapper struct {
+	Groups []ClaimSource
+	Roles  []ClaimSource
+
+	// Revocations, if set, is checked on every request, so that a
+	// certificate revoked after the connection was established is rejected
+	// as soon as the revocation data is reloaded
+	Revocations *Revocations
+}
+
+// User creates a user record from a client certificate.
+func (m *CertificateMapper) User(cert *x509.Certificate) *user.User {
+	return user.NewWithClaims(
+		cert.Subject.CommonName,
+		collect(m.Groups, cert),
+		collect(m.Roles, cert))
+}
+
+// collect gathers the unique claims from all the sources
+func collect(sources []ClaimSource, cert *x509.Certificate) []string {
+	seen := make(map[string]struct{})
+	var result []string
+	for _, source := range sources {
+		for _, claim := range source.Claims(cert) {
+			if _, ok := seen[claim]; ok || claim == "" {
+				continue
+			}
+			seen[claim] = struct{}{}
+			result = append(result, claim)
+		}
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package authn
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+
+// makeCertificate creates a self-signed certificate from the template, and
+// parses it back in again as a TLS server would
+func makeCertificate(t *testing.T, template *x509.Certificate) *x509.Certificate {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(1)
+	template.NotBefore = time.Now().Add(-1 * time.Hour)
+	template.NotAfter = time.Now().Add(1 * time.Hour)
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func mustParseURL(t *testing.T, text string) *url.URL {
+	u, err := url.Parse(text)
+	require.NoError(t, err)
+	return u
+}
+
+func mustMarshal(t *testing.T, value interface{}) []byte {
+	data, err := asn1.Marshal(value)
+	require.NoError(t, err)
+	return data
+}
+
+func mustParseClaimSource(t *testing.T, spec string) ClaimSource {
+	source, err := ParseClaimSource(spec)
+	require.NoError(t, err)
+	return source
+}
+
+func TestCertificateMapper(t *testing.T) {
+	require := require.New(t)
+
+	cert := makeCertificate(t, &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "alice",
+			Organization:       []string{"levity"},
+			OrganizationalUnit: []string{"client", "oncall"},
+		},
+		URIs: []*url.URL{
+			mustParseURL(t, "levity://group/builders"),
+			mustParseURL(t, "levity://role/operator"),
+			mustParseURL(t, "https://example.com/alice"),
+		},
+		ExtraExtensions: []pkix.Extension{
+			{Id: testOID, Value: mustMarshal(t, []string{"viewer", "operator"})},
+		},
+	})
+
+	uut := CertificateMapper{
+		Groups: []ClaimSource{
+			mustParseClaimSource(t, "ou"),
+			mustParseClaimSource(t, "uri:levity://group/"),
+		},
+		Roles: []ClaimSource{
+			mustParseClaimSource(t, "uri:levity://role/"),
+			mustParseClaimSource(t, "oid:1.3.6.1.4.1.99999.1"),
+		},
+	}
+
+	u := uut.User(cert)
+	require.Equal("alice", u.Login())
+	require.Equal([]string{"client", "oncall", "builders"}, u.Groups())
+	require.Equal([]string{"operator", "viewer"}, u.Roles())
+}
+
+func TestCertificateMapper_NoSources(t *testing.T) {
+	require := require.New(t)
+
+	cert := makeCertificate(t, &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "bob",
+			OrganizationalUnit: []string{"client"},
+		},
+	})
+
+	u := (&CertificateMapper{}).User(cert)
+	require.Equal("bob", u.Login())
+	require.Empty(u.Groups())
+	require.Empty(u.Roles())
+}
+
+func TestExtensionClaims_SingleString(t *testing.T) {
+	cert := makeCertificate(t, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "carol"},
+		ExtraExtensions: []pkix.Extension{
+			{Id: testOID, Value: mustMarshal(t, "admin")},
+		},
+	})
+
+	uut := extensionClaims{oid: testOID}
+	require.Equal(t, []string{"admin"}, uut.Claims(cert))
+}
+
+func TestParseClaimSource_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "cn", "uri:", "oid:", "oid:1", "oid:1.x.3", "oid:1.-2"} {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseClaimSource(spec)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package authn
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authenticate identifies the user behind a request, returning a context
+// carrying that user for the handler to use, or an Unauthenticated status if
+// the request was rejected.
+func authenticate(ctx context.Context, a Authenticator, method string) (context.Context, error) {
+	u, err := a.Authenticate(ctx)
+	if err != nil {
+		log.Printf("Rejected %s request: %v", method, err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return user.NewContext(ctx, u), nil
+}
+
+// isPublic determines whether a method belongs to one of the public services
+// that don't require authentication
+func isPublic(method string, publicServices []string) bool {
+	for _, service := range publicServices {
+		if strings.HasPrefix(method, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor authenticates every unary request with the given
+// Authenticator, injecting the resulting user into the context supplied to
+// the handler. Requests to any of the named public services (e.g.
+// `grpc.health.v1.Health`) are passed through unauthenticated.
+func UnaryServerInterceptor(a Authenticator, publicServices ...string) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if isPublic(info.FullMethod, publicServices) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticate(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authenticatedStream wraps a server stream, replacing its context with one
+// carrying the authenticated user
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor authenticates every streaming request with the
+// given Authenticator, injecting the resulting user into the context of the
+// stream supplied to the handler. Public services are handled as for
+// `UnaryServerInterceptor`.
+func StreamServerInterceptor(a Authenticator, publicServices ...string) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		if isPublic(info.FullMethod, publicServices) {
+			return handler(srv, stream)
+		}
+
+		ctx, err := authenticate(stream.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
@@ -0,0 +1,105 @@
+package authn
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStream is the minimum of a server stream needed to exercise the
+// stream interceptor
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	require := require.New(t)
+	cert := makeCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}})
+	uut := StreamServerInterceptor(&CertificateMapper{})
+	info := &grpc.StreamServerInfo{FullMethod: "/levity.TaskManager/StreamLogs"}
+
+	// Given a handler that records the user it was called with
+	var login string
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		if u, ok := user.FromContext(stream.Context()); ok {
+			login = u.Login()
+		}
+		return nil
+	}
+
+	// When a stream with a client certificate is intercepted, expect that
+	// the handler sees the certificate's user
+	stream := &fakeStream{ctx: withPeerCertificate(context.Background(), cert)}
+	require.NoError(uut(nil, stream, info, handler))
+	require.Equal("alice", login)
+
+	// When a stream without credentials is intercepted, expect that it is
+	// rejected without the handler being called
+	login = ""
+	stream = &fakeStream{ctx: withPeerCertificate(context.Background(), nil)}
+	err := uut(nil, stream, info, handler)
+	require.Equal(codes.Unauthenticated, status.Code(err))
+	require.Empty(login)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	require := require.New(t)
+	uut := UnaryServerInterceptor(&CertificateMapper{})
+	info := &grpc.UnaryServerInfo{FullMethod: "/levity.TaskManager/QueryTask"}
+
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+
+	_, err := uut(withPeerCertificate(context.Background(), nil), nil, info, handler)
+	require.Equal(codes.Unauthenticated, status.Code(err))
+	require.False(called)
+}
+
+func TestInterceptors_PublicServices(t *testing.T) {
+	require := require.New(t)
+	unary := UnaryServerInterceptor(&CertificateMapper{}, "grpc.health.v1.Health")
+	stream := StreamServerInterceptor(&CertificateMapper{}, "grpc.health.v1.Health")
+	ctx := withPeerCertificate(context.Background(), nil)
+
+	// Expect that unauthenticated requests to a public service are handled
+	called := false
+	_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		func(context.Context, interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		})
+	require.NoError(err)
+	require.True(called)
+
+	called = false
+	err = stream(nil, &fakeStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"},
+		func(interface{}, grpc.ServerStream) error {
+			called = true
+			return nil
+		})
+	require.NoError(err)
+	require.True(called)
+
+	// ... but that requests to other services still need credentials,
+	// even if their names share a prefix with a public one
+	_, err = unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.HealthAdmin/Reset"},
+		func(context.Context, interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	require.Equal(codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,150 @@
+package authn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	osuser "os/user"
+	"strconv"
+
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// PeerCredentials describes the process on the other end of a Unix domain
+// socket, as reported by the kernel when the connection was accepted. Unlike
+// a certificate or token, these can't be forged by the client.
+type PeerCredentials struct {
+	credentials.CommonAuthInfo
+	PID int
+	UID int
+	GID int
+}
+
+// AuthType implements credentials.AuthInfo for PeerCredentials
+func (PeerCredentials) AuthType() string {
+	return "peercred"
+}
+
+// localCredentials wraps the transport credentials used for network
+// connections, so that connections to a set of local Unix domain sockets are
+// identified by their peer credentials instead. Connections to the local
+// sockets don't use TLS.
+type localCredentials struct {
+	credentials.TransportCredentials
+	sockets map[string]struct{}
+}
+
+// LocalCredentials creates server transport credentials that identify
+// connections to the given Unix domain sockets by their peer credentials, and
+// hand every other connection to the `network` credentials (e.g. TLS).
+func LocalCredentials(network credentials.TransportCredentials, sockets ...string) credentials.TransportCredentials {
+	c := &localCredentials{
+		TransportCredentials: network,
+		sockets:              make(map[string]struct{}, len(sockets)),
+	}
+	for _, s := range sockets {
+		c.sockets[s] = struct{}{}
+	}
+	return c
+}
+
+// ServerHandshake implements credentials.TransportCredentials for
+// localCredentials
+func (c *localCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return c.TransportCredentials.ServerHandshake(conn)
+	}
+
+	if _, local := c.sockets[unixConn.LocalAddr().String()]; !local {
+		return c.TransportCredentials.ServerHandshake(conn)
+	}
+
+	creds, err := peerCredentials(unixConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, creds, nil
+}
+
+// Clone implements credentials.TransportCredentials for localCredentials
+func (c *localCredentials) Clone() credentials.TransportCredentials {
+	return &localCredentials{
+		TransportCredentials: c.TransportCredentials.Clone(),
+		sockets:              c.sockets,
+	}
+}
+
+// PeerCredentialsMapper identifies the user behind a request made over a
+// local socket from the user ID in its peer credentials, taking their login
+// and the groups they belong to from the system's user database.
+type PeerCredentialsMapper struct {
+	// lookup finds a user by ID. Defaults to the system user database, but
+	// may be replaced for testing.
+	lookup func(uid string) (*osuser.User, error)
+
+	// groups finds the names of the groups a user belongs to. Defaults to
+	// the system group database, but may be replaced for testing.
+	groups func(u *osuser.User) ([]string, error)
+}
+
+// systemGroups finds the names of the groups a user belongs to in the
+// system's group database. Groups without a name are left out, as they
+// can't be referred to by policies or shares.
+func systemGroups(u *osuser.User) ([]string, error) {
+	ids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		g, err := osuser.LookupGroupId(id)
+		if _, unknown := err.(osuser.UnknownGroupIdError); unknown {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, g.Name)
+	}
+	return names, nil
+}
+
+// Authenticate implements Authenticator for PeerCredentialsMapper
+func (m *PeerCredentialsMapper) Authenticate(ctx context.Context) (*user.User, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	creds, ok := p.AuthInfo.(PeerCredentials)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	lookup := m.lookup
+	if lookup == nil {
+		lookup = osuser.LookupId
+	}
+
+	u, err := lookup(strconv.Itoa(creds.UID))
+	if err != nil {
+		return nil, &Unauthenticated{reason: fmt.Sprintf("unknown user ID %d", creds.UID)}
+	}
+
+	groups := m.groups
+	if groups == nil {
+		groups = systemGroups
+	}
+
+	groupNames, err := groups(u)
+	if err != nil {
+		return nil, &Unauthenticated{
+			reason: fmt.Sprintf("failed to look up groups for user %s: %v", u.Username, err)}
+	}
+
+	return user.NewWithClaims(u.Username, groupNames, nil), nil
+}
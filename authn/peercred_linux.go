@@ -0,0 +1,36 @@
+package authn
+
+import (
+	"net"
+	"syscall"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// peerCredentials asks the kernel for the credentials of the process on the
+// other end of a Unix domain socket
+func peerCredentials(conn *net.UnixConn) (PeerCredentials, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+
+	var ucred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+	if credErr != nil {
+		return PeerCredentials{}, credErr
+	}
+
+	return PeerCredentials{
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		PID:            int(ucred.Pid),
+		UID:            int(ucred.Uid),
+		GID:            int(ucred.Gid),
+	}, nil
+}
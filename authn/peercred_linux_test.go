@@ -0,0 +1,30 @@
+package authn
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalCredentials_LocalSocket(t *testing.T) {
+	require := require.New(t)
+
+	// Given a connection to a local socket
+	socket, conn := acceptUnix(t, "local.sock")
+	network := &recordingCredentials{}
+	uut := LocalCredentials(network, socket)
+
+	// When the server handshakes with it
+	_, authInfo, err := uut.ServerHandshake(conn)
+	require.NoError(err)
+
+	// Expect that the client is identified by its peer credentials (i.e.
+	// as this process), without TLS
+	creds, ok := authInfo.(PeerCredentials)
+	require.True(ok)
+	require.Equal(os.Getuid(), creds.UID)
+	require.Equal(os.Getgid(), creds.GID)
+	require.Equal(os.Getpid(), creds.PID)
+	require.Equal(0, network.handshakes)
+}
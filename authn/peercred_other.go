@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package authn
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCredentials is only implemented for Linux, where the kernel reports
+// them via SO_PEERCRED
+func peerCredentials(conn *net.UnixConn) (PeerCredentials, error) {
+	return PeerCredentials{}, errors.New("Peer credentials are not supported on this platform")
+}
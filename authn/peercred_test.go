@@ -0,0 +1,131 @@
+package authn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	osuser "os/user"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// recordingCredentials stands in for the TLS credentials used for network
+// connections, recording whether it was asked to handshake
+type recordingCredentials struct {
+	credentials.TransportCredentials
+	handshakes int
+}
+
+func (c *recordingCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	c.handshakes++
+	return conn, nil, nil
+}
+
+// acceptUnix connects to a new Unix socket in a temporary directory,
+// returning the server's end of the connection
+func acceptUnix(t *testing.T, name string) (socket string, conn net.Conn) {
+	socket = path.Join(t.TempDir(), name)
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	conn, err = l.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return socket, conn
+}
+
+func TestLocalCredentials_OtherSockets(t *testing.T) {
+	require := require.New(t)
+
+	// Given a connection to a Unix socket that isn't one of the local ones
+	_, conn := acceptUnix(t, "tls.sock")
+	network := &recordingCredentials{}
+	uut := LocalCredentials(network, "/run/levityd/local.sock")
+
+	// When the server handshakes with it
+	_, _, err := uut.ServerHandshake(conn)
+	require.NoError(err)
+
+	// Expect that the network credentials (i.e. TLS) are used as normal
+	require.Equal(1, network.handshakes)
+}
+
+func withPeerCredentials(ctx context.Context, uid int) context.Context {
+	return peer.NewContext(ctx, &peer.Peer{AuthInfo: PeerCredentials{UID: uid}})
+}
+
+func TestPeerCredentialsMapper(t *testing.T) {
+	require := require.New(t)
+	uut := &PeerCredentialsMapper{
+		lookup: func(uid string) (*osuser.User, error) {
+			if uid == "1000" {
+				return &osuser.User{Uid: uid, Username: "alice"}, nil
+			}
+			return nil, errors.New("no such user")
+		},
+		groups: func(u *osuser.User) ([]string, error) {
+			return []string{"alice", "developers"}, nil
+		},
+	}
+
+	// Expect that a known user is identified by their login, along with the
+	// groups they belong to...
+	u, err := uut.Authenticate(withPeerCredentials(context.Background(), 1000))
+	require.NoError(err)
+	require.Equal("alice", u.Login())
+	require.ElementsMatch([]string{"alice", "developers"}, u.Groups())
+
+	// ... that an unknown user is rejected outright
+	_, err = uut.Authenticate(withPeerCredentials(context.Background(), 1001))
+	require.IsType(&Unauthenticated{}, err)
+
+	// ... and that other kinds of connection are left to other
+	// authenticators
+	_, err = uut.Authenticate(withPeerCertificate(context.Background(), nil))
+	require.Equal(ErrNoCredentials, err)
+	_, err = uut.Authenticate(context.Background())
+	require.Equal(ErrNoCredentials, err)
+}
+
+func TestPeerCredentialsMapper_GroupLookupFails(t *testing.T) {
+	uut := &PeerCredentialsMapper{
+		lookup: func(uid string) (*osuser.User, error) {
+			return &osuser.User{Uid: uid, Username: "alice"}, nil
+		},
+		groups: func(u *osuser.User) ([]string, error) {
+			return nil, errors.New("group database unavailable")
+		},
+	}
+
+	// Expect that a user whose groups can't be found is rejected, rather
+	// than treated as belonging to none
+	_, err := uut.Authenticate(withPeerCredentials(context.Background(), 1000))
+	require.IsType(t, &Unauthenticated{}, err)
+}
+
+func TestPeerCredentialsMapper_SystemUsers(t *testing.T) {
+	require := require.New(t)
+	current, err := osuser.Current()
+	require.NoError(err)
+
+	// Expect that, by default, users are looked up in the system database
+	u, err := (&PeerCredentialsMapper{}).Authenticate(
+		withPeerCredentials(context.Background(), os.Getuid()))
+	require.NoError(err)
+	require.Equal(current.Username, u.Login())
+
+	// ... along with their groups, including their primary group
+	primary, err := osuser.LookupGroupId(current.Gid)
+	require.NoError(err)
+	require.Contains(u.Groups(), primary.Name)
+}
@@ -0,0 +1,287 @@
+package authn
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Revoked is an error type indicating that a client certificate has been
+// revoked, either by a CRL or the local deny-list.
+type Revoked struct {
+	reason string
+}
+
+func (e *Revoked) Error() string {
+	return fmt.Sprintf("Certificate revoked: %s", e.reason)
+}
+
+// revocationData is an immutable snapshot of the revocation information
+// loaded from disk
+type revocationData struct {
+	// revoked by a CRL, keyed by the issuer name and serial number
+	crlSerials map[string]struct{}
+
+	// revoked by the deny-list, keyed by serial number alone
+	deniedSerials map[string]struct{}
+
+	// revoked by the deny-list, keyed by SHA-256 fingerprint
+	deniedFingerprints map[[sha256.Size]byte]struct{}
+}
+
+// Revocations checks client certificates against a set of certificate
+// revocation lists and a local deny-list. Both are re-read from disk when
+// `Reload` is called, so it is safe to use from multiple goroutines.
+type Revocations struct {
+	crlPaths     []string
+	denyListPath string
+
+	lock sync.RWMutex
+	cas  []*x509.Certificate
+	data *revocationData
+}
+
+// NewRevocations creates a revocation checker, loading the initial
+// revocation data from disk. CRLs must be signed by one of the supplied CA
+// certificates. Either the CRLs or the deny-list may be omitted.
+//
+// The deny-list is a text file with one entry per line, either a certificate
+// serial number in hex (`serial:01ab...`) or a SHA-256 fingerprint of the
+// DER-encoded certificate (`sha256:9f86...`). Blank lines and lines starting
+// with `#` are ignored. Colons within the hex digits are allowed.
+func NewRevocations(
+	cas []*x509.Certificate, crlPaths []string, denyListPath string) (*Revocations, error) {
+	r := &Revocations{
+		cas:          cas,
+		crlPaths:     crlPaths,
+		denyListPath: denyListPath,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Paths lists the files that the revocation data is loaded from.
+func (r *Revocations) Paths() []string {
+	result := append([]string{}, r.crlPaths...)
+	if r.denyListPath != "" {
+		result = append(result, r.denyListPath)
+	}
+	return result
+}
+
+// SetCAs replaces the set of CA certificates that CRLs must be signed by, for
+// when the client CA bundle is rotated. The new CAs take effect the next time
+// the revocation data is reloaded.
+func (r *Revocations) SetCAs(cas []*x509.Certificate) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cas = cas
+}
+
+// Reload re-reads the revocation data from disk. If any of the files fail to
+// load, the existing revocation data is left in place and an error returned.
+func (r *Revocations) Reload() error {
+	r.lock.RLock()
+	cas := r.cas
+	r.lock.RUnlock()
+
+	data := &revocationData{
+		crlSerials:         make(map[string]struct{}),
+		deniedSerials:      make(map[string]struct{}),
+		deniedFingerprints: make(map[[sha256.Size]byte]struct{}),
+	}
+
+	for _, path := range r.crlPaths {
+		if err := loadCRL(path, cas, data); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	if r.denyListPath != "" {
+		if err := loadDenyList(r.denyListPath, data); err != nil {
+			return err
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.data = data
+
+	return nil
+}
+
+func crlKey(rawIssuer []byte, serial *big.Int) string {
+	return hex.EncodeToString(rawIssuer) + "/" + serial.Text(16)
+}
+
+func loadCRL(path string, cas []*x509.Certificate, data *revocationData) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// NB: ParseCRL accepts both PEM and DER-encoded CRLs
+	crl, err := x509.ParseCRL(content)
+	if err != nil {
+		return err
+	}
+
+	var issuer *x509.Certificate
+	for _, ca := range cas {
+		if ca.CheckCRLSignature(crl) == nil {
+			issuer = ca
+			break
+		}
+	}
+
+	if issuer == nil {
+		return errors.New("CRL not signed by a trusted client CA")
+	}
+
+	if crl.HasExpired(time.Now()) {
+		log.Printf("Warning: CRL %s is past its next update time", path)
+	}
+
+	// Certificates issued by the CA will have the CA's subject as their
+	// issuer, so that's what we key the revoked serial numbers on
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		data.crlSerials[crlKey(issuer.RawSubject, revoked.SerialNumber)] = struct{}{}
+	}
+
+	return nil
+}
+
+func loadDenyList(path string, data *revocationData) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("%s:%d: expected serial:HEX or sha256:HEX", path, lineNumber)
+		}
+
+		value, err := hex.DecodeString(strings.ReplaceAll(parts[1], ":", ""))
+		if err != nil || len(value) == 0 {
+			return fmt.Errorf("%s:%d: invalid hex value", path, lineNumber)
+		}
+
+		switch strings.ToLower(parts[0]) {
+		case "serial":
+			data.deniedSerials[new(big.Int).SetBytes(value).Text(16)] = struct{}{}
+
+		case "sha256":
+			var fingerprint [sha256.Size]byte
+			if len(value) != len(fingerprint) {
+				return fmt.Errorf("%s:%d: invalid SHA-256 fingerprint", path, lineNumber)
+			}
+			copy(fingerprint[:], value)
+			data.deniedFingerprints[fingerprint] = struct{}{}
+
+		default:
+			return fmt.Errorf("%s:%d: unknown entry type %q", path, lineNumber, parts[0])
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Check tests the certificate against the current revocation data,
+// returning a Revoked error if it has been revoked.
+func (r *Revocations) Check(cert *x509.Certificate) error {
+	r.lock.RLock()
+	data := r.data
+	r.lock.RUnlock()
+
+	if _, revoked := data.crlSerials[crlKey(cert.RawIssuer, cert.SerialNumber)]; revoked {
+		return &Revoked{reason: fmt.Sprintf("serial %s listed in CRL", cert.SerialNumber.Text(16))}
+	}
+
+	if _, denied := data.deniedSerials[cert.SerialNumber.Text(16)]; denied {
+		return &Revoked{reason: fmt.Sprintf("serial %s is deny-listed", cert.SerialNumber.Text(16))}
+	}
+
+	if _, denied := data.deniedFingerprints[sha256.Sum256(cert.Raw)]; denied {
+		return &Revoked{reason: "fingerprint is deny-listed"}
+	}
+
+	return nil
+}
+
+// VerifyConnection checks the client certificate on a connection that has
+// already been verified by the TLS stack for revocation, logging any
+// rejected certificates. Suitable for use as a `tls.Config.VerifyConnection`
+// hook which, unlike `VerifyPeerCertificate`, is also called when a client
+// resumes an earlier TLS session.
+func (r *Revocations) VerifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	leaf := state.PeerCertificates[0]
+	if err := r.Check(leaf); err != nil {
+		log.Printf("Rejected client certificate for %q (serial %s, SHA-256 %s): %v",
+			leaf.Subject.CommonName,
+			leaf.SerialNumber.Text(16),
+			Fingerprint(leaf),
+			err)
+		return err
+	}
+	return nil
+}
+
+// Fingerprint formats the SHA-256 fingerprint of a certificate as hex
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseCertificates decodes all of the certificates in a PEM bundle
+func ParseCertificates(pemData []byte) ([]*x509.Certificate, error) {
+	var result []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, cert)
+	}
+	return result, nil
+}
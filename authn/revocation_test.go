@@ -0,0 +1,188 @@
+package authn
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  ed25519.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-1 * time.Hour),
+		NotAfter:              time.Now().Add(1 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: priv}
+}
+
+func (ca *testCA) issue(t *testing.T, login string, serial int64) *x509.Certificate {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: login},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// writeCRL writes a PEM-encoded CRL revoking the given serial numbers
+func (ca *testCA) writeCRL(t *testing.T, filename string, serials ...int64) {
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range serials {
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now(),
+		})
+	}
+
+	template := &x509.RevocationList{
+		Number:              big.NewInt(1),
+		ThisUpdate:          time.Now().Add(-1 * time.Hour),
+		NextUpdate:          time.Now().Add(1 * time.Hour),
+		RevokedCertificates: revoked,
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	require.NoError(t, ioutil.WriteFile(filename, data, 0600))
+}
+
+func TestRevocations_CRL(t *testing.T) {
+	require := require.New(t)
+	ca := newTestCA(t, "ClientCA")
+	otherCA := newTestCA(t, "OtherCA")
+	crlPath := path.Join(t.TempDir(), "client.crl")
+	ca.writeCRL(t, crlPath, 2)
+
+	// Given a revocation checker with a CRL that revokes serial number 2
+	uut, err := NewRevocations([]*x509.Certificate{ca.cert, otherCA.cert}, []string{crlPath}, "")
+	require.NoError(err)
+
+	// Expect that a certificate with serial number 2 is revoked...
+	require.IsType(&Revoked{}, uut.Check(ca.issue(t, "chuck", 2)))
+
+	// ... but that other certificates from the same CA are not
+	require.NoError(uut.Check(ca.issue(t, "alice", 3)))
+
+	// ... nor is a certificate with the same serial from a different CA
+	require.NoError(uut.Check(otherCA.issue(t, "bob", 2)))
+
+	// When the CRL is updated and reloaded
+	ca.writeCRL(t, crlPath, 3)
+	require.NoError(uut.Reload())
+
+	// Expect that the new CRL is in effect
+	require.NoError(uut.Check(ca.issue(t, "chuck", 2)))
+	require.IsType(&Revoked{}, uut.Check(ca.issue(t, "alice", 3)))
+}
+
+func TestRevocations_UntrustedCRL(t *testing.T) {
+	ca := newTestCA(t, "ClientCA")
+	otherCA := newTestCA(t, "OtherCA")
+	crlPath := path.Join(t.TempDir(), "client.crl")
+	otherCA.writeCRL(t, crlPath, 2)
+
+	_, err := NewRevocations([]*x509.Certificate{ca.cert}, []string{crlPath}, "")
+	require.Error(t, err)
+}
+
+func TestRevocations_DenyList(t *testing.T) {
+	require := require.New(t)
+	ca := newTestCA(t, "ClientCA")
+	bySerial := ca.issue(t, "chuck", 0x1234)
+	byFingerprint := ca.issue(t, "mallory", 5)
+	innocent := ca.issue(t, "alice", 6)
+
+	denyListPath := path.Join(t.TempDir(), "deny-list")
+	content := fmt.Sprintf("# leaked keys\nserial:12:34\n\nsha256:%s\n", Fingerprint(byFingerprint))
+	require.NoError(ioutil.WriteFile(denyListPath, []byte(content), 0600))
+
+	uut, err := NewRevocations([]*x509.Certificate{ca.cert}, nil, denyListPath)
+	require.NoError(err)
+
+	require.IsType(&Revoked{}, uut.Check(bySerial))
+	require.IsType(&Revoked{}, uut.Check(byFingerprint))
+	require.NoError(uut.Check(innocent))
+
+	// A connection with a revoked client certificate is rejected by the TLS
+	// hook
+	err = uut.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{bySerial, ca.cert}})
+	require.IsType(&Revoked{}, err)
+	require.NoError(uut.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{innocent, ca.cert}}))
+}
+
+func TestRevocations_FailedReloadKeepsOldData(t *testing.T) {
+	require := require.New(t)
+	ca := newTestCA(t, "ClientCA")
+	revoked := ca.issue(t, "chuck", 7)
+
+	denyListPath := path.Join(t.TempDir(), "deny-list")
+	require.NoError(ioutil.WriteFile(denyListPath, []byte("serial:07\n"), 0600))
+	uut, err := NewRevocations(nil, nil, denyListPath)
+	require.NoError(err)
+
+	require.NoError(ioutil.WriteFile(denyListPath, []byte("garbage\n"), 0600))
+	require.Error(uut.Reload())
+	require.IsType(&Revoked{}, uut.Check(revoked))
+}
+
+func TestRevocations_SetCAs(t *testing.T) {
+	require := require.New(t)
+	oldCA := newTestCA(t, "ClientCA")
+	newCA := newTestCA(t, "ClientCA-2")
+	crlPath := path.Join(t.TempDir(), "client.crl")
+	oldCA.writeCRL(t, crlPath)
+
+	// Given a revocation checker trusting the original client CA
+	uut, err := NewRevocations([]*x509.Certificate{oldCA.cert}, []string{crlPath}, "")
+	require.NoError(err)
+
+	// When the CRL is replaced with one from a rotated CA
+	newCA.writeCRL(t, crlPath, 2)
+
+	// Expect that it is rejected until the new CA is trusted
+	require.Error(uut.Reload())
+	uut.SetCAs([]*x509.Certificate{newCA.cert})
+	require.NoError(uut.Reload())
+	require.IsType(&Revoked{}, uut.Check(newCA.issue(t, "chuck", 2)))
+}
@@ -0,0 +1,399 @@
+package authn
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc/metadata"
+)
+
+// The JWT handling here is deliberately minimal: compact-serialised, signed
+// tokens only, and only the handful of algorithms we expect token issuers to
+// use. Anything else is rejected.
+
+const (
+	// AuthorizationHeader is the GRPC metadata key carrying bearer tokens
+	AuthorizationHeader = "authorization"
+
+	bearerPrefix = "bearer "
+
+	// clockLeeway allows for small differences between the token issuer's
+	// clock and ours when checking token lifetimes
+	clockLeeway = 1 * time.Minute
+)
+
+// jsonWebKey is the subset of RFC 7517 that we need to verify signatures
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type verificationKey struct {
+	id  string
+	alg string
+	key crypto.PublicKey
+}
+
+func decodeSegment(text string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(text, "="))
+}
+
+func decodeBigInt(text string) (*big.Int, error) {
+	data, err := decodeSegment(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func (k *jsonWebKey) publicKey() (verificationKey, error) {
+	result := verificationKey{id: k.Kid}
+
+	switch k.Kty {
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return result, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return result, fmt.Errorf("invalid Ed25519 key")
+		}
+		result.alg = "EdDSA"
+		result.key = ed25519.PublicKey(x)
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return result, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return result, fmt.Errorf("invalid EC key: %v", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return result, fmt.Errorf("invalid EC key: %v", err)
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return result, fmt.Errorf("invalid EC key: point not on curve")
+		}
+		result.alg = "ES256"
+		result.key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return result, fmt.Errorf("invalid RSA key: %v", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return result, fmt.Errorf("invalid RSA key exponent")
+		}
+		if n.BitLen() < 2048 {
+			return result, fmt.Errorf("RSA key too small")
+		}
+		result.alg = "RS256"
+		result.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+
+	default:
+		return result, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+
+	if k.Alg != "" && k.Alg != result.alg {
+		return result, fmt.Errorf("unsupported algorithm %q for %s key", k.Alg, k.Kty)
+	}
+
+	return result, nil
+}
+
+// KeySet is a set of public keys, loaded from a JSON Web Key Set (RFC 7517)
+// file, that token signatures are verified against. The file is re-read
+// when `Reload` is called, so it is safe to use from multiple goroutines.
+type KeySet struct {
+	path string
+
+	lock sync.RWMutex
+	keys []verificationKey
+}
+
+// LoadKeySet reads a JWKS file. Ed25519 (`OKP`), P-256 (`EC`) and RSA keys
+// are supported; keys marked for encryption are ignored.
+func LoadKeySet(path string) (*KeySet, error) {
+	ks := &KeySet{path: path}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Path is the name of the file the keys are loaded from
+func (ks *KeySet) Path() string {
+	return ks.path
+}
+
+// Reload re-reads the key set from disk. If the file fails to load, the
+// existing keys are left in place and an error returned.
+func (ks *KeySet) Reload() error {
+	content, err := ioutil.ReadFile(ks.path)
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return fmt.Errorf("%s: %v", ks.path, err)
+	}
+
+	var keys []verificationKey
+	for i, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return fmt.Errorf("%s: key %d: %v", ks.path, i, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("%s: no signing keys found", ks.path)
+	}
+
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	ks.keys = keys
+
+	return nil
+}
+
+// candidates lists the keys that may have signed a token with the given
+// header. A key is only ever used with the algorithm appropriate for its type,
+// so a token can't persuade us to (say) use an RSA key as an HMAC secret.
+func (ks *KeySet) candidates(alg, kid string) []verificationKey {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+
+	var result []verificationKey
+	for _, key := range ks.keys {
+		if key.alg == alg && (kid == "" || key.id == kid) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+func verifySignature(key verificationKey, signed, signature []byte) bool {
+	switch k := key.key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, signed, signature)
+
+	case *ecdsa.PublicKey:
+		// JWS encodes ECDSA signatures as the fixed-width concatenation of
+		// R and S, rather than the ASN.1 structure the stdlib expects
+		if len(signature) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
+
+// TokenAuthenticator identifies users by a signed JWT, carried as a bearer
+// token in the request's `authorization` metadata.
+type TokenAuthenticator struct {
+	Keys *KeySet
+
+	// Issuer and Audience, if set, must match the token's `iss` and `aud`
+	// claims.
+	Issuer   string
+	Audience string
+
+	// The names of the claims holding the user's login, groups and roles.
+	// The group and role claims may hold either a single string or a list
+	// of them, and are optional.
+	LoginClaim  string
+	GroupsClaim string
+	RolesClaim  string
+}
+
+// Authenticate implements Authenticator for bearer tokens
+func (a *TokenAuthenticator) Authenticate(ctx context.Context) (*user.User, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	values := md.Get(AuthorizationHeader)
+	if len(values) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	if len(values) > 1 {
+		return nil, &Unauthenticated{reason: "multiple authorization headers"}
+	}
+
+	if !strings.HasPrefix(strings.ToLower(values[0]), bearerPrefix) {
+		return nil, ErrNoCredentials
+	}
+
+	return a.Verify(strings.TrimSpace(values[0][len(bearerPrefix):]), time.Now())
+}
+
+// Verify checks a token's signature and lifetime as of the given time, and
+// maps its claims onto a user.
+func (a *TokenAuthenticator) Verify(token string, now time.Time) (*user.User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, &Unauthenticated{reason: "malformed token"}
+	}
+
+	headerJSON, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, &Unauthenticated{reason: "malformed token header"}
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, &Unauthenticated{reason: "malformed token header"}
+	}
+
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, &Unauthenticated{reason: "malformed token signature"}
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range a.Keys.candidates(header.Alg, header.Kid) {
+		if verifySignature(key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, &Unauthenticated{reason: "invalid token signature"}
+	}
+
+	claimsJSON, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, &Unauthenticated{reason: "malformed token claims"}
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, &Unauthenticated{reason: "malformed token claims"}
+	}
+
+	if err := a.checkClaims(claims, now); err != nil {
+		return nil, err
+	}
+
+	login, _ := claims[a.LoginClaim].(string)
+	if login == "" {
+		return nil, &Unauthenticated{reason: fmt.Sprintf("token has no %q claim", a.LoginClaim)}
+	}
+
+	return user.NewWithClaims(login,
+		stringClaims(claims, a.GroupsClaim),
+		stringClaims(claims, a.RolesClaim)), nil
+}
+
+func (a *TokenAuthenticator) checkClaims(claims map[string]interface{}, now time.Time) error {
+	// Tokens are expected to be short-lived, so we insist on an expiry time
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return &Unauthenticated{reason: "token has no expiry time"}
+	}
+
+	if now.After(time.Unix(int64(exp), 0).Add(clockLeeway)) {
+		return &Unauthenticated{reason: "token has expired"}
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok {
+		if now.Add(clockLeeway).Before(time.Unix(int64(nbf), 0)) {
+			return &Unauthenticated{reason: "token is not yet valid"}
+		}
+	}
+
+	if a.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.Issuer {
+			return &Unauthenticated{reason: "token has an unexpected issuer"}
+		}
+	}
+
+	if a.Audience != "" {
+		found := false
+		for _, aud := range stringClaims(claims, "aud") {
+			if aud == a.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &Unauthenticated{reason: "token is not intended for this server"}
+		}
+	}
+
+	return nil
+}
+
+// stringClaims extracts a claim that may either be a single string or a list
+// of them
+func stringClaims(claims map[string]interface{}, name string) []string {
+	if name == "" {
+		return nil
+	}
+
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+
+	case []interface{}:
+		var result []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+
+	return nil
+}
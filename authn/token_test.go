@@ -0,0 +1,238 @@
+package authn
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// testSigner mints JWTs, and describes its public key as a JWK
+type testSigner struct {
+	kid string
+	alg string
+	key crypto.Signer
+	jwk map[string]string
+}
+
+func newEd25519Signer(t *testing.T, kid string) *testSigner {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return &testSigner{
+		kid: kid,
+		alg: "EdDSA",
+		key: priv,
+		jwk: map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": kid, "x": b64(pub)},
+	}
+}
+
+func newECSigner(t *testing.T, kid string) *testSigner {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pad := func(n *big.Int) string {
+		buf := make([]byte, 32)
+		return b64(n.FillBytes(buf))
+	}
+	return &testSigner{
+		kid: kid,
+		alg: "ES256",
+		key: priv,
+		jwk: map[string]string{"kty": "EC", "crv": "P-256", "kid": kid, "x": pad(priv.X), "y": pad(priv.Y)},
+	}
+}
+
+func newRSASigner(t *testing.T, kid string) *testSigner {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return &testSigner{
+		kid: kid,
+		alg: "RS256",
+		key: priv,
+		jwk: map[string]string{
+			"kty": "RSA", "kid": kid,
+			"n": b64(priv.N.Bytes()),
+			"e": b64(big.NewInt(int64(priv.E)).Bytes()),
+		},
+	}
+}
+
+func (s *testSigner) sign(t *testing.T, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	require.NoError(t, err)
+	body, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := b64(header) + "." + b64(body)
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signed))
+
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	}
+
+	return signed + "." + b64(signature)
+}
+
+func writeKeySet(t *testing.T, signers ...*testSigner) string {
+	var keys []map[string]string
+	for _, s := range signers {
+		keys = append(keys, s.jwk)
+	}
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+
+	filename := path.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, ioutil.WriteFile(filename, data, 0600))
+	return filename
+}
+
+func newTokenAuthenticator(t *testing.T, signers ...*testSigner) *TokenAuthenticator {
+	keys, err := LoadKeySet(writeKeySet(t, signers...))
+	require.NoError(t, err)
+	return &TokenAuthenticator{
+		Keys:        keys,
+		Issuer:      "https://ci.example.com",
+		Audience:    "levity",
+		LoginClaim:  "sub",
+		GroupsClaim: "groups",
+		RolesClaim:  "roles",
+	}
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":    "ci-runner",
+		"iss":    "https://ci.example.com",
+		"aud":    []string{"levity", "other"},
+		"exp":    time.Now().Add(5 * time.Minute).Unix(),
+		"groups": []string{"builders"},
+		"roles":  "operator",
+	}
+}
+
+func TestTokenAuthenticator_Algorithms(t *testing.T) {
+	signers := []*testSigner{
+		newEd25519Signer(t, "ed"),
+		newECSigner(t, "ec"),
+		newRSASigner(t, "rsa"),
+	}
+	uut := newTokenAuthenticator(t, signers...)
+
+	for _, signer := range signers {
+		t.Run(signer.alg, func(t *testing.T) {
+			require := require.New(t)
+			u, err := uut.Verify(signer.sign(t, validClaims()), time.Now())
+			require.NoError(err)
+			require.Equal("ci-runner", u.Login())
+			require.Equal([]string{"builders"}, u.Groups())
+			require.Equal([]string{"operator"}, u.Roles())
+		})
+	}
+}
+
+// tamper replaces the claims in a signed token, leaving the signature as-is
+func tamper(token string, claims map[string]interface{}) string {
+	parts := strings.Split(token, ".")
+	body, _ := json.Marshal(claims)
+	return parts[0] + "." + b64(body) + "." + parts[2]
+}
+
+func TestTokenAuthenticator_Rejects(t *testing.T) {
+	signer := newEd25519Signer(t, "ed")
+	stranger := newEd25519Signer(t, "ed")
+	uut := newTokenAuthenticator(t, signer)
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	testCases := map[string]string{
+		"unknown key":     stranger.sign(t, validClaims()),
+		"expired":         signer.sign(t, with("exp", time.Now().Add(-time.Hour).Unix())),
+		"no expiry":       signer.sign(t, with("exp", nil)),
+		"not yet valid":   signer.sign(t, with("nbf", time.Now().Add(time.Hour).Unix())),
+		"wrong issuer":    signer.sign(t, with("iss", "https://evil.example.com")),
+		"wrong audience":  signer.sign(t, with("aud", "someone-else")),
+		"no login":        signer.sign(t, with("sub", nil)),
+		"unsigned":        b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"sub":"root"}`)) + ".",
+		"malformed":       "not-a-token",
+		"tampered claims": tamper(signer.sign(t, validClaims()), with("sub", "root")),
+	}
+
+	for name, token := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := uut.Verify(token, time.Now())
+			require.IsType(t, &Unauthenticated{}, err)
+		})
+	}
+}
+
+func TestTokenAuthenticator_Metadata(t *testing.T) {
+	require := require.New(t)
+	signer := newEd25519Signer(t, "ed")
+	uut := newTokenAuthenticator(t, signer)
+
+	// A request without a token is passed on to the next authenticator
+	_, err := uut.Authenticate(context.Background())
+	require.Equal(ErrNoCredentials, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(AuthorizationHeader, "Basic YWxpY2U6aHVudGVyMg=="))
+	_, err = uut.Authenticate(ctx)
+	require.Equal(ErrNoCredentials, err)
+
+	// ... but a request with a token is authenticated by it
+	ctx = metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(AuthorizationHeader, "Bearer "+signer.sign(t, validClaims())))
+	u, err := uut.Authenticate(ctx)
+	require.NoError(err)
+	require.Equal("ci-runner", u.Login())
+}
+
+func TestKeySet_FailedReloadKeepsOldKeys(t *testing.T) {
+	require := require.New(t)
+	signer := newEd25519Signer(t, "ed")
+	uut := newTokenAuthenticator(t, signer)
+
+	require.NoError(ioutil.WriteFile(uut.Keys.Path(), []byte("{"), 0600))
+	require.Error(uut.Keys.Reload())
+
+	_, err := uut.Verify(signer.sign(t, validClaims()), time.Now())
+	require.NoError(err)
+}
@@ -0,0 +1,522 @@
+// Package client provides a Go API for starting and managing tasks on a
+// levity server, as used by the `levity` command line client.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tcsc/levity/api"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// unixPrefix marks a server address as a Unix domain socket
+const unixPrefix = "unix:"
+
+// pollInterval is how often Wait checks on a task
+const pollInterval = 250 * time.Millisecond
+
+// State describes where a task is in its lifecycle
+type State string
+
+const (
+	// StateNotStarted means the task has been created, but its process has
+	// not yet started
+	StateNotStarted State = "NotStarted"
+
+	// StateRunning means the task is running normally
+	StateRunning State = "Running"
+
+	// StateSignalled means the task has been asked to quit, but has not yet
+	// exited
+	StateSignalled State = "Signalled"
+
+	// StateFinished means the task has exited, either of its own accord or
+	// after being signalled
+	StateFinished State = "Finished"
+
+	// StateBrutallyKilled means the task did not quit when signalled, and
+	// was killed
+	StateBrutallyKilled State = "BrutallyKilled"
+
+	// StateInternalServerError means the task failed due to an unexpected
+	// error in the server
+	StateInternalServerError State = "InternalServerError"
+)
+
+// Status describes the state of a task
+type Status struct {
+	State State
+
+	// ExitCode is the exit code of the task's process. Only valid if the
+	// state is StateFinished, and -1 if the process was killed by a signal.
+	ExitCode int
+
+	// Signal is the signal that killed the task's process, or zero if it
+	// wasn't killed by one
+	Signal syscall.Signal
+
+	// Labels are the labels the task was started with
+	Labels map[string]string
+}
+
+// Done tests if the task is over, i.e. will never change state again
+func (s *Status) Done() bool {
+	switch s.State {
+	case StateFinished, StateBrutallyKilled, StateInternalServerError:
+		return true
+	}
+	return false
+}
+
+// Command describes a task to start
+type Command struct {
+	Binary string
+	Args   []string
+
+	// Dir is the working directory to run the task in, if not the server's
+	// default
+	Dir string
+
+	// Env holds the environment variables to pass to the task, subject to
+	// the server's environment policy
+	Env map[string]string
+
+	// Labels are arbitrary name/value pairs to tag the task with, so that it
+	// can be picked out later with a Selector
+	Labels map[string]string
+}
+
+// Output holds the output a task has written so far
+type Output struct {
+	Stdout []byte
+	Stderr []byte
+}
+
+// Action is something that a task may be shared with other users for
+type Action string
+
+const (
+	// ActionQuery allows querying the task's status
+	ActionQuery Action = "query"
+
+	// ActionLogs allows fetching (or following) the task's output
+	ActionLogs Action = "logs"
+
+	// ActionSignal allows signalling the task to quit
+	ActionSignal Action = "signal"
+)
+
+var apiActions = map[Action]api.TaskAction{
+	ActionQuery:  api.TaskAction_Query,
+	ActionLogs:   api.TaskAction_Logs,
+	ActionSignal: api.TaskAction_Signal,
+}
+
+// Grant describes the users and groups to share a task with, and what they
+// may do with it
+type Grant struct {
+	Users   []string
+	Groups  []string
+	Actions []Action
+}
+
+// Selector picks out the tasks for a batch request: either those with the
+// given IDs, or (if All is set) every task the caller may act on. Either way,
+// only the tasks with all of the given labels, and in one of the given states,
+// are selected; no labels or states means any will do.
+type Selector struct {
+	IDs    []string
+	All    bool
+	Labels map[string]string
+	States []State
+}
+
+// TaskResult is the outcome of a batch request for a single task. Err is
+// the error that a request for that task alone would have failed with, if
+// any. Status is only set by QueryTasks, and only if Err is nil.
+type TaskResult struct {
+	TaskID string
+	Status *Status
+	Err    error
+}
+
+// Client makes requests to a levity server. It is safe for concurrent use.
+type Client struct {
+	conn    *grpc.ClientConn
+	api     api.TaskManagerClient
+	timeout time.Duration
+}
+
+// New creates a Client for the server at the address given with
+// WithAddress. The client must be identified with a certificate or a bearer
+// token, unless it connects to the server's local socket (where it's
+// identified by the user it runs as).
+//
+// No connection is made until the first request.
+func New(opts ...Option) (*Client, error) {
+	o := options{retries: DefaultRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.address == "" {
+		return nil, errors.New("No server address")
+	}
+
+	if o.retries < 0 {
+		return nil, errors.New("Retries must not be negative")
+	}
+
+	dialOptions, err := o.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	// NB: The retry interceptor is outermost, so each retry is traced as a
+	//     separate request
+	dialOptions = append(dialOptions, connectionOptions(newRetryPolicy(o.retries))...)
+	dialOptions = append(dialOptions,
+		grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()))
+	dialOptions = append(dialOptions, o.dialOptions...)
+
+	conn, err := grpc.Dial(o.address, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		conn:    conn,
+		api:     api.NewTaskManagerClient(conn),
+		timeout: o.timeout,
+	}, nil
+}
+
+// bearerToken supplies a bearer token with every request, for servers that
+// accept tokens in lieu of client certificates
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return true
+}
+
+// loadToken reads the bearer token to authenticate with, if any
+func (o *options) loadToken() (string, error) {
+	if o.tokenFile == "" {
+		return strings.TrimSpace(o.token), nil
+	}
+
+	content, err := ioutil.ReadFile(o.tokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("%s: empty token", o.tokenFile)
+	}
+	return token, nil
+}
+
+// credentials loads the certificates and token the client identifies itself
+// with, and builds the options to dial the server with them
+func (o *options) credentials() ([]grpc.DialOption, error) {
+	token, err := o.loadToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if (o.certFile == "") != (o.keyFile == "") {
+		return nil, errors.New("A client certificate and key must be supplied together")
+	}
+
+	if o.certFile == "" && token == "" {
+		// The daemon's local socket identifies clients by their user ID, so
+		// they don't need any credentials of their own (and don't use TLS)
+		if strings.HasPrefix(o.address, unixPrefix) {
+			return []grpc.DialOption{grpc.WithInsecure()}, nil
+		}
+		return nil, errors.New("Either a client certificate or a bearer token is required")
+	}
+
+	tlsCfg := &tls.Config{}
+	if o.tlsConfig != nil {
+		tlsCfg = o.tlsConfig.Clone()
+	}
+
+	if o.certFile != "" {
+		idCert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{idCert}
+	}
+
+	if o.caFile != "" {
+		cert, err := ioutil.ReadFile(o.caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(cert) {
+			return nil, errors.New("Failed to append certificate")
+		}
+
+		tlsCfg.RootCAs = certPool
+	}
+
+	options := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg))}
+	if token != "" {
+		options = append(options, grpc.WithPerRPCCredentials(bearerToken(token)))
+	}
+	return options, nil
+}
+
+// Close closes the connection to the server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// requestContext applies the client's timeout (if any) to a request
+func (c *Client) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+func handle(id string) *api.TaskHandle {
+	return &api.TaskHandle{Id: id}
+}
+
+// Start starts a task on the server, returning its ID
+func (c *Client) Start(ctx context.Context, cmd Command) (string, error) {
+	request := &api.StartTaskRequest{
+		Binary:      cmd.Binary,
+		Args:        cmd.Args,
+		Environment: cmd.Env,
+		Labels:      cmd.Labels,
+	}
+	if cmd.Dir != "" {
+		request.WorkingDir = &cmd.Dir
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	response, err := c.api.StartTask(ctx, request)
+	if err != nil {
+		return "", newError(err)
+	}
+	return response.GetTaskId().GetId(), nil
+}
+
+// Query fetches the status of a task
+func (c *Client) Query(ctx context.Context, id string) (*Status, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	response, err := c.api.QueryTask(ctx, &api.QueryTaskRequest{TaskId: handle(id)})
+	if err != nil {
+		return nil, newError(err)
+	}
+	return newStatus(response), nil
+}
+
+func newStatus(response *api.QueryTaskResponse) *Status {
+	s := &Status{
+		State:    State(response.StatusCode.String()),
+		ExitCode: -1,
+		Labels:   response.Labels,
+	}
+	if response.ExitCode != nil {
+		s.ExitCode = int(*response.ExitCode)
+	}
+	if response.Signal != nil {
+		s.Signal = syscall.Signal(*response.Signal)
+	}
+	return s
+}
+
+// selector converts a Selector into its API equivalent
+func (sel Selector) selector() (*api.TaskSelector, error) {
+	result := &api.TaskSelector{
+		All:    sel.All,
+		Labels: sel.Labels,
+	}
+	for _, id := range sel.IDs {
+		result.TaskIds = append(result.TaskIds, handle(id))
+	}
+	for _, state := range sel.States {
+		code, ok := api.TaskStatusCode_value[string(state)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown state %q", state)
+		}
+		result.Statuses = append(result.Statuses, api.TaskStatusCode(code))
+	}
+	return result, nil
+}
+
+// QueryTasks fetches the status of every task matching a selector, ordered
+// by task ID. Tasks that were named by ID but couldn't be queried (e.g.
+// because they don't exist) are reported in their result's Err, rather
+// than failing the whole request.
+func (c *Client) QueryTasks(ctx context.Context, sel Selector) ([]TaskResult, error) {
+	selector, err := sel.selector()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	response, err := c.api.QueryTasks(ctx, &api.QueryTasksRequest{Selector: selector})
+	if err != nil {
+		return nil, newError(err)
+	}
+
+	results := make([]TaskResult, 0, len(response.Tasks))
+	for _, t := range response.Tasks {
+		result := TaskResult{TaskID: t.GetTaskId().GetId(), Err: newTaskError(t.Error)}
+		if t.Status != nil {
+			result.Status = newStatus(t.Status)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Wait waits for a task to be over, polling its status until it is, and
+// returns its final status. Fails if the context is cancelled first.
+func (c *Client) Wait(ctx context.Context, id string) (*Status, error) {
+	for {
+		s, err := c.Query(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if s.Done() {
+			return s, nil
+		}
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, newError(status.FromContextError(ctx.Err()).Err())
+		}
+	}
+}
+
+// Signal asks a task to quit. The server kills the task if it hasn't quit
+// within its grace period. Does not wait for the task to quit; use Wait
+// for that.
+func (c *Client) Signal(ctx context.Context, id string) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	_, err := c.api.SignalTask(ctx, &api.SignalTaskRequest{TaskId: handle(id)})
+	return newError(err)
+}
+
+// SignalTasks asks every task matching a selector to quit, in the same way
+// as Signal, and reports the outcome for each, ordered by task ID
+func (c *Client) SignalTasks(ctx context.Context, sel Selector) ([]TaskResult, error) {
+	selector, err := sel.selector()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	response, err := c.api.SignalTasks(ctx, &api.SignalTasksRequest{Selector: selector})
+	if err != nil {
+		return nil, newError(err)
+	}
+
+	results := make([]TaskResult, 0, len(response.Tasks))
+	for _, t := range response.Tasks {
+		results = append(results, TaskResult{TaskID: t.GetTaskId().GetId(), Err: newTaskError(t.Error)})
+	}
+	return results, nil
+}
+
+// Share grants other users, or groups of users, permission to act on a
+// task. Grants accumulate, so sharing a task again adds to what has already
+// been shared.
+func (c *Client) Share(ctx context.Context, id string, grant Grant) error {
+	request := &api.ShareTaskRequest{
+		TaskId: handle(id),
+		Users:  grant.Users,
+		Groups: grant.Groups,
+	}
+	for _, a := range grant.Actions {
+		action, ok := apiActions[a]
+		if !ok {
+			return fmt.Errorf("Unknown action %q", a)
+		}
+		request.Actions = append(request.Actions, action)
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	_, err := c.api.ShareTask(ctx, request)
+	return newError(err)
+}
+
+// Logs fetches the output that a task has written so far
+func (c *Client) Logs(ctx context.Context, id string) (*Output, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	response, err := c.api.FetchLogs(ctx, &api.FetchLogsRequest{TaskId: handle(id)})
+	if err != nil {
+		return nil, newError(err)
+	}
+	return &Output{Stdout: response.Stdout, Stderr: response.Stderr}, nil
+}
+
+// FollowLogs writes a task's output to the given writers as the task writes
+// it, starting with whatever it has written already, and returns once the
+// task is over and all of its output has been written. It is not subject
+// to the client's timeout.
+func (c *Client) FollowLogs(ctx context.Context, id string, stdout io.Writer, stderr io.Writer) error {
+	stream, err := c.api.FollowLogs(ctx, &api.FollowLogsRequest{TaskId: handle(id)})
+	if err != nil {
+		return newError(err)
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return newError(err)
+		}
+
+		if _, err := stdout.Write(msg.Stdout); err != nil {
+			return err
+		}
+		if _, err := stderr.Write(msg.Stderr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/authn"
+	"github.com/tcsc/levity/config"
+	"github.com/tcsc/levity/taskmanager"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// asAlice authenticates every request as alice
+type asAlice struct{}
+
+func (asAlice) Authenticate(context.Context) (*user.User, error) {
+	return user.New("alice"), nil
+}
+
+// startServer runs a task manager on a Unix socket, where the client needs
+// no credentials, and returns its address
+func startServer(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "levity.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(authn.UnaryServerInterceptor(asAlice{})),
+		grpc.StreamInterceptor(authn.StreamServerInterceptor(asAlice{})))
+	api.RegisterTaskManagerServer(srv, taskmanager.New())
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	return "unix://" + path
+}
+
+func newClient(t *testing.T, opts ...Option) *Client {
+	c, err := New(append([]Option{WithAddress(startServer(t)), WithTimeout(5 * time.Second)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_StartAndWait(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	uut := newClient(t)
+
+	// Given a task that writes to both of its output streams, and then fails
+	id, err := uut.Start(ctx, Command{
+		Binary: "sh",
+		Args:   []string{"-c", "echo $GREETING; echo err >&2; exit 3"},
+		Env:    map[string]string{"GREETING": "hello"},
+	})
+	require.NoError(err)
+	require.NotEmpty(id)
+
+	// When I wait for it to finish
+	status, err := uut.Wait(ctx, id)
+
+	// Expect its final status
+	require.NoError(err)
+	require.Equal(&Status{State: StateFinished, ExitCode: 3}, status)
+	require.True(status.Done())
+
+	// ... and for its output to be available
+	output, err := uut.Logs(ctx, id)
+	require.NoError(err)
+	require.Equal(&Output{Stdout: []byte("hello\n"), Stderr: []byte("err\n")}, output)
+}
+
+func TestClient_FollowLogs(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	uut := newClient(t)
+
+	// Given a task that writes output over a period of time
+	id, err := uut.Start(ctx, Command{
+		Binary: "sh",
+		Args:   []string{"-c", "for i in 1 2 3; do echo out $i; echo err $i >&2; sleep 0.1; done"},
+	})
+	require.NoError(err)
+
+	// When I follow its output
+	var stdout, stderr bytes.Buffer
+	require.NoError(uut.FollowLogs(ctx, id, &stdout, &stderr))
+
+	// Expect that all of its output is written, and that the task is over
+	// once it has been
+	require.Equal("out 1\nout 2\nout 3\n", stdout.String())
+	require.Equal("err 1\nerr 2\nerr 3\n", stderr.String())
+
+	status, err := uut.Query(ctx, id)
+	require.NoError(err)
+	require.True(status.Done())
+}
+
+func TestClient_Signal(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	uut := newClient(t)
+
+	// Given a task that runs until it's told to quit
+	id, err := uut.Start(ctx, Command{Binary: "sleep", Args: []string{"60"}})
+	require.NoError(err)
+
+	status, err := uut.Query(ctx, id)
+	require.NoError(err)
+	require.Equal(StateRunning, status.State)
+	require.False(status.Done())
+
+	// When I signal it
+	require.NoError(uut.Signal(ctx, id))
+
+	// Expect it to be killed by the signal
+	status, err = uut.Wait(ctx, id)
+	require.NoError(err)
+	require.Equal(&Status{State: StateFinished, ExitCode: -1, Signal: syscall.SIGTERM}, status)
+}
+
+func TestClient_Wait_Cancelled(t *testing.T) {
+	require := require.New(t)
+	uut := newClient(t)
+
+	id, err := uut.Start(context.Background(), Command{Binary: "sleep", Args: []string{"60"}})
+	require.NoError(err)
+	defer uut.Signal(context.Background(), id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = uut.Wait(ctx, id)
+	require.Equal(codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestClient_Share(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	uut := newClient(t)
+
+	id, err := uut.Start(ctx, Command{Binary: "true"})
+	require.NoError(err)
+
+	require.NoError(uut.Share(ctx, id, Grant{Users: []string{"bob"}, Actions: []Action{ActionQuery}}))
+	require.Error(uut.Share(ctx, id, Grant{Users: []string{"bob"}, Actions: []Action{"delete"}}))
+}
+
+func TestClient_QueryAndSignalTasks(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	uut := newClient(t)
+
+	// Given a couple of labelled tasks that run until they're told to quit
+	var ids []string
+	for i := 0; i < 2; i++ {
+		id, err := uut.Start(ctx, Command{
+			Binary: "sleep",
+			Args:   []string{"60"},
+			Labels: map[string]string{"job": "batch"},
+		})
+		require.NoError(err)
+		ids = append(ids, id)
+	}
+
+	// When I query them by ID, along with a task that doesn't exist
+	results, err := uut.QueryTasks(ctx, Selector{IDs: append(ids, "no-such-task")})
+	require.NoError(err)
+
+	// Expect the status of each, and an error for the missing one
+	require.Len(results, 3)
+	for _, r := range results {
+		if r.TaskID == "no-such-task" {
+			require.True(errors.Is(r.Err, ErrNoSuchTask))
+			require.Equal(codes.NotFound, status.Code(r.Err))
+			require.Nil(r.Status)
+			continue
+		}
+		require.NoError(r.Err)
+		require.Equal(StateRunning, r.Status.State)
+		require.Equal(map[string]string{"job": "batch"}, r.Status.Labels)
+	}
+
+	// When I signal every running task with their label
+	results, err = uut.SignalTasks(ctx, Selector{
+		All:    true,
+		Labels: map[string]string{"job": "batch"},
+		States: []State{StateRunning},
+	})
+	require.NoError(err)
+
+	// Expect both to have been signalled, and to quit
+	require.Len(results, 2)
+	for _, r := range results {
+		require.Contains(ids, r.TaskID)
+		require.NoError(r.Err)
+
+		status, err := uut.Wait(ctx, r.TaskID)
+		require.NoError(err)
+		require.Equal(syscall.SIGTERM, status.Signal)
+	}
+}
+
+func TestClient_SelectorErrors(t *testing.T) {
+	require := require.New(t)
+	uut := newClient(t)
+
+	// Expect that a selector that selects nothing is rejected by the server
+	_, err := uut.QueryTasks(context.Background(), Selector{Labels: map[string]string{"job": "batch"}})
+	require.True(errors.Is(err, ErrInvalidRequest))
+
+	// ... and that unknown states are rejected before anything is sent
+	_, err = uut.SignalTasks(context.Background(), Selector{All: true, States: []State{"Sleeping"}})
+	require.Error(err)
+}
+
+func TestClient_NoSuchTask(t *testing.T) {
+	require := require.New(t)
+	uut := newClient(t)
+
+	// When I query a task that doesn't exist
+	_, err := uut.Query(context.Background(), "no-such-task")
+
+	// Expect an error that can be told apart from others
+	require.True(errors.Is(err, ErrNoSuchTask))
+	require.False(errors.Is(err, ErrAccessDenied))
+
+	var clientErr *Error
+	require.True(errors.As(err, &clientErr))
+	require.Equal(codes.NotFound, clientErr.Code)
+	require.Equal(ReasonNoSuchTask, clientErr.Reason)
+	require.Equal("no-such-task", clientErr.Metadata["task_id"])
+
+	// ... and that still looks like a GRPC error
+	require.Equal(codes.NotFound, status.Code(err))
+}
+
+func TestClient_Unavailable(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server that isn't there
+	uut, err := New(
+		WithAddress("unix://"+filepath.Join(t.TempDir(), "nobody-home.sock")),
+		WithRetries(1),
+		WithTimeout(5*time.Second))
+	require.NoError(err)
+	defer uut.Close()
+
+	// Expect requests to fail as unavailable
+	_, err = uut.Query(context.Background(), "1234")
+	require.True(errors.Is(err, ErrUnavailable))
+
+	_, err = uut.Start(context.Background(), Command{Binary: "true"})
+	require.True(errors.Is(err, ErrUnavailable))
+}
+
+func TestNew_Errors(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "no address", opts: nil},
+		{name: "no credentials", opts: []Option{WithAddress("localhost:1234")}},
+		{name: "certificate without key", opts: []Option{
+			WithAddress("localhost:1234"), WithCertificate("../cert/alice-cert.pem", "")}},
+		{name: "missing certificate", opts: []Option{
+			WithAddress("localhost:1234"), WithCertificate("no-such-cert.pem", "no-such-key.pem")}},
+		{name: "missing token file", opts: []Option{
+			WithAddress("localhost:1234"), WithTokenFile("no-such-token")}},
+		{name: "negative retries", opts: []Option{
+			WithAddress("unix:///tmp/levity.sock"), WithRetries(-1)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.opts...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNew_Credentials(t *testing.T) {
+	// Expect that a client can be created with a certificate, or a token,
+	// without connecting to the server
+	c, err := New(
+		WithAddress("localhost:1234"),
+		WithCertificate("../cert/alice-cert.pem", "../cert/alice-key.pem"),
+		WithCA("../cert/svr-ca-cert.pem"))
+	require.NoError(t, err)
+	c.Close()
+
+	c, err = New(WithAddress("localhost:1234"), WithToken("secret"))
+	require.NoError(t, err)
+	c.Close()
+}
+
+func TestWithProfile(t *testing.T) {
+	require := require.New(t)
+
+	// Given options that have already been set
+	o := options{address: "localhost:1234", caFile: "ca.pem", timeout: time.Second}
+
+	// When I apply a profile that sets some of them
+	WithProfile(&config.Profile{
+		Address:     "levity.example.com:4321",
+		Certificate: "alice-cert.pem",
+		Key:         "alice-key.pem",
+		Timeout:     10 * time.Second,
+	})(&o)
+
+	// Expect that only the settings in the profile are replaced
+	require.Equal(options{
+		address:  "levity.example.com:4321",
+		certFile: "alice-cert.pem",
+		keyFile:  "alice-key.pem",
+		caFile:   "ca.pem",
+		timeout:  10 * time.Second,
+	}, o)
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"github.com/tcsc/levity/api"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The reasons the server gives for rejecting a request, which are the same
+// as those reported by the taskmanager package
+const (
+	ReasonNoSuchTask        = "NO_SUCH_TASK"
+	ReasonAccessDenied      = "ACCESS_DENIED"
+	ReasonInvalidState      = "INVALID_STATE"
+	ReasonCommandDenied     = "COMMAND_DENIED"
+	ReasonEnvironmentDenied = "ENVIRONMENT_DENIED"
+	ReasonStartFailed       = "START_FAILED"
+	ReasonInvalidRequest    = "INVALID_REQUEST"
+	ReasonShuttingDown      = "SHUTTING_DOWN"
+	ReasonTooManyTasks      = "TOO_MANY_TASKS"
+)
+
+// Error describes a failed request, either because the server rejected it,
+// or because it could not be made at all (e.g. the server was unreachable).
+// Errors can be matched against the Err* values with errors.Is, e.g.
+//
+//	if errors.Is(err, client.ErrNoSuchTask) {
+//	    ...
+//	}
+type Error struct {
+	// Code is the GRPC status code the request failed with
+	Code codes.Code
+
+	// Reason is one of the Reason* values if the server said why it
+	// rejected the request, and empty otherwise
+	Reason string
+
+	// Message describes the error in human-readable terms
+	Message string
+
+	// Metadata holds any further details the server gave about the error,
+	// e.g. the ID of the task that doesn't exist
+	Metadata map[string]string
+
+	status *status.Status
+}
+
+// Errors that may be matched with errors.Is. Those with a reason match any
+// Error with the same reason; the rest match any Error with the same code.
+var (
+	ErrNoSuchTask        = &Error{Code: codes.NotFound, Reason: ReasonNoSuchTask, Message: "no such task"}
+	ErrAccessDenied      = &Error{Code: codes.PermissionDenied, Reason: ReasonAccessDenied, Message: "access denied"}
+	ErrInvalidState      = &Error{Code: codes.FailedPrecondition, Reason: ReasonInvalidState, Message: "task in invalid state"}
+	ErrCommandDenied     = &Error{Code: codes.PermissionDenied, Reason: ReasonCommandDenied, Message: "command denied"}
+	ErrEnvironmentDenied = &Error{Code: codes.PermissionDenied, Reason: ReasonEnvironmentDenied, Message: "environment denied"}
+	ErrStartFailed       = &Error{Code: codes.InvalidArgument, Reason: ReasonStartFailed, Message: "task failed to start"}
+	ErrInvalidRequest    = &Error{Code: codes.InvalidArgument, Reason: ReasonInvalidRequest, Message: "invalid request"}
+	ErrTooManyTasks      = &Error{Code: codes.ResourceExhausted, Reason: ReasonTooManyTasks, Message: "too many tasks"}
+	ErrShuttingDown      = &Error{Code: codes.Unavailable, Reason: ReasonShuttingDown, Message: "server shutting down"}
+	ErrUnavailable       = &Error{Code: codes.Unavailable, Message: "server unavailable"}
+	ErrUnauthenticated   = &Error{Code: codes.Unauthenticated, Message: "unauthenticated"}
+)
+
+func (e *Error) Error() string {
+	if e.status != nil {
+		return e.status.Err().Error()
+	}
+	return e.Message
+}
+
+// GRPCStatus reports the GRPC status the request failed with, so that the
+// status package's functions work on an Error as they would on the original
+// GRPC error
+func (e *Error) GRPCStatus() *status.Status {
+	if e.status != nil {
+		return e.status
+	}
+	return status.New(e.Code, e.Message)
+}
+
+// Is matches an Error against one of the Err* values
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if t.Reason != "" {
+		return e.Reason == t.Reason
+	}
+	return e.Code == t.Code
+}
+
+// newError converts an error returned by GRPC into an Error. Anything that
+// isn't a GRPC error is returned as it is.
+func newError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	e := &Error{
+		Code:    st.Code(),
+		Message: st.Message(),
+		status:  st,
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			e.Reason = info.Reason
+			e.Metadata = info.Metadata
+		}
+	}
+	return e
+}
+
+// newTaskError converts the error reported for a single task in a batch
+// request into an Error, in the same form as if the task had been the
+// subject of a request of its own
+func newTaskError(e *api.TaskError) error {
+	if e == nil {
+		return nil
+	}
+
+	st := status.New(codes.Code(e.Code), e.Message)
+	if e.Reason != "" {
+		detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+			Reason:   e.Reason,
+			Metadata: e.Metadata,
+		})
+		if err == nil {
+			st = detailed
+		}
+	}
+	return newError(st.Err())
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/taskmanager"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewError(t *testing.T) {
+	require := require.New(t)
+
+	st, err := status.New(codes.PermissionDenied, "Access denied").WithDetails(
+		&errdetails.ErrorInfo{
+			Reason:   ReasonAccessDenied,
+			Domain:   taskmanager.ErrorDomain,
+			Metadata: map[string]string{"task_id": "1234", "action": "signal"},
+		})
+	require.NoError(err)
+
+	// Given a GRPC error with details attached
+	uut := newError(st.Err())
+
+	// Expect the details to be unpacked
+	var e *Error
+	require.True(errors.As(uut, &e))
+	require.Equal(codes.PermissionDenied, e.Code)
+	require.Equal(ReasonAccessDenied, e.Reason)
+	require.Equal("Access denied", e.Message)
+	require.Equal(map[string]string{"task_id": "1234", "action": "signal"}, e.Metadata)
+
+	// ... that it matches errors with the same reason, but not just the same
+	// code
+	require.True(errors.Is(uut, ErrAccessDenied))
+	require.False(errors.Is(uut, ErrCommandDenied))
+
+	// ... and that the original error is still available
+	require.Equal(st.Err().Error(), uut.Error())
+	require.Equal(st.Proto(), status.Convert(uut).Proto())
+}
+
+func TestNewError_NoDetails(t *testing.T) {
+	require := require.New(t)
+
+	uut := newError(status.Error(codes.Unavailable, "connection refused"))
+	require.True(errors.Is(uut, ErrUnavailable))
+	require.False(errors.Is(uut, ErrShuttingDown))
+
+	require.Nil(newError(nil))
+
+	plain := errors.New("not a GRPC error")
+	require.Equal(plain, newError(plain))
+}
+
+func TestNewTaskError(t *testing.T) {
+	require := require.New(t)
+
+	// Given the error reported for a single task in a batch response
+	uut := newTaskError(&api.TaskError{
+		Code:     int32(codes.FailedPrecondition),
+		Message:  "Task 1234 is in the wrong state for signal",
+		Reason:   ReasonInvalidState,
+		Metadata: map[string]string{"task_id": "1234"},
+	})
+
+	// Expect it to look the same as the error for a single-task request
+	var e *Error
+	require.True(errors.As(uut, &e))
+	require.True(errors.Is(uut, ErrInvalidState))
+	require.Equal(codes.FailedPrecondition, status.Code(uut))
+	require.Equal("1234", e.Metadata["task_id"])
+
+	var info *errdetails.ErrorInfo
+	for _, detail := range status.Convert(uut).Details() {
+		info, _ = detail.(*errdetails.ErrorInfo)
+	}
+	require.NotNil(info)
+	require.Equal(ReasonInvalidState, info.Reason)
+
+	require.Nil(newTaskError(nil))
+}
+
+func TestReasons(t *testing.T) {
+	// Expect the reasons to match those the server gives
+	require := require.New(t)
+	require.Equal(taskmanager.ReasonNoSuchTask, ReasonNoSuchTask)
+	require.Equal(taskmanager.ReasonAccessDenied, ReasonAccessDenied)
+	require.Equal(taskmanager.ReasonInvalidState, ReasonInvalidState)
+	require.Equal(taskmanager.ReasonCommandDenied, ReasonCommandDenied)
+	require.Equal(taskmanager.ReasonEnvironmentDenied, ReasonEnvironmentDenied)
+	require.Equal(taskmanager.ReasonStartFailed, ReasonStartFailed)
+	require.Equal(taskmanager.ReasonInvalidRequest, ReasonInvalidRequest)
+	require.Equal(taskmanager.ReasonShuttingDown, ReasonShuttingDown)
+	require.Equal(taskmanager.ReasonTooManyTasks, ReasonTooManyTasks)
+}
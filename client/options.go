@@ -0,0 +1,134 @@
+package client
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/tcsc/levity/config"
+	"google.golang.org/grpc"
+)
+
+// DefaultRetries is the number of times requests that are safe to repeat are
+// retried by default, if the server can't be reached
+const DefaultRetries = 3
+
+// options collects the settings for a Client. Credentials are only loaded
+// once all of the options have been applied.
+type options struct {
+	address     string
+	certFile    string
+	keyFile     string
+	caFile      string
+	token       string
+	tokenFile   string
+	tlsConfig   *tls.Config
+	timeout     time.Duration
+	retries     int
+	dialOptions []grpc.DialOption
+}
+
+// Option sets up a Client
+type Option func(*options)
+
+// WithAddress sets the address of the server, either as a `host:port` or as
+// `unix:///PATH` for a Unix domain socket
+func WithAddress(address string) Option {
+	return func(o *options) {
+		o.address = address
+	}
+}
+
+// WithCertificate identifies the client with the certificate and private
+// key in the given PEM files
+func WithCertificate(certFile string, keyFile string) Option {
+	return func(o *options) {
+		o.certFile = certFile
+		o.keyFile = keyFile
+	}
+}
+
+// WithCA verifies the server's certificate against the CA certificate in
+// the given PEM file, rather than the system's CAs
+func WithCA(caFile string) Option {
+	return func(o *options) {
+		o.caFile = caFile
+	}
+}
+
+// WithToken authenticates the client with a bearer token, for servers that
+// accept tokens in lieu of client certificates
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithTokenFile authenticates the client with the bearer token in the given
+// file. Takes precedence over WithToken.
+func WithTokenFile(path string) Option {
+	return func(o *options) {
+		o.tokenFile = path
+	}
+}
+
+// WithTLSConfig supplies the TLS configuration that the client's certificate
+// and CA are added to, e.g. to restrict the TLS versions used. The config is
+// copied, rather than modified.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithTimeout limits how long each request may take, including any retries.
+// Following a task's output (e.g. with FollowLogs) is not subject to the
+// timeout, as it lasts as long as the task does. By default requests are
+// limited only by the context they are made with.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetries sets how many times requests that are safe to repeat (i.e.
+// Query and Logs) are retried if the server can't be reached. Defaults to
+// DefaultRetries; zero turns retrying off.
+func WithRetries(retries int) Option {
+	return func(o *options) {
+		o.retries = retries
+	}
+}
+
+// WithProfile applies the settings from a server profile in the client's
+// configuration file (see config.LoadClient). Settings that the profile
+// leaves empty are left as they are.
+func WithProfile(p *config.Profile) Option {
+	return func(o *options) {
+		if p.Address != "" {
+			o.address = p.Address
+		}
+		if p.Certificate != "" {
+			o.certFile = p.Certificate
+		}
+		if p.Key != "" {
+			o.keyFile = p.Key
+		}
+		if p.CA != "" {
+			o.caFile = p.CA
+		}
+		if p.TokenFile != "" {
+			o.tokenFile = p.TokenFile
+		}
+		if p.Timeout > 0 {
+			o.timeout = p.Timeout
+		}
+	}
+}
+
+// WithDialOptions passes extra options on to grpc.Dial, e.g. to install
+// additional interceptors
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+// idempotentMethods lists the requests that may safely be repeated if they
+// fail, because they don't change anything on the server. Anything else
+// might be repeated after the server has already acted on it (e.g. starting
+// the same task twice).
+var idempotentMethods = map[string]bool{
+	"/levity.TaskManager/QueryTask":  true,
+	"/levity.TaskManager/QueryTasks": true,
+	"/levity.TaskManager/FetchLogs":  true,
+}
+
+// retryPolicy describes how failed requests are retried. It mirrors the
+// retry policy from the GRPC service config, which the GRPC version we use
+// only honours if GRPC_GO_RETRY=on is set in the environment.
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	jitter         float64
+}
+
+func newRetryPolicy(maxRetries int) retryPolicy {
+	return retryPolicy{
+		maxRetries:     maxRetries,
+		initialBackoff: 200 * time.Millisecond,
+		maxBackoff:     2 * time.Second,
+		multiplier:     2,
+		jitter:         0.2,
+	}
+}
+
+// backoff calculates how long to wait before the given retry (counting from
+// zero), randomised so that clients that fail together don't all retry at
+// the same moment
+func (p retryPolicy) backoff(retry int) time.Duration {
+	delay := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(retry))
+	if delay > float64(p.maxBackoff) {
+		delay = float64(p.maxBackoff)
+	}
+	delay *= 1 + p.jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+// unaryInterceptor retries idempotent requests that fail because the server
+// is unavailable, backing off between attempts. It gives up early if the
+// request's deadline would expire before the next attempt.
+func (p retryPolicy) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption) error {
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if !idempotentMethods[method] {
+			return err
+		}
+
+		for retry := 0; retry < p.maxRetries && status.Code(err) == codes.Unavailable; retry++ {
+			delay := p.backoff(retry)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+				break
+			}
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return err
+			}
+
+			err = invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return err
+	}
+}
+
+// connectionOptions configures how the client connects to the server, and
+// keeps the connection alive. The server's keepalive enforcement policy
+// has to allow pings at least this often.
+func connectionOptions(policy retryPolicy) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(policy.unaryInterceptor()),
+
+		// NB: Otherwise, setting GRPC_GO_RETRY would have GRPC retrying on
+		//     top of our own retries
+		grpc.WithDisableRetry(),
+
+		// Reconnect quickly enough that a retried request has a chance of
+		// finding the connection back up
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  100 * time.Millisecond,
+				Multiplier: 1.6,
+				Jitter:     0.2,
+				MaxDelay:   policy.maxBackoff,
+			},
+			MinConnectTimeout: 5 * time.Second,
+		}),
+
+		// Detect a dead connection during a long-running request (e.g. `run`)
+		// rather than waiting forever for a response that will never come
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    30 * time.Second,
+			Timeout: 10 * time.Second,
+		}),
+	}
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	methodQuery      = "/levity.TaskManager/QueryTask"
+	methodQueryTasks = "/levity.TaskManager/QueryTasks"
+	methodStart      = "/levity.TaskManager/StartTask"
+)
+
+// failingInvoker fails with each of the given errors in turn, and then
+// succeeds, counting the calls made to it
+type failingInvoker struct {
+	errs  []error
+	calls int
+}
+
+func (f *failingInvoker) invoke(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+	f.calls++
+	if f.calls > len(f.errs) {
+		return nil
+	}
+	return f.errs[f.calls-1]
+}
+
+func fastRetryPolicy(maxRetries int) retryPolicy {
+	p := newRetryPolicy(maxRetries)
+	p.initialBackoff = time.Millisecond
+	p.maxBackoff = 5 * time.Millisecond
+	return p
+}
+
+func TestRetry_Unavailable(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	for _, method := range []string{methodQuery, methodQueryTasks} {
+		t.Run(method, func(t *testing.T) {
+			require := require.New(t)
+
+			// Given a server that is unavailable for a couple of attempts
+			invoker := &failingInvoker{errs: []error{unavailable, unavailable}}
+
+			// When I make an idempotent request
+			uut := fastRetryPolicy(3).unaryInterceptor()
+			err := uut(context.Background(), method, nil, nil, nil, invoker.invoke)
+
+			// Expect that it is retried until it succeeds
+			require.NoError(err)
+			require.Equal(3, invoker.calls)
+		})
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	require := require.New(t)
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	// Given a server that stays unavailable
+	invoker := &failingInvoker{errs: []error{unavailable, unavailable, unavailable, unavailable}}
+
+	// When I make an idempotent request
+	uut := fastRetryPolicy(2).unaryInterceptor()
+	err := uut(context.Background(), methodQuery, nil, nil, nil, invoker.invoke)
+
+	// Expect that it is retried only as many times as allowed
+	require.Equal(codes.Unavailable, status.Code(err))
+	require.Equal(3, invoker.calls)
+}
+
+func TestRetry_OnlyIdempotentRequestsOnUnavailable(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		err    error
+	}{
+		{name: "start", method: methodStart, err: status.Error(codes.Unavailable, "down")},
+		{name: "not found", method: methodQuery, err: status.Error(codes.NotFound, "gone")},
+		{name: "deadline", method: methodQuery, err: status.Error(codes.DeadlineExceeded, "slow")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			invoker := &failingInvoker{errs: []error{tc.err}}
+			uut := fastRetryPolicy(3).unaryInterceptor()
+			err := uut(context.Background(), tc.method, nil, nil, nil, invoker.invoke)
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, 1, invoker.calls)
+		})
+	}
+}
+
+func TestRetry_RespectsDeadline(t *testing.T) {
+	require := require.New(t)
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	// Given a server that is unavailable, and a request that will time out
+	// before the first retry
+	invoker := &failingInvoker{errs: []error{unavailable}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// When I make an idempotent request
+	uut := newRetryPolicy(3).unaryInterceptor()
+	err := uut(ctx, methodQuery, nil, nil, nil, invoker.invoke)
+
+	// Expect that it fails straight away, rather than waiting out the
+	// deadline
+	require.Equal(unavailable, err)
+	require.Equal(1, invoker.calls)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	uut := newRetryPolicy(10)
+
+	for retry, expected := range []time.Duration{
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		1600 * time.Millisecond,
+		2 * time.Second,
+		2 * time.Second,
+	} {
+		delay := uut.backoff(retry)
+		require.InDelta(t, float64(expected), float64(delay), float64(expected)*uut.jitter)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Exit codes for failures, so that scripts can tell (say) a task that no
+// longer exists from a server that's unreachable. These follow sysexits(3),
+// keeping them clear of the low codes that commands usually exit with, so
+// that `run` can pass a task's own exit code through.
+const (
+	exitUsage              = 64 // EX_USAGE: The command line or client settings are wrong
+	exitInvalidArgument    = 65 // EX_DATAERR: The request was malformed, or the command could not be run
+	exitNotFound           = 66 // EX_NOINPUT: The task does not exist
+	exitUnauthenticated    = 67 // EX_NOUSER: The server did not accept our credentials
+	exitUnavailable        = 69 // EX_UNAVAILABLE: The server could not be reached, or timed out
+	exitFailure            = 70 // EX_SOFTWARE: Anything not covered here
+	exitFailedPrecondition = 75 // EX_TEMPFAIL: The task is in the wrong state for the request
+	exitPermissionDenied   = 77 // EX_NOPERM: We are not allowed to do that
+)
+
+var exitCodes = map[codes.Code]int{
+	codes.Unavailable:        exitUnavailable,
+	codes.DeadlineExceeded:   exitUnavailable,
+	codes.Unauthenticated:    exitUnauthenticated,
+	codes.PermissionDenied:   exitPermissionDenied,
+	codes.NotFound:           exitNotFound,
+	codes.InvalidArgument:    exitInvalidArgument,
+	codes.FailedPrecondition: exitFailedPrecondition,
+}
+
+// exitCodeFor maps a GRPC error onto the exit code for its category
+func exitCodeFor(err error) int {
+	if code, ok := exitCodes[status.Code(err)]; ok {
+		return code
+	}
+	return exitFailure
+}
+
+// describeDetails formats any structured error details attached to a GRPC
+// error, e.g. "reason=NO_SUCH_TASK task_id=1234"
+func describeDetails(err error) string {
+	var parts []string
+	for _, detail := range status.Convert(err).Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok {
+			continue
+		}
+
+		parts = append(parts, "reason="+info.Reason)
+		keys := make([]string, 0, len(info.Metadata))
+		for k := range info.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			parts = append(parts, k+"="+info.Metadata[k])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// requestFailed reports a failed GRPC request and exits with the exit code
+// appropriate to the failure
+func requestFailed(err error) {
+	log.Printf("GRPC request failed: %v", err)
+	if details := describeDetails(err); details != "" {
+		log.Printf("Error details: %s", details)
+	}
+	if outputFormat != formatText {
+		writeDocument(os.Stdout, newErrorDocument(err))
+	}
+	finishTracing(err)
+	os.Exit(exitCodeFor(err))
+}
+
+// setupFailed reports a problem with the command line or the client's
+// settings, found before any request was made, and exits
+func setupFailed(format string, args ...interface{}) {
+	err := fmt.Errorf(format, args...)
+	log.Print(err)
+	finishTracing(err)
+	os.Exit(exitUsage)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	require := require.New(t)
+	require.Equal(exitNotFound, exitCodeFor(status.Error(codes.NotFound, "gone")))
+	require.Equal(exitUnavailable, exitCodeFor(status.Error(codes.Unavailable, "down")))
+	require.Equal(exitUnavailable, exitCodeFor(status.Error(codes.DeadlineExceeded, "slow")))
+	require.Equal(exitFailure, exitCodeFor(status.Error(codes.Internal, "oops")))
+	require.Equal(exitFailure, exitCodeFor(errors.New("not a GRPC error")))
+}
+
+func TestExitCodes_ClearOfTaskExitCodes(t *testing.T) {
+	// Expect that the client's own exit codes can be told apart from those
+	// that `run` passes through from a task, which are usually small, or 128
+	// plus a signal number
+	codes := []int{exitUsage, exitFailure}
+	for _, code := range exitCodes {
+		codes = append(codes, code)
+	}
+	for _, code := range codes {
+		require.True(t, code >= 64 && code < 128, "exit code %d", code)
+	}
+}
+
+func TestDescribeDetails(t *testing.T) {
+	require := require.New(t)
+
+	st, err := status.New(codes.NotFound, "No such task: 1234").WithDetails(
+		&errdetails.ErrorInfo{
+			Reason:   "NO_SUCH_TASK",
+			Domain:   "levity",
+			Metadata: map[string]string{"task_id": "1234", "action": "query"},
+		})
+	require.NoError(err)
+
+	require.Equal("reason=NO_SUCH_TASK action=query task_id=1234", describeDetails(st.Err()))
+	require.Empty(describeDetails(status.Error(codes.NotFound, "No details")))
+}
@@ -1,12 +1,9 @@
 package main
 
 import (
-	"context"
-	"log"
 	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/tcsc/levity/api"
 )
 
 var (
@@ -20,21 +17,19 @@ var (
 )
 
 func fetchLogs(cmd *cobra.Command, args []string) {
-	conn, client, err := makeClient()
+	c, err := makeClient()
 	if err != nil {
-		log.Fatalf("Failed to create GRPC client: %v", err)
+		setupFailed("Failed to create GRPC client: %v", err)
 	}
-	defer conn.Close()
+	defer c.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	request := &api.FetchLogsRequest{TaskId: &api.TaskHandle{Id: args[0]}}
-	response, err := client.FetchLogs(ctx, request)
+	output, err := c.Logs(commandCtx, args[0])
 	if err != nil {
-		log.Fatalf("GRPC request failed: %v", err)
+		requestFailed(err)
 	}
 
-	os.Stdout.Write(response.Stdout)
-	os.Stderr.Write(response.Stderr)
+	printResult(newLogsDocument(args[0], output), func() {
+		os.Stdout.Write(output.Stdout)
+		os.Stderr.Write(output.Stderr)
+	})
 }
@@ -2,22 +2,26 @@ package main
 
 import (
 	"crypto/tls"
-	"crypto/x509"
 	"errors"
-	"io/ioutil"
 	"os"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/tcsc/levity/api"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"github.com/tcsc/levity/client"
 )
 
 const (
-	argAddress        = "address"
-	argClientCert     = "certificate"
-	argClientKey      = "key"
+	argAddress    = "address"
+	argTimeout    = "timeout"
+	argCA         = "ca"
+	argClientCert = "certificate"
+	argClientKey  = "key"
+	argTokenFile  = "token-file"
+	argRetries    = "retries"
+
+	// envToken names an environment variable that may hold a bearer token,
+	// as an alternative to --token-file
+	envToken          = "LEVITY_TOKEN"
 	argUseObsoleteTLS = "use-obsolete-tls"
 )
 
@@ -33,6 +37,8 @@ var (
 	caCertPath     string
 	idPrivateKey   string
 	idUserCert     string
+	tokenFile      string
+	retries        = client.DefaultRetries
 	useObsoleteTLS bool
 )
 
@@ -40,12 +46,12 @@ func init() {
 	flags := rootCmd.PersistentFlags()
 
 	flags.StringVarP(&serverAddress, argAddress, "a", "",
-		"The server address and port")
+		"The server address and port, or unix:///PATH for a Unix socket")
 
-	flags.DurationVarP(&timeout, "timeout", "t", timeout,
+	flags.DurationVarP(&timeout, argTimeout, "t", timeout,
 		"Timeout for GRPC requests")
 
-	flags.StringVar(&caCertPath, "ca", "", "Override system CA with provided CA")
+	flags.StringVar(&caCertPath, argCA, "", "Override system CA with provided CA")
 
 	flags.StringVarP(&idPrivateKey, argClientKey, "k", "",
 		"Path to TLS identity private key")
@@ -53,11 +59,13 @@ func init() {
 	flags.StringVarP(&idUserCert, argClientCert, "c", "",
 		"Path to TLS identity certificate")
 
-	for _, arg := range []string{argAddress, argClientCert, argClientKey} {
-		if err := rootCmd.MarkPersistentFlagRequired(arg); err != nil {
-			panic(err)
-		}
-	}
+	flags.StringVar(&tokenFile, argTokenFile, "",
+		"Authenticate with the bearer token in the given file, instead of a "+
+			"client certificate (default: the token in $"+envToken+", if set)")
+
+	flags.IntVar(&retries, argRetries, retries,
+		"Retry requests that are safe to repeat (query, including batch queries, and logs) "+
+			"up to this many times if the server can't be reached")
 
 	// Useful for testing, but should not be advertised to the user
 	flags.BoolVar(&useObsoleteTLS, argUseObsoleteTLS, false,
@@ -66,59 +74,49 @@ func init() {
 		panic(err)
 	}
 
-	rootCmd.AddCommand(&cmdStart, &cmdFetchLogs, &cmdQuery, &cmdSignal)
+	// Connection settings may come from a profile rather than the command
+	// line, so they have to be filled in before anything else happens
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
+		if err := applyProfile(cmd); err != nil {
+			return err
+		}
+		return startTracing(cmd, args)
+	}
+
+	rootCmd.AddCommand(&cmdStart, &cmdRun, &cmdFetchLogs, &cmdQuery, &cmdSignal, &cmdShare)
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
-func makeCredentials() (grpc.DialOption, error) {
-	idCert, err := tls.LoadX509KeyPair(idUserCert, idPrivateKey)
-	if err != nil {
-		return nil, err
+func makeClient() (*client.Client, error) {
+	if serverAddress == "" {
+		return nil, errors.New("No server address: give one with --" + argAddress + ", or in a profile")
 	}
 
-	tlsCfg := &tls.Config{
-		Certificates: []tls.Certificate{idCert},
+	options := []client.Option{
+		client.WithAddress(serverAddress),
+		client.WithCertificate(idUserCert, idPrivateKey),
+		client.WithCA(caCertPath),
+		client.WithTimeout(timeout),
+		client.WithRetries(retries),
 	}
 
-	if caCertPath != "" {
-		cert, err := ioutil.ReadFile(caCertPath)
-		if err != nil {
-			return nil, err
-		}
-
-		certPool := x509.NewCertPool()
-		if !certPool.AppendCertsFromPEM(cert) {
-			return nil, errors.New("Failed to append certificate")
-		}
-
-		tlsCfg.RootCAs = certPool
+	if tokenFile != "" {
+		options = append(options, client.WithTokenFile(tokenFile))
+	} else {
+		options = append(options, client.WithToken(os.Getenv(envToken)))
 	}
 
 	if useObsoleteTLS {
-		tlsCfg.MaxVersion = tls.VersionTLS12
-	}
-
-	creds := grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg))
-	return creds, nil
-}
-
-func makeClient() (*grpc.ClientConn, api.TaskManagerClient, error) {
-	creds, err := makeCredentials()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	conn, err := grpc.Dial(
-		serverAddress,
-		creds)
-	if err != nil {
-		return nil, nil, err
+		options = append(options, client.WithTLSConfig(&tls.Config{MaxVersion: tls.VersionTLS12}))
 	}
 
-	return conn, api.NewTaskManagerClient(conn), nil
+	return client.New(options...)
 }
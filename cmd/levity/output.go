@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tcsc/levity/client"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	argOutput = "output"
+
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+var outputFormat = formatText
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, argOutput, "o", formatText,
+		"Output format, one of text, json or yaml")
+}
+
+// checkOutputFormat rejects an unknown --output format before any request is
+// made, rather than after the request has taken effect
+func checkOutputFormat() error {
+	switch outputFormat {
+	case formatText, formatJSON, formatYAML:
+		return nil
+	}
+	return fmt.Errorf("Unknown output format %q (expected one of text, json or yaml)", outputFormat)
+}
+
+// The documents written by each command when --output is json or yaml. These
+// are part of the client's interface (see the README), so fields may be added
+// but must never be renamed or removed.
+
+// taskDocument is written by `start` and `signal`
+type taskDocument struct {
+	TaskID string `json:"task_id" yaml:"task_id"`
+}
+
+// statusDocument is written by `query`. The exit code is null until the task
+// has finished, and the signal is null unless the task was killed by one.
+// The labels are left out if the task has none.
+type statusDocument struct {
+	TaskID   string            `json:"task_id" yaml:"task_id"`
+	Status   string            `json:"status" yaml:"status"`
+	ExitCode *int32            `json:"exit_code" yaml:"exit_code"`
+	Signal   *int32            `json:"signal" yaml:"signal"`
+	Labels   map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// logsDocument is written by `logs`. Any bytes in the output that aren't valid
+// UTF-8 are replaced with U+FFFD, as neither JSON nor YAML can carry them.
+type logsDocument struct {
+	TaskID      string `json:"task_id" yaml:"task_id"`
+	StdoutBytes int    `json:"stdout_bytes" yaml:"stdout_bytes"`
+	StderrBytes int    `json:"stderr_bytes" yaml:"stderr_bytes"`
+	Stdout      string `json:"stdout" yaml:"stdout"`
+	Stderr      string `json:"stderr" yaml:"stderr"`
+}
+
+// shareDocument is written by `share`
+type shareDocument struct {
+	TaskID  string   `json:"task_id" yaml:"task_id"`
+	Users   []string `json:"users" yaml:"users"`
+	Groups  []string `json:"groups" yaml:"groups"`
+	Actions []string `json:"actions" yaml:"actions"`
+}
+
+// errorDocument is written by any command whose request fails, in place of
+// the document it would otherwise have written
+type errorDocument struct {
+	Error errorDetail `json:"error" yaml:"error"`
+}
+
+// tasksDocument is written by `query` and `signal` when they act on several
+// tasks at once. Each task is described by the document the command would
+// write for that task alone, or by a taskErrorDocument if it couldn't be
+// acted on.
+type tasksDocument struct {
+	Tasks []interface{} `json:"tasks" yaml:"tasks"`
+}
+
+// taskErrorDocument describes a task in a batch that couldn't be acted on
+type taskErrorDocument struct {
+	TaskID string      `json:"task_id" yaml:"task_id"`
+	Error  errorDetail `json:"error" yaml:"error"`
+}
+
+type errorDetail struct {
+	Code     string            `json:"code" yaml:"code"`
+	Message  string            `json:"message" yaml:"message"`
+	Reason   string            `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+func newStatusDocument(id string, status *client.Status) statusDocument {
+	doc := statusDocument{
+		TaskID: id,
+		Status: string(status.State),
+	}
+	if status.State == client.StateFinished {
+		exitCode := int32(status.ExitCode)
+		doc.ExitCode = &exitCode
+	}
+	if status.Signal != 0 {
+		signal := int32(status.Signal)
+		doc.Signal = &signal
+	}
+	if len(status.Labels) > 0 {
+		doc.Labels = status.Labels
+	}
+	return doc
+}
+
+// newTasksDocument describes the outcome of a batch request, using the
+// given function to describe each task that was acted on
+func newTasksDocument(results []client.TaskResult, describe func(client.TaskResult) interface{}) tasksDocument {
+	doc := tasksDocument{Tasks: make([]interface{}, 0, len(results))}
+	for _, r := range results {
+		if r.Err != nil {
+			doc.Tasks = append(doc.Tasks, taskErrorDocument{
+				TaskID: r.TaskID,
+				Error:  newErrorDocument(r.Err).Error,
+			})
+			continue
+		}
+		doc.Tasks = append(doc.Tasks, describe(r))
+	}
+	return doc
+}
+
+func newLogsDocument(id string, output *client.Output) logsDocument {
+	return logsDocument{
+		TaskID:      id,
+		StdoutBytes: len(output.Stdout),
+		StderrBytes: len(output.Stderr),
+		Stdout:      strings.ToValidUTF8(string(output.Stdout), "\uFFFD"),
+		Stderr:      strings.ToValidUTF8(string(output.Stderr), "\uFFFD"),
+	}
+}
+
+func newShareDocument(id string, grant client.Grant) shareDocument {
+	doc := shareDocument{
+		TaskID:  id,
+		Users:   append([]string{}, grant.Users...),
+		Groups:  append([]string{}, grant.Groups...),
+		Actions: make([]string, 0, len(grant.Actions)),
+	}
+	for _, a := range grant.Actions {
+		doc.Actions = append(doc.Actions, string(a))
+	}
+	return doc
+}
+
+func newErrorDocument(err error) errorDocument {
+	st := status.Convert(err)
+	doc := errorDocument{Error: errorDetail{
+		Code:    st.Code().String(),
+		Message: st.Message(),
+	}}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			doc.Error.Reason = info.Reason
+			doc.Error.Metadata = info.Metadata
+		}
+	}
+	return doc
+}
+
+// writeDocument renders a document in the selected output format
+func writeDocument(w io.Writer, doc interface{}) error {
+	switch outputFormat {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+
+	case formatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("Unknown output format %q", outputFormat)
+}
+
+// printResult writes a command's result to stdout, either as a structured
+// document or, for text output, however the command has always written it
+func printResult(doc interface{}, text func()) {
+	if outputFormat == formatText {
+		text()
+		return
+	}
+
+	if err := writeDocument(os.Stdout, doc); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write output: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
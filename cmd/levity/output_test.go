@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/client"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func render(t *testing.T, format string, doc interface{}) string {
+	defer func(f string) { outputFormat = f }(outputFormat)
+	outputFormat = format
+
+	var buf bytes.Buffer
+	require.NoError(t, writeDocument(&buf, doc))
+	return buf.String()
+}
+
+func TestStatusDocument(t *testing.T) {
+	require := require.New(t)
+
+	// Given a task that's still running
+	running := newStatusDocument("1234", &client.Status{
+		State:    client.StateRunning,
+		ExitCode: -1,
+	})
+
+	// Expect that the exit code is rendered as null, rather than left out
+	require.JSONEq(`{"task_id": "1234", "status": "Running", "exit_code": null, "signal": null}`,
+		render(t, formatJSON, running))
+	require.Equal("task_id: \"1234\"\nstatus: Running\nexit_code: null\nsignal: null\n",
+		render(t, formatYAML, running))
+
+	// Given a task that has finished
+	finished := newStatusDocument("1234", &client.Status{
+		State:    client.StateFinished,
+		ExitCode: 0,
+	})
+
+	// Expect that the exit code is rendered as a number
+	require.JSONEq(`{"task_id": "1234", "status": "Finished", "exit_code": 0, "signal": null}`,
+		render(t, formatJSON, finished))
+
+	// Given a task that was killed by a signal
+	killed := newStatusDocument("1234", &client.Status{
+		State:    client.StateFinished,
+		ExitCode: -1,
+		Signal:   syscall.SIGTERM,
+	})
+
+	// Expect that the signal is rendered as a number
+	require.JSONEq(`{"task_id": "1234", "status": "Finished", "exit_code": -1, "signal": 15}`,
+		render(t, formatJSON, killed))
+
+	// Given a task with labels
+	labelled := newStatusDocument("1234", &client.Status{
+		State:    client.StateRunning,
+		ExitCode: -1,
+		Labels:   map[string]string{"job": "build"},
+	})
+
+	// Expect that the labels are included
+	require.JSONEq(`{"task_id": "1234", "status": "Running", "exit_code": null, "signal": null,
+		"labels": {"job": "build"}}`,
+		render(t, formatJSON, labelled))
+}
+
+func TestTasksDocument(t *testing.T) {
+	require := require.New(t)
+
+	// Given the results of a batch request, one of which failed
+	st, err := status.New(codes.NotFound, "No such task: 5678").WithDetails(
+		&errdetails.ErrorInfo{Reason: "NO_SUCH_TASK", Metadata: map[string]string{"task_id": "5678"}})
+	require.NoError(err)
+
+	doc := newTasksDocument([]client.TaskResult{
+		{TaskID: "1234", Status: &client.Status{State: client.StateRunning, ExitCode: -1}},
+		{TaskID: "5678", Err: st.Err()},
+	}, func(r client.TaskResult) interface{} {
+		return newStatusDocument(r.TaskID, r.Status)
+	})
+
+	// Expect each task to be described as it would be on its own, and the
+	// failure to be described in the same terms as a failed request
+	require.JSONEq(`{"tasks": [
+		{"task_id": "1234", "status": "Running", "exit_code": null, "signal": null},
+		{"task_id": "5678", "error": {
+			"code": "NotFound",
+			"message": "No such task: 5678",
+			"reason": "NO_SUCH_TASK",
+			"metadata": {"task_id": "5678"}
+		}}
+	]}`, render(t, formatJSON, doc))
+
+	// ... and that an empty batch is rendered as an empty list
+	empty := newTasksDocument(nil, nil)
+	require.JSONEq(`{"tasks": []}`, render(t, formatJSON, empty))
+	require.Equal("tasks: []\n", render(t, formatYAML, empty))
+}
+
+func TestLogsDocument(t *testing.T) {
+	// Given task output that isn't valid UTF-8
+	doc := newLogsDocument("1234", &client.Output{
+		Stdout: []byte("hello\n"),
+		Stderr: []byte{0xff, 'x'},
+	})
+
+	// Expect that the byte counts are those of the raw output, and that the
+	// invalid bytes are replaced
+	require.JSONEq(t, `{
+		"task_id": "1234",
+		"stdout_bytes": 6,
+		"stderr_bytes": 2,
+		"stdout": "hello\n",
+		"stderr": "�x"
+	}`, render(t, formatJSON, doc))
+}
+
+func TestShareDocument(t *testing.T) {
+	doc := newShareDocument("1234", client.Grant{
+		Users:   []string{"bob"},
+		Actions: []client.Action{client.ActionQuery, client.ActionLogs},
+	})
+
+	require.JSONEq(t,
+		`{"task_id": "1234", "users": ["bob"], "groups": [], "actions": ["query", "logs"]}`,
+		render(t, formatJSON, doc))
+}
+
+func TestErrorDocument(t *testing.T) {
+	require := require.New(t)
+
+	st, err := status.New(codes.NotFound, "No such task: 1234").WithDetails(
+		&errdetails.ErrorInfo{
+			Reason:   "NO_SUCH_TASK",
+			Domain:   "levity",
+			Metadata: map[string]string{"task_id": "1234"},
+		})
+	require.NoError(err)
+
+	require.JSONEq(`{"error": {
+		"code": "NotFound",
+		"message": "No such task: 1234",
+		"reason": "NO_SUCH_TASK",
+		"metadata": {"task_id": "1234"}
+	}}`, render(t, formatJSON, newErrorDocument(st.Err())))
+
+	require.JSONEq(`{"error": {"code": "Unavailable", "message": "down"}}`,
+		render(t, formatJSON, newErrorDocument(status.Error(codes.Unavailable, "down"))))
+}
+
+func TestCheckOutputFormat(t *testing.T) {
+	defer func(f string) { outputFormat = f }(outputFormat)
+
+	for _, f := range []string{formatText, formatJSON, formatYAML} {
+		outputFormat = f
+		require.NoError(t, checkOutputFormat())
+	}
+
+	outputFormat = "xml"
+	require.Error(t, checkOutputFormat())
+}
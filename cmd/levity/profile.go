@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/tcsc/levity/config"
+)
+
+const (
+	argConfig  = "config"
+	argProfile = "profile"
+
+	// envConfig and envProfile name environment variables that may be used
+	// in place of --config and --profile
+	envConfig  = "LEVITY_CONFIG"
+	envProfile = "LEVITY_PROFILE"
+)
+
+var (
+	configPath  string
+	profileName string
+)
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+
+	flags.StringVar(&configPath, argConfig, "",
+		"Read server profiles from the given file (default: $"+envConfig+", or ~/.config/levity/config.yaml)")
+
+	flags.StringVarP(&profileName, argProfile, "p", "",
+		"Connect using the named profile from the config file (default: $"+envProfile+", or the file's default_profile)")
+}
+
+// loadProfile finds the server profile selected by the user, if any. It's
+// only an error for the config file to be missing if the user explicitly
+// asked for it, or for a profile in it.
+func loadProfile(flags *pflag.FlagSet) (*config.Profile, error) {
+	if !flags.Changed(argProfile) {
+		profileName = os.Getenv(envProfile)
+	}
+
+	explicit := true
+	if !flags.Changed(argConfig) {
+		configPath = os.Getenv(envConfig)
+	}
+	if configPath == "" {
+		explicit = false
+		var err error
+		if configPath, err = config.DefaultClientPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := config.LoadClient(configPath)
+	if os.IsNotExist(err) && !explicit && profileName == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return file.Profile(profileName)
+}
+
+// applyProfile fills in any connection settings that weren't given on the
+// command line from the selected server profile
+func applyProfile(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	profile, err := loadProfile(flags)
+	if err != nil {
+		return err
+	}
+
+	if profile == nil {
+		return nil
+	}
+
+	settings := []struct {
+		flag  string
+		value string
+		dst   *string
+	}{
+		{argAddress, profile.Address, &serverAddress},
+		{argClientCert, profile.Certificate, &idUserCert},
+		{argClientKey, profile.Key, &idPrivateKey},
+		{argCA, profile.CA, &caCertPath},
+		{argTokenFile, profile.TokenFile, &tokenFile},
+	}
+	for _, s := range settings {
+		if s.value != "" && !flags.Changed(s.flag) {
+			*s.dst = s.value
+		}
+	}
+
+	if profile.Timeout > 0 && !flags.Changed(argTimeout) {
+		timeout = profile.Timeout
+	}
+	return nil
+}
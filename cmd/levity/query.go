@@ -1,44 +1,80 @@
 package main
 
 import (
-	"context"
 	"fmt"
-	"log"
+	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/client"
 )
 
 var (
 	cmdQuery = cobra.Command{
-		Use:   "query [task-id]",
+		Use:   "query [task-id...]",
 		Short: "Fetch the task status",
-		Args:  cobra.ExactArgs(1),
-		Run:   queryStatus,
+		Long: "Fetch the status of a task. Given several task IDs, or --all, " +
+			"fetches the status of each of the selected tasks, one per line, " +
+			"optionally narrowed down with --status and --label.",
+		Args: checkSelector,
+		Run:  queryStatus,
 	}
 )
 
+func init() {
+	addSelectorFlags(&cmdQuery)
+}
+
 func queryStatus(cmd *cobra.Command, args []string) {
-	request := &api.QueryTaskRequest{
-		TaskId: &api.TaskHandle{Id: args[0]},
+	c, err := makeClient()
+	if err != nil {
+		setupFailed("Failed to create GRPC client: %v", err)
+	}
+	defer c.Close()
+
+	if isBatch(args) {
+		queryTasks(c, args)
+		return
 	}
 
-	conn, client, err := makeClient()
+	status, err := c.Query(commandCtx, args[0])
 	if err != nil {
-		log.Fatalf("Failed to create GRPC client: %v", err)
+		requestFailed(err)
 	}
-	defer conn.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	printResult(newStatusDocument(args[0], status), func() {
+		fmt.Println(status.State)
+		if status.State == client.StateFinished {
+			fmt.Println(status.ExitCode)
+		}
+	})
+}
 
-	response, err := client.QueryTask(ctx, request)
+// queryTasks queries a batch of tasks, writing a line for each of them
+// with its ID, status and (if it has finished) exit code
+func queryTasks(c *client.Client, args []string) {
+	results, err := c.QueryTasks(commandCtx, newSelector(args))
 	if err != nil {
-		log.Fatalf("GRPC request failed: %v", err)
+		requestFailed(err)
+	}
+
+	describe := func(r client.TaskResult) interface{} {
+		return newStatusDocument(r.TaskID, r.Status)
 	}
+	printResult(newTasksDocument(results, describe), func() {
+		for _, r := range results {
+			if r.Err != nil {
+				continue
+			}
+			if r.Status.State == client.StateFinished {
+				fmt.Println(r.TaskID, r.Status.State, r.Status.ExitCode)
+			} else {
+				fmt.Println(r.TaskID, r.Status.State)
+			}
+		}
+	})
 
-	fmt.Println(response.StatusCode)
-	if response.StatusCode == api.TaskStatusCode_Finished {
-		fmt.Println(*response.ExitCode)
+	if code := batchFailed(results); code != 0 {
+		finishTracing(nil)
+		os.Exit(code)
 	}
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/tcsc/levity/client"
+)
+
+var (
+	cmdRun = cobra.Command{
+		Use:   "run command [arg1...]",
+		Short: "Run a task on the server, and wait for it to finish",
+		Long: "Start a task on the server, writing its output to the local " +
+			"stdout and stderr as it runs. Interrupting the client (e.g. with " +
+			"Ctrl-C) signals the task to quit. Exits with the task's exit code, " +
+			"or 128 plus the number of the signal that killed it.",
+		Args: checkStartArgs,
+		Run:  runTask,
+	}
+)
+
+func init() {
+	addStartFlags(&cmdRun)
+}
+
+func runTask(cmd *cobra.Command, args []string) {
+	c, err := makeClient()
+	if err != nil {
+		setupFailed("Failed to create GRPC client: %v", err)
+	}
+	defer c.Close()
+
+	// Catch interrupts before the task is started, so that there's no window
+	// where one would kill the client and leave the task running
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+
+	id, err := c.Start(commandCtx, newCommand(args))
+	if err != nil {
+		requestFailed(err)
+	}
+
+	followed := make(chan error, 1)
+	go func() {
+		followed <- c.FollowLogs(commandCtx, id, os.Stdout, os.Stderr)
+	}()
+
+	for {
+		select {
+		case <-interrupts:
+			// The server kills the task outright if it doesn't quit within
+			// its grace period, so we carry on following it until it's gone
+			log.Printf("Interrupted: signalling task %s", id)
+			if err := c.Signal(commandCtx, id); err != nil {
+				log.Printf("Failed to signal task: %v", err)
+			}
+
+		case err := <-followed:
+			if err != nil {
+				requestFailed(err)
+			}
+
+			status, err := c.Query(commandCtx, id)
+			if err != nil {
+				requestFailed(err)
+			}
+
+			if code := taskExitCode(status); code != 0 {
+				finishTracing(nil)
+				os.Exit(code)
+			}
+			return
+		}
+	}
+}
+
+// taskExitCode maps the final status of a task onto the code that `run`
+// exits with, in the same way as a shell would for a local process
+func taskExitCode(status *client.Status) int {
+	if status.Signal != 0 {
+		return 128 + int(status.Signal)
+	}
+	if status.State == client.StateFinished && status.ExitCode >= 0 {
+		return status.ExitCode
+	}
+	return exitFailure
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/client"
+)
+
+func TestTaskExitCode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   *client.Status
+		expected int
+	}{
+		{
+			name:     "success",
+			status:   &client.Status{State: client.StateFinished, ExitCode: 0},
+			expected: 0,
+		},
+		{
+			name:     "failure",
+			status:   &client.Status{State: client.StateFinished, ExitCode: 2},
+			expected: 2,
+		},
+		{
+			name:     "signalled",
+			status:   &client.Status{State: client.StateFinished, ExitCode: -1, Signal: syscall.SIGTERM},
+			expected: 143,
+		},
+		{
+			name:     "killed",
+			status:   &client.Status{State: client.StateBrutallyKilled, ExitCode: -1, Signal: syscall.SIGKILL},
+			expected: 137,
+		},
+		{
+			name:     "server error",
+			status:   &client.Status{State: client.StateInternalServerError, ExitCode: -1},
+			expected: exitFailure,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, taskExitCode(tc.status))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tcsc/levity/client"
+)
+
+var (
+	selectAll      bool
+	selectStatuses []string
+	selectLabels   []string
+)
+
+// addSelectorFlags adds the flags for picking out several tasks at once to a
+// command that can act on them in a batch
+func addSelectorFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&selectAll, "all", false,
+		"Select all of the tasks you have access to, instead of listing their IDs")
+
+	cmd.Flags().StringSliceVar(&selectStatuses, "status", []string{},
+		"Only select tasks with this status, e.g. Running")
+
+	cmd.Flags().StringSliceVarP(&selectLabels, "label", "l", []string{},
+		"Only select tasks with this label, in the form NAME=VALUE")
+}
+
+// states are the task statuses that may be given with --status
+var states = map[client.State]bool{
+	client.StateNotStarted:          true,
+	client.StateRunning:             true,
+	client.StateSignalled:           true,
+	client.StateFinished:            true,
+	client.StateBrutallyKilled:      true,
+	client.StateInternalServerError: true,
+}
+
+// parseLabels parses labels given on the command line in the form NAME=VALUE
+func parseLabels(labels []string) (map[string]string, error) {
+	result := make(map[string]string, len(labels))
+	for _, s := range labels {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid label %q: expected NAME=VALUE", s)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
+
+// isBatch tests if a command should act on a batch of tasks, rather than
+// the single task it has always acted on
+func isBatch(args []string) bool {
+	return len(args) != 1 || selectAll || len(selectStatuses) > 0 || len(selectLabels) > 0
+}
+
+// checkSelector rejects command lines that don't select any tasks, or that
+// select them in two different ways, before any request is made
+func checkSelector(cmd *cobra.Command, args []string) error {
+	if selectAll && len(args) > 0 {
+		return errors.New("Give either task IDs or --all, not both")
+	}
+	if !selectAll && len(args) == 0 {
+		return errors.New("No tasks selected: give some task IDs, or --all")
+	}
+	for _, s := range selectStatuses {
+		if !states[client.State(s)] {
+			return fmt.Errorf("Unknown status %q", s)
+		}
+	}
+	_, err := parseLabels(selectLabels)
+	return err
+}
+
+// newSelector builds the selector for a batch request from the command line
+func newSelector(args []string) client.Selector {
+	labels, _ := parseLabels(selectLabels)
+	sel := client.Selector{
+		IDs:    args,
+		All:    selectAll,
+		Labels: labels,
+	}
+	for _, s := range selectStatuses {
+		sel.States = append(sel.States, client.State(s))
+	}
+	return sel
+}
+
+// batchFailed reports the tasks in a batch request that couldn't be acted
+// on, and returns the exit code for the first of them (or zero if there
+// were none), so that a script can tell that something went wrong
+func batchFailed(results []client.TaskResult) int {
+	code := 0
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+
+		log.Printf("Task %s: %v", r.TaskID, r.Err)
+		if details := describeDetails(r.Err); details != "" {
+			log.Printf("Error details: %s", details)
+		}
+		if code == 0 {
+			code = exitCodeFor(r.Err)
+		}
+	}
+	return code
+}
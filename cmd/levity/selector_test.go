@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/client"
+)
+
+func TestParseLabels(t *testing.T) {
+	require := require.New(t)
+
+	labels, err := parseLabels([]string{"job=build", "empty=", "url=http://x/?a=b"})
+	require.NoError(err)
+	require.Equal(map[string]string{"job": "build", "empty": "", "url": "http://x/?a=b"}, labels)
+
+	for _, invalid := range []string{"job", "=build", ""} {
+		_, err := parseLabels([]string{invalid})
+		require.Error(err, invalid)
+	}
+}
+
+// withSelector sets the selector flags for the duration of a test
+func withSelector(t *testing.T, all bool, statuses []string, labels []string) {
+	oldAll, oldStatuses, oldLabels := selectAll, selectStatuses, selectLabels
+	t.Cleanup(func() { selectAll, selectStatuses, selectLabels = oldAll, oldStatuses, oldLabels })
+	selectAll, selectStatuses, selectLabels = all, statuses, labels
+}
+
+func TestCheckSelector(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		all      bool
+		statuses []string
+		labels   []string
+		valid    bool
+		batch    bool
+	}{
+		{name: "single task", args: []string{"1234"}, valid: true, batch: false},
+		{name: "several tasks", args: []string{"1234", "5678"}, valid: true, batch: true},
+		{name: "single task with status", args: []string{"1234"}, statuses: []string{"Running"}, valid: true, batch: true},
+		{name: "all", all: true, valid: true, batch: true},
+		{name: "all with label", all: true, labels: []string{"job=build"}, valid: true, batch: true},
+		{name: "nothing selected", labels: []string{"job=build"}, valid: false},
+		{name: "all and IDs", args: []string{"1234"}, all: true, valid: false},
+		{name: "unknown status", all: true, statuses: []string{"running"}, valid: false},
+		{name: "malformed label", all: true, labels: []string{"job"}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			withSelector(t, tc.all, tc.statuses, tc.labels)
+
+			err := checkSelector(&cmdQuery, tc.args)
+			if !tc.valid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.batch, isBatch(tc.args))
+		})
+	}
+}
+
+func TestNewSelector(t *testing.T) {
+	withSelector(t, true, []string{"Running", "Signalled"}, []string{"job=build"})
+
+	require.Equal(t, client.Selector{
+		All:    true,
+		Labels: map[string]string{"job": "build"},
+		States: []client.State{client.StateRunning, client.StateSignalled},
+	}, newSelector(nil))
+}
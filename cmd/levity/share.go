@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/tcsc/levity/client"
+)
+
+var (
+	shareUsers  []string
+	shareGroups []string
+	shareRead   bool
+	shareSignal bool
+
+	cmdShare = cobra.Command{
+		Use:   "share [task-id]",
+		Short: "Share a task with other users",
+		Long: "Grant other users, or groups of users, permission to interact " +
+			"with a task. At least one of --read or --signal must be given.",
+		Args: cobra.ExactArgs(1),
+		Run:  shareTask,
+	}
+)
+
+func init() {
+	cmdShare.Flags().StringSliceVarP(&shareUsers, "user", "u", []string{},
+		"Login of a user to share the task with")
+
+	cmdShare.Flags().StringSliceVarP(&shareGroups, "group", "g", []string{},
+		"Group to share the task with")
+
+	cmdShare.Flags().BoolVar(&shareRead, "read", false,
+		"Allow querying the task status and fetching its logs")
+
+	cmdShare.Flags().BoolVar(&shareSignal, "signal", false,
+		"Allow signalling the task to quit")
+}
+
+func shareTask(cmd *cobra.Command, args []string) {
+	grant := client.Grant{
+		Users:  shareUsers,
+		Groups: shareGroups,
+	}
+
+	if shareRead {
+		grant.Actions = append(grant.Actions, client.ActionQuery, client.ActionLogs)
+	}
+
+	if shareSignal {
+		grant.Actions = append(grant.Actions, client.ActionSignal)
+	}
+
+	if len(grant.Actions) == 0 {
+		setupFailed("Nothing to share: specify at least one of --read or --signal")
+	}
+
+	c, err := makeClient()
+	if err != nil {
+		setupFailed("Failed to create GRPC client: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Share(commandCtx, args[0], grant); err != nil {
+		requestFailed(err)
+	}
+
+	printResult(newShareDocument(args[0], grant), func() {})
+}
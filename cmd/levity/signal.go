@@ -1,36 +1,69 @@
 package main
 
 import (
-	"context"
-	"log"
+	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/client"
 )
 
 var (
 	cmdSignal = cobra.Command{
-		Use:   "signal [task-id]",
+		Use:   "signal [task-id...]",
 		Short: "Signal the task to quit",
-		Args:  cobra.ExactArgs(1),
-		Run:   signalTask,
+		Long: "Signal a task to quit. Given several task IDs, or --all, " +
+			"signals each of the selected tasks, optionally narrowed down with " +
+			"--status and --label, and writes the IDs of those it signalled.",
+		Args: checkSelector,
+		Run:  signalTask,
 	}
 )
 
-func signalTask(cmd *cobra.Command, args []string) {
-	request := &api.SignalTaskRequest{TaskId: &api.TaskHandle{Id: args[0]}}
+func init() {
+	addSelectorFlags(&cmdSignal)
+}
 
-	conn, client, err := makeClient()
+func signalTask(cmd *cobra.Command, args []string) {
+	c, err := makeClient()
 	if err != nil {
-		log.Fatalf("Failed to create GRPC client: %v", err)
+		setupFailed("Failed to create GRPC client: %v", err)
+	}
+	defer c.Close()
+
+	if isBatch(args) {
+		signalTasks(c, args)
+		return
+	}
+
+	if err := c.Signal(commandCtx, args[0]); err != nil {
+		requestFailed(err)
 	}
-	defer conn.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	printResult(taskDocument{TaskID: args[0]}, func() {})
+}
 
-	_, err = client.SignalTask(ctx, request)
+// signalTasks signals a batch of tasks, writing the ID of each task that
+// was signalled
+func signalTasks(c *client.Client, args []string) {
+	results, err := c.SignalTasks(commandCtx, newSelector(args))
 	if err != nil {
-		log.Fatalf("GRPC request failed: %v", err)
+		requestFailed(err)
+	}
+
+	describe := func(r client.TaskResult) interface{} {
+		return taskDocument{TaskID: r.TaskID}
+	}
+	printResult(newTasksDocument(results, describe), func() {
+		for _, r := range results {
+			if r.Err == nil {
+				fmt.Println(r.TaskID)
+			}
+		}
+	})
+
+	if code := batchFailed(results); code != 0 {
+		finishTracing(nil)
+		os.Exit(code)
 	}
 }
@@ -1,34 +1,54 @@
 package main
 
 import (
-	"context"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/spf13/cobra"
-	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/client"
 )
 
 var (
-	workingDir string
-	envStrings []string
+	workingDir  string
+	envStrings  []string
+	startLabels []string
 
 	cmdStart = cobra.Command{
 		Use:   "start command [arg1...]",
 		Short: "Start a task on the server",
 		Run:   startTask,
-		Args:  cobra.MinimumNArgs(1),
+		Args:  checkStartArgs,
 	}
 )
 
 func init() {
-	cmdStart.Flags().StringVarP(&workingDir, "dir", "d", "",
+	addStartFlags(&cmdStart)
+}
+
+// addStartFlags adds the flags describing the task to start to a command
+// that starts one
+func addStartFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&workingDir, "dir", "d", "",
 		"Optionally set the working dir on the server")
 
-	cmdStart.Flags().StringSliceVarP(&envStrings, "define", "D",
+	cmd.Flags().StringSliceVarP(&envStrings, "define", "D",
 		[]string{},
 		"Define an environment variable, in the form FOO=BAR")
+
+	cmd.Flags().StringSliceVarP(&startLabels, "label", "l",
+		[]string{},
+		"Label the task, in the form NAME=VALUE, so that it can be selected "+
+			"later with --label")
+}
+
+// checkStartArgs requires a command to start, and rejects malformed labels
+// before any request is made
+func checkStartArgs(cmd *cobra.Command, args []string) error {
+	if err := cobra.MinimumNArgs(1)(cmd, args); err != nil {
+		return err
+	}
+	_, err := parseLabels(startLabels)
+	return err
 }
 
 func formatEnv(env []string) map[string]string {
@@ -55,29 +75,32 @@ func formatEnv(env []string) map[string]string {
 	return result
 }
 
-func startTask(cmd *cobra.Command, args []string) {
-	request := &api.StartTaskRequest{
-		Binary:      args[0],
-		Environment: formatEnv(envStrings),
-		Args:        args[1:],
-	}
-	if workingDir != "" {
-		request.WorkingDir = &workingDir
+// newCommand describes the task to start from the given command line, with
+// the working dir, environment and labels given on the command line
+func newCommand(args []string) client.Command {
+	labels, _ := parseLabels(startLabels)
+	return client.Command{
+		Binary: args[0],
+		Args:   args[1:],
+		Dir:    workingDir,
+		Env:    formatEnv(envStrings),
+		Labels: labels,
 	}
+}
 
-	conn, client, err := makeClient()
+func startTask(cmd *cobra.Command, args []string) {
+	c, err := makeClient()
 	if err != nil {
-		log.Fatalf("Failed to create GRPC client: %v", err)
+		setupFailed("Failed to create GRPC client: %v", err)
 	}
-	defer conn.Close()
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	defer c.Close()
 
-	response, err := client.StartTask(ctx, request)
+	id, err := c.Start(commandCtx, newCommand(args))
 	if err != nil {
-		log.Fatalf("GRPC request failed: %v", err)
+		requestFailed(err)
 	}
 
-	fmt.Println(response.TaskId.Id)
+	printResult(taskDocument{TaskID: id}, func() {
+		fmt.Println(id)
+	})
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tcsc/levity/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	otlpEndpoint string
+	otlpInsecure bool
+	traceFile    string
+
+	// commandCtx carries the span covering the whole command, which all of
+	// the command's requests are made under. If the client was run with a
+	// TRACEPARENT in its environment (e.g. by a CI pipeline) then the span
+	// joins that trace.
+	commandCtx  = context.Background()
+	commandSpan trace.Span
+
+	stopTracing func(context.Context) error
+)
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+
+	flags.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"Export trace spans to the OTLP/GRPC collector at the given host:port")
+
+	flags.BoolVar(&otlpInsecure, "otlp-insecure", false,
+		"Connect to the OTLP collector without TLS")
+
+	flags.StringVar(&traceFile, "trace-file", "",
+		"Append trace spans to the given file, as JSON")
+
+	rootCmd.PersistentPostRun = func(*cobra.Command, []string) {
+		finishTracing(nil)
+	}
+}
+
+func startTracing(cmd *cobra.Command, args []string) error {
+	var err error
+	stopTracing, err = tracing.Init(context.Background(), tracing.Config{
+		ServiceName:  "levity",
+		OTLPEndpoint: otlpEndpoint,
+		OTLPInsecure: otlpInsecure,
+		File:         traceFile,
+	})
+	if err != nil {
+		return err
+	}
+
+	commandCtx, commandSpan = tracing.Tracer().Start(
+		tracing.FromEnvironment(context.Background()), "levity "+cmd.Name())
+	return nil
+}
+
+// finishTracing ends the command's span, recording the error it failed with
+// (if any), and flushes any spans that have yet to be exported. Must be
+// called before the client exits, or spans may be lost.
+func finishTracing(err error) {
+	if stopTracing == nil {
+		return
+	}
+
+	if err != nil {
+		commandSpan.RecordError(err)
+		commandSpan.SetStatus(codes.Error, err.Error())
+	}
+	commandSpan.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := stopTracing(ctx); err != nil {
+		log.Printf("Failed to export trace spans: %v", err)
+	}
+	stopTracing = nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tcsc/levity/pki"
+)
+
+// The `ca` subcommands replace the OpenSSL scripts that used to be used to
+// create the CAs and certificates for testing, and are good enough for
+// issuing short-lived certificates to real users.
+
+var (
+	cmdCA = cobra.Command{
+		Use:   "ca",
+		Short: "Manage the certificate authorities used for mutual TLS",
+	}
+
+	cmdCAInit = cobra.Command{
+		Use:   "init",
+		Short: "Create a new certificate authority",
+		Args:  cobra.NoArgs,
+		Run:   caInit,
+	}
+
+	cmdCAIssue = cobra.Command{
+		Use:   "issue",
+		Short: "Issue a client or server certificate",
+		Long: "Issue a client certificate for the user given by --login, or a " +
+			"server certificate for the hosts given by --host",
+		Args: cobra.NoArgs,
+		Run:  caIssue,
+	}
+
+	caInitDir   string
+	caInitName  string
+	caInitCN    string
+	caInitOU    string
+	caInitTTL   time.Duration
+	caInitForce bool
+
+	issueDir    string
+	issueCACert string
+	issueCAKey  string
+	issueTTL    time.Duration
+	issueLogin  string
+	issueHosts  []string
+	issueName   string
+	issueOrgs   []string
+	issueOUs    []string
+	issueURIs   []string
+)
+
+func init() {
+	cmdCAInit.Flags().StringVarP(&caInitDir, "out", "o", "./cert",
+		"Directory to write the CA certificate and key to")
+	cmdCAInit.Flags().StringVar(&caInitName, "name", "client",
+		"Name of the CA, used as a prefix for the CA files (e.g. client-ca-cert.pem)")
+	cmdCAInit.Flags().StringVar(&caInitCN, "cn", "",
+		"Common name for the CA certificate (default: derived from --name)")
+	cmdCAInit.Flags().StringVar(&caInitOU, "ou", "levity",
+		"Organisational unit for the CA certificate")
+	cmdCAInit.Flags().DurationVar(&caInitTTL, "ttl", 365*24*time.Hour,
+		"How long the CA certificate is valid for")
+	cmdCAInit.Flags().BoolVar(&caInitForce, "force", false,
+		"Overwrite an existing CA")
+
+	cmdCAIssue.Flags().StringVarP(&issueDir, "out", "o", "./cert",
+		"Directory to write the certificate and key to")
+	cmdCAIssue.Flags().StringVar(&issueCACert, "ca-cert", "./cert/client-ca-cert.pem",
+		"Path to the certificate of the issuing CA")
+	cmdCAIssue.Flags().StringVar(&issueCAKey, "ca-key", "./cert/client-ca-key.pem",
+		"Path to the private key of the issuing CA")
+	cmdCAIssue.Flags().DurationVar(&issueTTL, "ttl", 24*time.Hour,
+		"How long the certificate is valid for")
+	cmdCAIssue.Flags().StringVar(&issueLogin, "login", "",
+		"Issue a client certificate for the given user")
+	cmdCAIssue.Flags().StringSliceVar(&issueHosts, "host", []string{},
+		"Issue a server certificate for the given DNS names and/or IP addresses")
+	cmdCAIssue.Flags().StringVar(&issueName, "name", "",
+		"Prefix for the certificate files (default: the login, or \"svr\" for server certificates)")
+	cmdCAIssue.Flags().StringSliceVar(&issueOrgs, "org", []string{"levity"},
+		"Organisation(s) for the certificate subject")
+	cmdCAIssue.Flags().StringSliceVar(&issueOUs, "ou", []string{"client"},
+		"Organisational unit(s) for the certificate subject, usable as group claims")
+	cmdCAIssue.Flags().StringSliceVar(&issueURIs, "uri", []string{},
+		"URI subject alternative names, usable as group or role claims")
+
+	cmdCA.AddCommand(&cmdCAInit, &cmdCAIssue)
+	rootCmd.AddCommand(&cmdCA)
+}
+
+func exists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+func caInit(cmd *cobra.Command, args []string) {
+	certPath := filepath.Join(caInitDir, caInitName+"-ca-cert.pem")
+	keyPath := filepath.Join(caInitDir, caInitName+"-ca-key.pem")
+
+	if !caInitForce && (exists(certPath) || exists(keyPath)) {
+		log.Fatalf("CA %q already exists in %s (use --force to replace it)", caInitName, caInitDir)
+	}
+
+	cn := caInitCN
+	if cn == "" {
+		cn = fmt.Sprintf("levity %s CA", caInitName)
+	}
+
+	ca, err := pki.NewCA(cn, caInitOU, caInitTTL)
+	if err != nil {
+		log.Fatalf("Failed to create CA: %v", err)
+	}
+
+	if err := ca.Save(certPath, keyPath); err != nil {
+		log.Fatalf("Failed to write CA: %v", err)
+	}
+
+	fmt.Println(certPath)
+	fmt.Println(keyPath)
+}
+
+func caIssue(cmd *cobra.Command, args []string) {
+	if (issueLogin == "") == (len(issueHosts) == 0) {
+		log.Fatalf("Specify exactly one of --login or --host")
+	}
+
+	ca, err := pki.LoadCA(issueCACert, issueCAKey)
+	if err != nil {
+		log.Fatalf("Failed to load CA: %v", err)
+	}
+
+	req := pki.Request{
+		CommonName:          issueLogin,
+		Organisation:        issueOrgs,
+		OrganisationalUnits: issueOUs,
+		TTL:                 issueTTL,
+	}
+
+	for _, text := range issueURIs {
+		u, err := url.Parse(text)
+		if err != nil {
+			log.Fatalf("Invalid URI %q: %v", text, err)
+		}
+		req.URIs = append(req.URIs, u)
+	}
+
+	name := issueName
+	if len(issueHosts) > 0 {
+		req.Usage = pki.ServerCertificate
+		req.CommonName = issueHosts[0]
+		req.Hosts = issueHosts
+		if !cmd.Flags().Changed("ou") {
+			req.OrganisationalUnits = nil
+		}
+		if name == "" {
+			name = "svr"
+		}
+	} else if name == "" {
+		name = issueLogin
+	}
+
+	cert, key, err := ca.Issue(req)
+	if err != nil {
+		log.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	certPath := filepath.Join(issueDir, name+"-cert.pem")
+	keyPath := filepath.Join(issueDir, name+"-key.pem")
+	if err := pki.WriteKeyPair(cert, key, certPath, keyPath); err != nil {
+		log.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	fmt.Println(certPath)
+	fmt.Println(keyPath)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// unixPrefix marks a listen address as the path to a Unix domain socket,
+// rather than a TCP host:port
+const unixPrefix = "unix:"
+
+// listen opens a listener on each of the addresses, which are either TCP
+// `host:port` pairs or `unix:PATH` for a Unix domain socket. If any of them
+// can't be opened, any that were are closed again.
+func listen(addrs []string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := listenOn(addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("Failed to listen on %q: %w", addr, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func listenOn(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return net.Listen("tcp", addr)
+	}
+
+	path := strings.TrimPrefix(addr, unixPrefix)
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	// NB: The socket is removed again when the listener is closed
+	return net.Listen("unix", path)
+}
+
+// staleSocketTimeout bounds how long to wait for an existing socket to
+// answer, before deciding that it was left behind by a previous instance
+const staleSocketTimeout = 1 * time.Second
+
+// listenLocal opens the local socket, where clients are identified by their
+// peer credentials. Anyone who can connect to it can act as themselves, which
+// is enough to run commands as the daemon's user unless the policies say
+// otherwise, so the socket is only open to the given group (or the daemon's
+// own group, if gid is -1) by default.
+func listenLocal(path string, mode os.FileMode, gid int) (net.Listener, error) {
+	// NB: The socket is created with the process's umask, so make sure that
+	//     nobody else can connect to it before its group and permissions
+	//     have been set. The umask is process-wide, but nothing else creates
+	//     files while the daemon is starting up.
+	umask := syscall.Umask(0177)
+	l, err := listenOn(unixPrefix + path)
+	syscall.Umask(umask)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to listen on local socket %q: %w", path, err)
+	}
+
+	if err := os.Chown(path, -1, gid); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// parseSocketMode parses the permissions for the local socket, given in
+// octal like `chmod` takes them
+func parseSocketMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode&^uint64(os.ModePerm) != 0 {
+		return 0, fmt.Errorf("Invalid socket mode %q: expected octal permissions, e.g. 0660", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// lookupGroup finds the ID of the group named (or numbered) by s, or -1 if s
+// is empty, to leave the group alone
+func lookupGroup(s string) (int, error) {
+	if s == "" {
+		return -1, nil
+	}
+
+	g, err := user.LookupGroup(s)
+	if _, unknown := err.(user.UnknownGroupError); unknown {
+		g, err = user.LookupGroupId(s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Invalid socket group %q: %w", s, err)
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// removeStaleSocket removes a socket left behind by a previous instance of
+// the daemon that didn't shut down cleanly. A socket that something is still
+// listening on is left alone, as is anything other than a socket, so that
+// neither a second instance nor a typo in the configuration can remove
+// something important.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists, and is not a socket", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, staleSocketTimeout); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is already in use", path)
+	}
+	return os.Remove(path)
+}
@@ -2,38 +2,122 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"errors"
-	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/audit"
+	"github.com/tcsc/levity/authn"
+	"github.com/tcsc/levity/config"
+	"github.com/tcsc/levity/environment"
+	"github.com/tcsc/levity/health"
+	"github.com/tcsc/levity/metrics"
+	"github.com/tcsc/levity/policy"
+	"github.com/tcsc/levity/registry"
+	"github.com/tcsc/levity/reload"
 	"github.com/tcsc/levity/taskmanager"
-	"github.com/tcsc/levity/user"
+	"github.com/tcsc/levity/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
+const (
+	// taskManagerService is the name the TaskManager's health is reported
+	// under, in addition to the daemon's overall health
+	taskManagerService = "levity.TaskManager"
+
+	healthCheckInterval = 5 * time.Second
+)
+
+// auditToSyslog is the special value of --audit-log that sends the audit log
+// to syslog rather than a file
+const auditToSyslog = "syslog"
+
 var (
 	rootCmd = cobra.Command{
-		Use:   "levityd [address]",
+		Use:   "levityd [address...]",
 		Short: "Levity: A simple task manager service",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.ArbitraryArgs,
 		Run:   levityMain,
 	}
 
+	configPath       string
+	listenAddrs      []string
+	localSocketPath  string
+	localSocketMode  string
+	localSocketGroup string
+
 	clientCACertPath string
 	certificatePath  string
 	privateKeyPath   string
+	crlPaths         []string
+	denyListPath     string
+
+	groupClaims []string
+	roleClaims  []string
+
+	// certificateMapper maps client certificates to users. Configured from
+	// the command line at startup, and treated as read-only thereafter.
+	certificateMapper authn.CertificateMapper
+
+	jwksPath         string
+	tokenIssuer      string
+	tokenAudience    string
+	tokenLoginClaim  string
+	tokenGroupsClaim string
+	tokenRolesClaim  string
+
+	// authenticator identifies the user behind each request, from either
+	// their client certificate or a bearer token. Configured at startup and
+	// treated as read-only thereafter.
+	authenticator authn.Chain
+
+	auditLogPath string
+
+	metricsAddr string
+
+	otlpEndpoint string
+	otlpInsecure bool
+	traceFile    string
+
+	shutdownPolicy string
+
+	maxTasks        int
+	maxTasksPerUser int
+	maxLogBytes     int
+	logRetention    time.Duration
+
+	commandPolicyPath string
+	authPolicyPath    string
+
+	inheritEnv  bool
+	passEnv     []string
+	envFilePath string
+	deniedEnv   []string
 )
 
 func init() {
+	rootCmd.Flags().StringVar(&configPath, "config", "",
+		"Read settings from the given YAML file. Flags and "+config.EnvPrefix+"* environment variables take precedence.")
+
+	rootCmd.Flags().StringSliceVar(&listenAddrs, "listen", []string{},
+		"Serve requests on the given addresses, as host:port or unix:PATH, in addition to any given as arguments")
+
+	rootCmd.Flags().StringVar(&localSocketPath, "local-socket", "",
+		"Also serve requests from local clients on the given Unix socket, identifying them by their user ID rather than TLS")
+
+	rootCmd.Flags().StringVar(&localSocketMode, "local-socket-mode", "0660",
+		"Permissions for the local socket, in octal. Anyone who can connect to it can run tasks as themselves.")
+
+	rootCmd.Flags().StringVar(&localSocketGroup, "local-socket-group", "",
+		"Group (name or ID) that owns the local socket, instead of the daemon's own group")
+
 	rootCmd.Flags().StringVarP(&certificatePath, "certificate", "c",
 		"./cert/svr-cert.pem",
 		"Path to server TLS certificate")
@@ -45,150 +129,493 @@ func init() {
 	rootCmd.Flags().StringVar(&clientCACertPath, "client-ca",
 		"",
 		"Specify the root CA used to validate client certificates")
+
+	rootCmd.Flags().StringSliceVar(&crlPaths, "crl", []string{},
+		"Reject client certificates revoked by the given CRL, which must be signed by the client CA")
+
+	rootCmd.Flags().StringVar(&denyListPath, "deny-list", "",
+		"Reject client certificates listed in the given file, by serial:HEX or sha256:HEX")
+
+	rootCmd.Flags().StringSliceVar(&groupClaims, "group-claim", []string{},
+		"Client certificate field to take group names from: one of ou, o, uri:PREFIX or oid:OID")
+
+	rootCmd.Flags().StringSliceVar(&roleClaims, "role-claim", []string{},
+		"Client certificate field to take role names from: one of ou, o, uri:PREFIX or oid:OID")
+
+	rootCmd.Flags().StringVar(&jwksPath, "jwks", "",
+		"Also accept bearer tokens signed by a key in the given JWKS file, making client certificates optional")
+
+	rootCmd.Flags().StringVar(&tokenIssuer, "token-issuer", "",
+		"Only accept bearer tokens with the given issuer (iss) claim")
+
+	rootCmd.Flags().StringVar(&tokenAudience, "token-audience", "",
+		"Only accept bearer tokens with the given audience (aud) claim")
+
+	rootCmd.Flags().StringVar(&tokenLoginClaim, "token-login-claim", "sub",
+		"Bearer token claim holding the user's login")
+
+	rootCmd.Flags().StringVar(&tokenGroupsClaim, "token-group-claim", "groups",
+		"Bearer token claim holding the user's groups")
+
+	rootCmd.Flags().StringVar(&tokenRolesClaim, "token-role-claim", "roles",
+		"Bearer token claim holding the user's roles")
+
+	rootCmd.Flags().StringVar(&auditLogPath, "audit-log", "",
+		"Record every request as a JSON line in the given file, or \""+auditToSyslog+"\" to send them to the local syslog")
+
+	rootCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "",
+		"Serve Prometheus metrics over plain HTTP at /metrics on the given address")
+
+	rootCmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"Export trace spans to the OTLP/GRPC collector at the given host:port")
+
+	rootCmd.Flags().BoolVar(&otlpInsecure, "otlp-insecure", false,
+		"Connect to the OTLP collector without TLS")
+
+	rootCmd.Flags().StringVar(&traceFile, "trace-file", "",
+		"Append trace spans to the given file, as JSON")
+
+	rootCmd.Flags().StringVar(&shutdownPolicy, "shutdown-policy", string(taskmanager.ShutdownSignal),
+		"What to do with running tasks on shutdown: signal them and wait for them to exit, "+
+			"detach and leave them running, or wait for them to finish by themselves")
+
+	rootCmd.Flags().IntVar(&maxTasks, "max-tasks", 0,
+		"Maximum number of tasks that may run at once (0 for no limit)")
+
+	rootCmd.Flags().IntVar(&maxTasksPerUser, "max-tasks-per-user", 0,
+		"Maximum number of tasks that each user may run at once (0 for no limit)")
+
+	rootCmd.Flags().IntVar(&maxLogBytes, "max-log-bytes", 0,
+		"Maximum amount of stdout and stderr (each) kept for each task, in bytes. Further output is discarded. (0 for no limit)")
+
+	rootCmd.Flags().DurationVar(&logRetention, "log-retention", 0,
+		"How long to keep finished tasks and their output before forgetting them (0 to keep them until the daemon exits)")
+
+	rootCmd.Flags().StringVar(&commandPolicyPath, "command-policy", "",
+		"Restrict the commands users may run to those in the given allowlist file")
+
+	rootCmd.Flags().StringVar(&authPolicyPath, "auth-policy", "",
+		"Grant users roles on other users' tasks, as described in the given policy file")
+
+	rootCmd.Flags().BoolVar(&inheritEnv, "inherit-env", false,
+		"Start tasks with the daemon's entire environment")
+
+	rootCmd.Flags().StringSliceVar(&passEnv, "pass-env",
+		[]string{"PATH", "HOME", "LANG", "USER", "LOGNAME", "SHELL", "TMPDIR", "TZ"},
+		"Daemon environment variables passed on to tasks (ignored if --inherit-env is set)")
+
+	rootCmd.Flags().StringVar(&envFilePath, "env-file", "",
+		"File of NAME=value lines to add to the base task environment")
+
+	rootCmd.Flags().StringSliceVar(&deniedEnv, "deny-env",
+		environment.DefaultDenied,
+		"Environment variables that clients may not set")
 }
 
-func expandPaths() error {
-	s, err := filepath.Abs(certificatePath)
-	if err != nil {
+// initConfig fills in any settings not given on the command line from the
+// environment and the configuration file (if any). The configuration file may
+// itself be given in the environment.
+func initConfig(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if !flags.Changed("config") {
+		configPath = os.Getenv(config.EnvVar("config"))
+	}
+
+	var file *config.Daemon
+	if configPath != "" {
+		log.Printf("Loading configuration from %s", configPath)
+		var err error
+		if file, err = config.Load(configPath); err != nil {
+			return err
+		}
+	}
+
+	return config.Apply(flags, file)
+}
+
+// initClaims configures how group and role claims are extracted from client
+// certificates
+func initClaims() error {
+	for _, spec := range groupClaims {
+		source, err := authn.ParseClaimSource(spec)
+		if err != nil {
+			return err
+		}
+		certificateMapper.Groups = append(certificateMapper.Groups, source)
+	}
+
+	for _, spec := range roleClaims {
+		source, err := authn.ParseClaimSource(spec)
+		if err != nil {
+			return err
+		}
+		certificateMapper.Roles = append(certificateMapper.Roles, source)
+	}
+
+	return nil
+}
+
+// initAuthentication builds the chain of authenticators used to identify
+// users. Client certificates always take precedence over bearer tokens. The
+// token signing keys are reloaded whenever the JWKS file changes.
+//
+// In a real application, a lot more validation would need to go into
+// authentication (e.g. checking a user database, etc). Revoked client
+// certificates are rejected by the TLS layer when a connection is
+// established or resumed (see `initTLS`), and again on every request, in
+// case they were revoked after the connection was established.
+//
+// But for this exercise, for the sake of simplicity, we're just going to
+// assume that
+//  1. Holding a valid certificate or token implies you are an authorised
+//     user,
+//  2. The certificate CN (or token login claim) is a unique identifier
+//     for user on the system, and
+//  3. Any group or role claims in the configured certificate fields or
+//     token claims were vetted by whoever issued them.
+//
+// (NB: I would *not* consider these good assumptionions for a production
+// system)
+func initAuthentication() error {
+	if err := initClaims(); err != nil {
 		return err
 	}
-	certificatePath = s
+	authenticator = authn.Chain{&certificateMapper}
+
+	// Clients on the local socket are identified by the kernel, and can't
+	// present any other kind of credentials (or at least, they're ignored)
+	if localSocketPath != "" {
+		authenticator = append(authn.Chain{&authn.PeerCredentialsMapper{}}, authenticator...)
+	}
+
+	if jwksPath == "" {
+		return nil
+	}
 
-	s, err = filepath.Abs(privateKeyPath)
+	log.Printf("Loading token signing keys from %s", jwksPath)
+	keys, err := authn.LoadKeySet(jwksPath)
 	if err != nil {
 		return err
 	}
 
-	privateKeyPath = s
+	go reload.Watch(context.Background(), reload.DefaultInterval, func() {
+		if err := keys.Reload(); err != nil {
+			log.Printf("Failed to reload token signing keys: %v", err)
+		}
+	}, keys.Path())
+
+	authenticator = append(authenticator, &authn.TokenAuthenticator{
+		Keys:        keys,
+		Issuer:      tokenIssuer,
+		Audience:    tokenAudience,
+		LoginClaim:  tokenLoginClaim,
+		GroupsClaim: tokenGroupsClaim,
+		RolesClaim:  tokenRolesClaim,
+	})
 
 	return nil
 }
 
-func initTLS() (grpc.ServerOption, error) {
+// initInterceptors assembles the interceptors that every request passes
+// through on the way to the TaskManager. If auditing is enabled, the audit
+// interceptor comes before authentication so that requests that fail
+// authentication are still audited. The tracing and metrics interceptors come
+// first of all, so that they see every request too.
+func initInterceptors(m *metrics.Metrics) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor, error) {
+	unary := []grpc.UnaryServerInterceptor{otelgrpc.UnaryServerInterceptor()}
+	stream := []grpc.StreamServerInterceptor{otelgrpc.StreamServerInterceptor()}
+
+	if m != nil {
+		unary = append(unary, m.UnaryServerInterceptor())
+		stream = append(stream, m.StreamServerInterceptor())
+	}
+
+	if auditLogPath == "" {
+		unary = append(unary, authn.UnaryServerInterceptor(authenticator, health.ServiceName))
+		stream = append(stream, authn.StreamServerInterceptor(authenticator, health.ServiceName))
+		return unary, stream, nil
+	}
+
+	var sink audit.Sink
+	var err error
+	if auditLogPath == auditToSyslog {
+		log.Printf("Writing audit log to syslog")
+		sink, err = audit.OpenSyslog()
+	} else {
+		log.Printf("Writing audit log to %s", auditLogPath)
+		sink, err = audit.OpenFile(auditLogPath)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	auditor := audit.New(sink)
+	unary = append(unary,
+		auditor.UnaryServerInterceptor(health.ServiceName),
+		authn.UnaryServerInterceptor(authenticator, health.ServiceName),
+		audit.UserInterceptor)
+	stream = append(stream,
+		auditor.StreamServerInterceptor(health.ServiceName),
+		authn.StreamServerInterceptor(authenticator, health.ServiceName),
+		audit.StreamUserInterceptor)
+
+	return unary, stream, nil
+}
+
+// initTracing installs the tracer provider that the GRPC interceptors and
+// task manager create spans with. Spans are only exported if an exporter has
+// been configured, but the trace context is passed on to tasks regardless.
+// Returns a function that flushes any buffered spans on shutdown.
+func initTracing() (func(context.Context) error, error) {
+	if otlpEndpoint != "" {
+		log.Printf("Exporting trace spans to %s", otlpEndpoint)
+	}
+	if traceFile != "" {
+		log.Printf("Writing trace spans to %s", traceFile)
+	}
 
-	log.Printf("Loading TLS certificate from %s", certificatePath)
-	log.Printf("Loading private key from %s", privateKeyPath)
-	log.Printf("Client CA Cert path: %s", clientCACertPath)
+	return tracing.Init(context.Background(), tracing.Config{
+		ServiceName:  "levityd",
+		OTLPEndpoint: otlpEndpoint,
+		OTLPInsecure: otlpInsecure,
+		File:         traceFile,
+	})
+}
+
+// initMetrics starts serving metrics over HTTP if a metrics address has been
+// configured. The metrics server is deliberately separate from the API
+// server, as it is unauthenticated and should only be reachable by whatever
+// is scraping it.
+func initMetrics(tasks *registry.Registry) (*metrics.Metrics, error) {
+	if metricsAddr == "" {
+		return nil, nil
+	}
 
-	idCert, err := tls.LoadX509KeyPair(certificatePath, privateKeyPath)
+	listener, err := net.Listen("tcp", metricsAddr)
 	if err != nil {
 		return nil, err
 	}
 
-	tlsCfg := &tls.Config{
-		Certificates: []tls.Certificate{idCert},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		MinVersion:   tls.VersionTLS13,
+	m := metrics.New(tasks)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+
+	log.Printf("Serving metrics on %s", listener.Addr().String())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Metrics server failed: %v", err)
+		}
+	}()
+
+	return m, nil
+}
+
+// initEnvironment builds the base environment for tasks from the daemon's own
+// environment and the (optional) environment file. Values from the file take
+// precedence over those inherited from the daemon.
+func initEnvironment() (*environment.Policy, error) {
+	var base map[string]string
+	if inheritEnv {
+		base = environment.Inherit()
+	} else {
+		base = environment.Allow(passEnv...)
 	}
 
-	if clientCACertPath != "" {
-		cert, err := ioutil.ReadFile(clientCACertPath)
+	if envFilePath != "" {
+		log.Printf("Loading task environment from %s", envFilePath)
+		fileEnv, err := environment.Load(envFilePath)
 		if err != nil {
 			return nil, err
 		}
 
-		certPool := x509.NewCertPool()
-		if !certPool.AppendCertsFromPEM(cert) {
-			return nil, errors.New("Failed to append certificate")
+		for k, v := range fileEnv {
+			base[k] = v
 		}
-
-		tlsCfg.ClientCAs = certPool
 	}
 
-	return grpc.Creds(credentials.NewTLS(tlsCfg)), nil
+	return environment.New(base, deniedEnv...), nil
 }
 
-// The CLI for the daemon is very simple, taking the address and
-// port to bind to as its single argument. This implies that server
-// can only listen to a single address, which not what you'd want
-// on a production server - but would require a more involved
-// configuration system than is appropriate for this exercise.
-
+// The daemon listens on the addresses given as arguments, plus any given
+// with `--listen` (or in the configuration file). Every listener serves the
+// same API, with the same TLS configuration and authentication.
 func levityMain(cmd *cobra.Command, args []string) {
-	addr := args[0]
+	if err := initConfig(cmd); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Copy the flag's addresses, rather than appending to its backing array
+	addrs := append(append([]string{}, listenAddrs...), args...)
+	if len(addrs) == 0 {
+		log.Fatalf("No addresses to listen on: give at least one as an argument, or with --listen")
+	}
 
 	if err := expandPaths(); err != nil {
-		log.Fatalf("Failed to get absolute paths for TLS keys: %v", err)
+		log.Fatalf("Failed to get absolute paths for TLS keys and sockets: %v", err)
+	}
+
+	if err := initAuthentication(); err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+
+	onShutdown, err := taskmanager.ParseShutdownPolicy(shutdownPolicy)
+	if err != nil {
+		log.Fatalf("Invalid shutdown policy: %v", err)
+	}
+
+	stopTracing, err := initTracing()
+	if err != nil {
+		log.Fatalf("Failed to configure tracing: %v", err)
 	}
 
 	options := make([]grpc.ServerOption, 0, 1)
-	creds, err := initTLS()
+	tlsState, err := initTLS()
 	if err != nil {
 		log.Fatalf("Failed to configure TLS : %v", err)
 	}
-	options = append(options, creds, grpc.UnaryInterceptor(authenticateRequest))
+	go tlsState.watch(context.Background())
 
-	listener, err := net.Listen("tcp", addr)
+	// NB: The certificate mapper is already in the authenticator chain, so
+	//     it picks this up from here on
+	certificateMapper.Revocations = tlsState.revocations
+
+	tasks := registry.New()
+	daemonMetrics, err := initMetrics(tasks)
 	if err != nil {
-		log.Fatalf("Failed to listen on \"%s\": %v", addr, err)
+		log.Fatalf("Failed to start metrics server: %v", err)
+	}
+
+	unaryInterceptors, streamInterceptors, err := initInterceptors(daemonMetrics)
+	if err != nil {
+		log.Fatalf("Failed to configure audit log: %v", err)
+	}
+	options = append(options,
+		tlsState.serverOption(localSocketPath),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+
+		// Clients ping every 30 seconds to keep long-running requests (e.g.
+		// `levity run`) alive, which the default policy would reject as
+		// abusive
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime: 15 * time.Second,
+		}))
+
+	listeners, err := listen(addrs)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if localSocketPath != "" {
+		mode, err := parseSocketMode(localSocketMode)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		gid, err := lookupGroup(localSocketGroup)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		local, err := listenLocal(localSocketPath, mode, gid)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		listeners = append(listeners, local)
 	}
 
 	// NB: Some system tests look for the following line to know where
 	//     to point their clients. This interlock method is pretty
 	//     flakey, and I'd definitely be looking to replace it in a
 	//     live system
-	log.Printf("Listening on %s", listener.Addr().String())
+	for _, listener := range listeners {
+		log.Printf("Listening on %s", listener.Addr().String())
+	}
+
+	envPolicy, err := initEnvironment()
+	if err != nil {
+		log.Fatalf("Failed to configure task environment: %v", err)
+	}
 
-	taskMan := taskmanager.New()
+	serverOptions := []taskmanager.Option{
+		taskmanager.WithEnvironment(envPolicy),
+		taskmanager.WithRegistry(tasks),
+		taskmanager.WithLimits(taskmanager.Limits{
+			MaxTasks:        maxTasks,
+			MaxTasksPerUser: maxTasksPerUser,
+			MaxOutputBytes:  maxLogBytes,
+		}),
+		taskmanager.WithRetention(logRetention),
+	}
+	if daemonMetrics != nil {
+		serverOptions = append(serverOptions, taskmanager.WithObserver(daemonMetrics))
+	}
+	if commandPolicyPath != "" {
+		log.Printf("Loading command policy from %s", commandPolicyPath)
+		commandPolicy, err := policy.LoadCommandAllowlist(commandPolicyPath)
+		if err != nil {
+			log.Fatalf("Failed to load command policy: %v", err)
+		}
+		serverOptions = append(serverOptions, taskmanager.WithCommandPolicy(commandPolicy))
+	}
+
+	if authPolicyPath != "" {
+		log.Printf("Loading authorisation policy from %s", authPolicyPath)
+		authPolicy, err := policy.LoadRolePolicy(authPolicyPath)
+		if err != nil {
+			log.Fatalf("Failed to load authorisation policy: %v", err)
+		}
+		serverOptions = append(serverOptions, taskmanager.WithAuthorisationPolicy(authPolicy))
+	}
+
+	taskMan := taskmanager.New(serverOptions...)
 
 	grpcServer := grpc.NewServer(options...)
 	api.RegisterTaskManagerServer(grpcServer, taskMan)
+	reflection.Register(grpcServer)
+
+	healthMonitor := initHealth(taskMan)
+	healthMonitor.Register(grpcServer)
+	go healthMonitor.Run(context.Background(), healthCheckInterval)
+
+	d := &shutdown{
+		grpcServer:    grpcServer,
+		taskMan:       taskMan,
+		healthMonitor: healthMonitor,
+		policy:        onShutdown,
+		stopTracing:   stopTracing,
+		failed:        make(chan error, 1),
+		done:          make(chan struct{}),
+	}
+	go d.onSignal()
+
 	log.Printf("Serving requests")
-	err = grpcServer.Serve(listener)
-	if err != nil {
-		log.Fatalf("GRPC server faled to start: %v", err)
+	for _, listener := range listeners {
+		go func(l net.Listener) {
+			// A shutdown that starts before every listener is being served
+			// stops the server first, which isn't a failure
+			err := grpcServer.Serve(l)
+			if err != nil && err != grpc.ErrServerStopped {
+				d.fail(err)
+			}
+		}(listener)
+	}
+
+	// Wait for the shutdown to finish before exiting, however it was started
+	<-d.done
+	if d.err != nil {
+		log.Fatalf("Shutdown complete, after the GRPC server failed to serve requests: %v", d.err)
 	}
+	log.Printf("Shutdown complete")
 }
 
-// Maps the certificate supplied by the user to a levity daemon user account
-// and injects that user into the context supplied to the handler.
-func authenticateRequest(
-	ctx context.Context,
-	req interface{},
-	info *grpc.UnaryServerInfo,
-	handler grpc.UnaryHandler) (interface{}, error) {
-
-	p, ok := peer.FromContext(ctx)
-	if !ok {
-		// TODO: make a more useful/descriptive error type
-		return nil, errors.New("No Peer")
-	}
-
-	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
-	if !ok {
-		// TODO: make a more useful/descriptive error type
-		return nil, errors.New("No TLS Info")
-	}
-
-	if len(tlsInfo.State.PeerCertificates) == 0 {
-		// Not sure if it's even possible to get here, as the TLS configuration
-		// requires a client certificate to be verified before this is even
-		// called. Probably good to check here anyway, though, in case the TLS
-		// config changes.
-		return nil, errors.New("No client certificate")
-	}
-	clientCertificate := tlsInfo.State.PeerCertificates[0]
-
-	// In a real application, a lot more validation would need to go here (e.g.
-	// checking a user database, consulting certificate revocation lists,
-	// figuring out the claims the user has on the server, etc). The way a
-	// certificate is mapped to a user would *definitely* need to be more
-	// sophisticated.
-	//
-	// You might even go so far as to introduce a second `authorisation`
-	// service to do all this once and issue a token of some sort that that
-	// can be reused without having to reload it every call.
-	//
-	// But for this exercise, for the sake of simplicity, we're just going to
-	// assume that
-	//  1. Holding a valid certificate implies you are an authorised user, and
-	//  2. The certificate CN is a unique identifier for user on the system.
-	//
-	// (NB: I would *not* consider these good assumptionions for a production
-	//	system)
-	loginName := clientCertificate.Subject.CommonName
-	ctxWithUser := user.NewContext(ctx, user.New(loginName))
-	return handler(ctxWithUser, req)
+// initHealth creates the health monitor that reports whether the daemon is
+// able to serve requests, and runs the initial health check
+func initHealth(taskMan *taskmanager.Server) *health.Monitor {
+	m := health.NewMonitor(taskManagerService)
+	m.AddCheck("registry", taskMan.Check)
+	if err := m.CheckNow(context.Background(), healthCheckInterval); err != nil {
+		log.Printf("Initial health check failed: %v", err)
+	}
+	return m
 }
 
 func main() {
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tcsc/levity/health"
+	"github.com/tcsc/levity/taskmanager"
+	"google.golang.org/grpc"
+)
+
+// gracefulStopTimeout bounds how long in-flight requests have to complete
+// once the server has stopped accepting new connections
+const gracefulStopTimeout = 10 * time.Second
+
+// shutdown takes the daemon down in an orderly fashion when it is asked to
+// terminate:
+//
+//  1. The daemon is reported as not serving, so that load balancers stop
+//     sending it new requests
+//  2. New tasks are refused, and the shutdown policy is applied to any
+//     tasks that are still running. Requests about existing tasks are
+//     still answered in the meantime.
+//  3. The GRPC server stops accepting connections, and finishes off any
+//     in-flight requests
+//  4. Any buffered trace spans are flushed
+//
+// The same happens if the GRPC server fails while serving requests, and the
+// daemon then exits with an error.
+//
+// A second signal abandons waiting for tasks, and stops the server
+// immediately.
+type shutdown struct {
+	grpcServer    *grpc.Server
+	taskMan       *taskmanager.Server
+	healthMonitor *health.Monitor
+	policy        taskmanager.ShutdownPolicy
+	stopTracing   func(context.Context) error
+
+	// failed receives the error that made the GRPC server stop serving, if
+	// it didn't stop because of a shutdown
+	failed chan error
+
+	// err is the error that the shutdown was started for, if any. It may only
+	// be read once done has been closed.
+	err error
+
+	// done is closed once the shutdown has completed
+	done chan struct{}
+}
+
+// fail starts a shutdown because the GRPC server has stopped serving
+// requests on one of its listeners
+func (d *shutdown) fail(err error) {
+	log.Printf("GRPC server failed: %v", err)
+	select {
+	case d.failed <- err:
+	default:
+		// A shutdown is already underway
+	}
+}
+
+// onSignal waits for SIGTERM or SIGINT, or for the GRPC server to fail, and
+// then shuts the daemon down
+func (d *shutdown) onSignal() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	select {
+	case sig := <-signals:
+		log.Printf("Received %v, shutting down", sig)
+	case d.err = <-d.failed:
+		log.Printf("Shutting down")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("Received %v again, shutting down immediately", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	d.run(ctx)
+	cancel()
+}
+
+func (d *shutdown) run(ctx context.Context) {
+	defer close(d.done)
+
+	d.healthMonitor.Shutdown()
+
+	if err := d.taskMan.Shutdown(ctx, d.policy); err != nil {
+		log.Printf("Failed to stop tasks: %v", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		d.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		d.grpcServer.Stop()
+	case <-time.After(gracefulStopTimeout):
+		log.Printf("Timed out waiting for requests to complete")
+		d.grpcServer.Stop()
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.stopTracing(flushCtx); err != nil {
+		log.Printf("Failed to flush trace spans: %v", err)
+	}
+}
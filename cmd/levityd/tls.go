@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/tcsc/levity/authn"
+	"github.com/tcsc/levity/reload"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// serverTLS holds the daemon's current TLS configuration. The server's key
+// pair and the client CA bundle are re-read from disk on SIGHUP or whenever
+// the files change, so that certificates can be rotated without restarting
+// the daemon (and killing all of its tasks). New connections use the most
+// recently loaded configuration; existing connections are unaffected.
+type serverTLS struct {
+	// revocations checks client certificates for revocation. May be nil if
+	// no CRLs or deny-list have been configured.
+	revocations *authn.Revocations
+
+	lock   sync.RWMutex
+	config *tls.Config
+}
+
+func expandPaths() error {
+	s, err := filepath.Abs(certificatePath)
+	if err != nil {
+		return err
+	}
+	certificatePath = s
+
+	s, err = filepath.Abs(privateKeyPath)
+	if err != nil {
+		return err
+	}
+
+	privateKeyPath = s
+
+	// NB: Connections are recognised as being to the local socket by the
+	//     address they were accepted on, which is only reported as an
+	//     absolute path if the socket was created with one.
+	if localSocketPath != "" {
+		s, err = filepath.Abs(localSocketPath)
+		if err != nil {
+			return err
+		}
+		localSocketPath = s
+	}
+
+	return nil
+}
+
+// loadTLSConfig reads the server key pair and client CA bundle from disk,
+// returning the resulting TLS config and the parsed client CA certificates.
+func loadTLSConfig() (*tls.Config, []*x509.Certificate, error) {
+	idCert, err := tls.LoadX509KeyPair(certificatePath, privateKeyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Clients that authenticate with a bearer token won't have a client
+	// certificate, but any certificate that *is* supplied must be valid.
+	clientAuth := tls.RequireAndVerifyClientCert
+	if jwksPath != "" {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{idCert},
+		ClientAuth:   clientAuth,
+		MinVersion:   tls.VersionTLS13,
+
+		// NB: The config we return from `GetConfigForClient` is used as-is,
+		//     so we need to advertise HTTP/2 ourselves rather than relying on
+		//     the GRPC credentials to do it for us.
+		NextProtos: []string{"h2"},
+	}
+
+	if clientCACertPath == "" {
+		return tlsCfg, nil, nil
+	}
+
+	cert, err := ioutil.ReadFile(clientCACertPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(cert) {
+		return nil, nil, errors.New("Failed to append certificate")
+	}
+	tlsCfg.ClientCAs = certPool
+
+	cas, err := authn.ParseCertificates(cert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tlsCfg, cas, nil
+}
+
+func initTLS() (*serverTLS, error) {
+	log.Printf("Loading TLS certificate from %s", certificatePath)
+	log.Printf("Loading private key from %s", privateKeyPath)
+	log.Printf("Client CA Cert path: %s", clientCACertPath)
+
+	if clientCACertPath == "" && len(crlPaths) > 0 {
+		return nil, errors.New("Checking CRLs requires an explicit client CA")
+	}
+
+	tlsCfg, cas, err := loadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &serverTLS{config: tlsCfg}
+
+	if len(crlPaths) > 0 || denyListPath != "" {
+		s.revocations, err = authn.NewRevocations(cas, crlPaths, denyListPath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded certificate revocation data from %v", s.revocations.Paths())
+		tlsCfg.VerifyConnection = s.revocations.VerifyConnection
+	}
+
+	return s, nil
+}
+
+// reload re-reads the TLS material from disk. If anything fails to load, the
+// existing configuration is left in place and an error returned.
+func (s *serverTLS) reload() error {
+	tlsCfg, cas, err := loadTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	if s.revocations != nil {
+		// CRLs signed by a newly-rotated CA will only load once we trust the
+		// new CA, so reload them as well. A failure here shouldn't stop the
+		// new certificates from being used, though, as the previous
+		// revocation data is still in effect.
+		s.revocations.SetCAs(cas)
+		if err := s.revocations.Reload(); err != nil {
+			log.Printf("Failed to reload certificate revocation data: %v", err)
+		}
+		tlsCfg.VerifyConnection = s.revocations.VerifyConnection
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.config = tlsCfg
+
+	return nil
+}
+
+// getConfigForClient supplies the current TLS configuration for each new
+// connection. Suitable for use as a `tls.Config.GetConfigForClient` hook.
+func (s *serverTLS) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.config, nil
+}
+
+// serverOption creates the GRPC server credentials that will use whatever
+// TLS configuration is current when a client connects. Connections to the
+// local socket (if any) bypass TLS, and are identified by their peer
+// credentials instead.
+func (s *serverTLS) serverOption(localSocket string) grpc.ServerOption {
+	creds := credentials.NewTLS(&tls.Config{
+		GetConfigForClient: s.getConfigForClient,
+		MinVersion:         tls.VersionTLS13,
+	})
+
+	if localSocket != "" {
+		creds = authn.LocalCredentials(creds, localSocket)
+	}
+
+	return grpc.Creds(creds)
+}
+
+// watch reloads the TLS configuration whenever the daemon receives a SIGHUP
+// or any of the TLS files change, and the revocation data whenever any of
+// its files change. Blocks until the context is cancelled, so will usually be
+// run in its own goroutine.
+func (s *serverTLS) watch(ctx context.Context) {
+	reloadTLS := func() {
+		if err := s.reload(); err != nil {
+			log.Printf("Failed to reload TLS configuration: %v", err)
+			return
+		}
+		log.Printf("Reloaded TLS configuration")
+	}
+
+	paths := []string{certificatePath, privateKeyPath}
+	if clientCACertPath != "" {
+		paths = append(paths, clientCACertPath)
+	}
+	go reload.Watch(ctx, reload.DefaultInterval, reloadTLS, paths...)
+
+	if s.revocations != nil {
+		go reload.Watch(ctx, reload.DefaultInterval, func() {
+			if err := s.revocations.Reload(); err != nil {
+				log.Printf("Failed to reload certificate revocation data: %v", err)
+			}
+		}, s.revocations.Paths()...)
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-hangup:
+			log.Printf("Received SIGHUP")
+			reloadTLS()
+		}
+	}
+}
@@ -2,19 +2,39 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/user"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 )
 
 var portPattern = regexp.MustCompile(`Listening on 127.0.0.1:(?P<port>\d+)`)
@@ -62,14 +82,23 @@ type daemon struct {
 }
 
 // startDaemon starts the levity daemon on the loopback address,
-// wait for it to start up, and hand back a handle to it.
-func startDaemon() (*daemon, error) {
-	cmd := exec.Command(
-		"levityd", "127.0.0.1:0",
+// wait for it to start up, and hand back a handle to it. Any extra
+// arguments are passed on to the daemon.
+func startDaemon(extraArgs ...string) (*daemon, error) {
+	return startDaemonProbedAs("alice", extraArgs...)
+}
+
+// startDaemonProbedAs starts the levity daemon as per `startDaemon`, but
+// checks its health as the given user. Tests that deliberately configure the
+// daemon to reject every user can skip the health check with an empty login.
+func startDaemonProbedAs(probeLogin string, extraArgs ...string) (*daemon, error) {
+	args := []string{
+		"127.0.0.1:0",
 		"--certificate", "../cert/svr-cert.pem",
 		"--key", "../cert/svr-key.pem",
 		"--client-ca", "../cert/client-ca-cert.pem",
-	)
+	}
+	cmd := exec.Command("levityd", append(args, extraArgs...)...)
 	cmd.Stdout = os.Stdout
 
 	stderr := &portExtractor{
@@ -86,19 +115,80 @@ func startDaemon() (*daemon, error) {
 
 	d := daemon{cmd: cmd}
 
-	// Wait for the server to tell us what port it is listening on.
+	// Wait for the server to tell us what port it is listening on, and
+	// then for it to report itself as healthy.
 	select {
 	case port := <-stderr.ch:
 		d.port = port
-		return &d, nil
 
 	case <-time.After(1 * time.Second):
 		d.kill()
 		return nil, errors.New("Timed out waiting for data on stdout")
 	}
+
+	if probeLogin != "" {
+		if err := d.awaitHealthy(probeLogin, 5*time.Second); err != nil {
+			d.kill()
+			return nil, err
+		}
+	}
+
+	return &d, nil
+}
+
+// dial connects to the daemon as the given user
+func (d *daemon) dial(login string) (*grpc.ClientConn, error) {
+	cert, err := tls.LoadX509KeyPair(
+		fmt.Sprintf("../cert/%s-cert.pem", login),
+		fmt.Sprintf("../cert/%s-key.pem", login))
+	if err != nil {
+		return nil, err
+	}
+
+	caCert, err := ioutil.ReadFile("../cert/svr-ca-cert.pem")
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caCert)
+
+	creds := credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: roots})
+	return grpc.Dial(d.addr(), grpc.WithTransportCredentials(creds))
+}
+
+// awaitHealthy waits for the daemon's health service to report it as
+// serving
+func (d *daemon) awaitHealthy(login string, timeout time.Duration) error {
+	conn, err := d.dial(login)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := healthpb.NewHealthClient(conn)
+	for {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+		if err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Timed out waiting for daemon to become healthy: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
 }
 
 func (d *daemon) kill() {
+	if d.cmd.ProcessState != nil {
+		// Already exited, and been waited for
+		return
+	}
+
 	log.Print("Killing daemon.")
 	defer log.Print("Daemon killed")
 	if err := d.cmd.Process.Kill(); err != nil {
@@ -120,8 +210,17 @@ func levity(login string, addr string, argv ...string) (string, error) {
 		"-k", fmt.Sprintf("../cert/%s-key.pem", login),
 		"--ca", "../cert/svr-ca-cert.pem",
 	}
+	return runLevity(append(args, argv...)...)
+}
 
-	client := exec.Command("levity", append(args, argv...)...)
+// levityLocal executes the levity client against the daemon's local socket,
+// without any credentials of its own, returning the collected stdout stream.
+func levityLocal(socket string, argv ...string) (string, error) {
+	return runLevity(append([]string{"-a", "unix://" + socket}, argv...)...)
+}
+
+func runLevity(args ...string) (string, error) {
+	client := exec.Command("levity", args...)
 	output, err := client.Output()
 	if err != nil {
 		exitErr := err.(*exec.ExitError)
@@ -199,6 +298,213 @@ func Test_Client_ReturnsNonZero_OnNoSuchTask(t *testing.T) {
 	require.Error(err)
 	exitErr := err.(*exec.ExitError)
 	require.NotEqual(0, exitErr.ExitCode())
+
+	// ... and that a missing task can be told apart from other failures
+	require.Equal(66, exitErr.ExitCode())
+	require.Contains(string(exitErr.Stderr), "reason=NO_SUCH_TASK")
+}
+
+func Test_Client_WritesStructuredOutput(t *testing.T) {
+	require := require.New(t)
+
+	daemon, err := startDaemon()
+	require.NoError(err)
+	defer daemon.kill()
+
+	// When I start a task, asking for JSON output
+	stdout, err := levity("alice", daemon.addr(), "-o", "json", "start", "--", "echo", "hello")
+	require.NoError(err)
+
+	// Expect that the task ID is written as a JSON document
+	var started struct {
+		TaskID string `json:"task_id"`
+	}
+	require.NoError(json.Unmarshal([]byte(stdout), &started))
+	require.NotEmpty(started.TaskID)
+	require.NoError(awaitTask("alice", started.TaskID, daemon, 5*time.Second))
+
+	// When I query the finished task
+	stdout, err = levity("alice", daemon.addr(), "--output", "json", "query", started.TaskID)
+	require.NoError(err)
+
+	// Expect the status and exit code to be fields of the document
+	require.JSONEq(fmt.Sprintf(
+		`{"task_id": %q, "status": "Finished", "exit_code": 0, "signal": null}`, started.TaskID), stdout)
+
+	// When I fetch the task's logs as YAML
+	stdout, err = levity("alice", daemon.addr(), "-o", "yaml", "logs", started.TaskID)
+	require.NoError(err)
+
+	// Expect the output to be a field of the document
+	require.Contains(stdout, "stdout_bytes: 6")
+	require.Contains(stdout, "stdout: |\n  hello")
+
+	// Expect that an unknown format is rejected
+	_, err = levity("alice", daemon.addr(), "-o", "xml", "query", started.TaskID)
+	require.Error(err)
+}
+
+func Test_Client_RetriesWhileServerUnavailable(t *testing.T) {
+	require := require.New(t)
+
+	// Given an address that nothing is listening on yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	addr := l.Addr().String()
+	require.NoError(l.Close())
+
+	// When I query a task there, allowing plenty of retries
+	client := exec.Command("levity",
+		"-a", addr,
+		"-c", "../cert/alice-cert.pem",
+		"-k", "../cert/alice-key.pem",
+		"--ca", "../cert/svr-ca-cert.pem",
+		"--timeout", "15s",
+		"--retries", "10",
+		"query", "no-such-task")
+	require.NoError(client.Start())
+	defer client.Process.Kill()
+
+	// ... and the server only starts listening after the first attempt
+	<-time.After(500 * time.Millisecond)
+	daemon, err := startDaemon(addr)
+	require.NoError(err)
+	defer daemon.kill()
+
+	// Expect that the query eventually reaches the server, and fails
+	// because the task doesn't exist, rather than because the server was
+	// unavailable
+	err = client.Wait()
+	require.Error(err)
+	require.Equal(66, err.(*exec.ExitError).ExitCode())
+}
+
+func Test_Client_Run(t *testing.T) {
+	require := require.New(t)
+
+	daemon, err := startDaemon()
+	require.NoError(err)
+	defer daemon.kill()
+
+	// When I run a task that writes to both of its output streams, and then
+	// fails
+	client := exec.Command("levity",
+		"-a", daemon.addr(),
+		"-c", "../cert/alice-cert.pem",
+		"-k", "../cert/alice-key.pem",
+		"--ca", "../cert/svr-ca-cert.pem",
+		"run", "--", "sh", "-c", "echo out; sleep 0.5; echo err >&2; exit 3")
+	var stdout, stderr bytes.Buffer
+	client.Stdout = &stdout
+	client.Stderr = &stderr
+	err = client.Run()
+
+	// Expect the client to exit with the task's exit code, having written
+	// the task's output to the corresponding local streams
+	require.Error(err)
+	require.Equal(3, err.(*exec.ExitError).ExitCode())
+	require.Equal("out\n", stdout.String())
+	require.Contains(stderr.String(), "err\n")
+
+	// When I run a task that succeeds
+	out, err := levity("alice", daemon.addr(), "run", "--", "echo", "hello")
+
+	// Expect the client to succeed too
+	require.NoError(err)
+	require.Equal("hello", out)
+}
+
+func Test_Client_Run_ForwardsInterrupt(t *testing.T) {
+	require := require.New(t)
+
+	daemon, err := startDaemon()
+	require.NoError(err)
+	defer daemon.kill()
+
+	// Given a task that runs until it's told to quit
+	client := exec.Command("levity",
+		"-a", daemon.addr(),
+		"-c", "../cert/alice-cert.pem",
+		"-k", "../cert/alice-key.pem",
+		"--ca", "../cert/svr-ca-cert.pem",
+		"run", "--", "sh", "-c", "echo ready; exec sleep 60")
+	stdout, err := client.StdoutPipe()
+	require.NoError(err)
+	require.NoError(client.Start())
+	defer client.Process.Kill()
+
+	line := make([]byte, len("ready\n"))
+	_, err = io.ReadFull(stdout, line)
+	require.NoError(err)
+	require.Equal("ready\n", string(line))
+
+	// When I interrupt the client
+	require.NoError(client.Process.Signal(os.Interrupt))
+
+	// Expect that the task is signalled, and that the client exits as if it
+	// had been killed by the same signal
+	done := make(chan error, 1)
+	go func() { done <- client.Wait() }()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow("Timed out waiting for the client to exit")
+	}
+	require.Error(err)
+	require.Equal(128+int(syscall.SIGTERM), err.(*exec.ExitError).ExitCode())
+}
+
+func Test_Client_QueriesAndSignalsBatches(t *testing.T) {
+	require := require.New(t)
+
+	daemon, err := startDaemon()
+	require.NoError(err)
+	defer daemon.kill()
+
+	// Given a couple of labelled tasks that run forever, and one that doesn't
+	var workers []string
+	for i := 0; i < 2; i++ {
+		id, err := levity("alice", daemon.addr(), "start", "-l", "job=worker", "--", "sleep", "60")
+		require.NoError(err)
+		workers = append(workers, id)
+	}
+	other, err := levity("alice", daemon.addr(), "start", "-l", "job=other", "--", "sleep", "60")
+	require.NoError(err)
+	defer levity("alice", daemon.addr(), "signal", other)
+
+	// When I query all of them by ID
+	stdout, err := levity("alice", daemon.addr(), "query", workers[0], workers[1], other)
+
+	// Expect a line for each, with its status
+	require.NoError(err)
+	lines := strings.Split(stdout, "\n")
+	require.Len(lines, 3)
+	for _, id := range append(workers, other) {
+		require.Contains(lines, id+" Running")
+	}
+
+	// When I signal all of the running workers
+	stdout, err = levity("alice", daemon.addr(), "signal", "--all", "--status", "Running", "-l", "job=worker")
+
+	// Expect that only the workers were signalled
+	require.NoError(err)
+	require.ElementsMatch(workers, strings.Split(stdout, "\n"))
+	for _, id := range workers {
+		require.NoError(awaitTask("alice", id, daemon, 5*time.Second))
+	}
+
+	stdout, err = levity("alice", daemon.addr(), "query", other)
+	require.NoError(err)
+	require.Equal("Running", stdout)
+
+	// When I query a batch that includes a task that doesn't exist
+	_, err = levity("alice", daemon.addr(), "query", other, "no-such-task")
+
+	// Expect the client to fail as it would for that task alone
+	require.Error(err)
+	exitErr := err.(*exec.ExitError)
+	require.Equal(66, exitErr.ExitCode())
+	require.Contains(string(exitErr.Stderr), "reason=NO_SUCH_TASK")
 }
 
 func Test_Client_ReturnsNonZero_OnNoServer(t *testing.T) {
@@ -206,6 +512,10 @@ func Test_Client_ReturnsNonZero_OnNoServer(t *testing.T) {
 	require.Error(t, err)
 	exitErr := err.(*exec.ExitError)
 	require.NotEqual(t, 0, exitErr.ExitCode())
+
+	// ... and that an unreachable server can be told apart from other
+	// failures
+	require.Equal(t, 69, exitErr.ExitCode())
 }
 
 func Test_Client_ReturnsNonZero_OnInvalidSeverCert(t *testing.T) {
@@ -268,3 +578,515 @@ func Test_Client_ReturnsNonZero_OnObsoleteTLS(t *testing.T) {
 	require.Contains(
 		string(exitErr.Stderr), "protocol version not supported")
 }
+
+func Test_Client_ReturnsNonZero_OnRevokedCertificate(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server with a deny-list containing Alice's
+	// certificate
+	pemData, err := ioutil.ReadFile("../cert/alice-cert.pem")
+	require.NoError(err)
+	block, _ := pem.Decode(pemData)
+	require.NotNil(block)
+	fingerprint := sha256.Sum256(block.Bytes)
+
+	denyListPath := path.Join(t.TempDir(), "deny-list")
+	require.NoError(ioutil.WriteFile(denyListPath,
+		[]byte(fmt.Sprintf("sha256:%x\n", fingerprint)), 0600))
+
+	daemon, err := startDaemonProbedAs("", "--deny-list", denyListPath)
+	require.NoError(err)
+	defer daemon.kill()
+
+	// When Alice attempts to issue a command
+	_, err = levity("alice", daemon.addr(), "start", "echo", "hello world")
+
+	// Expect that the request fails and the client's exit code is non 0
+	require.Error(err)
+	exitErr := err.(*exec.ExitError)
+	require.NotEqual(0, exitErr.ExitCode())
+}
+
+func Test_Daemon_ReloadsClientCA_OnHangup(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server that trusts the wrong client CA
+	caPath := path.Join(t.TempDir(), "client-ca-cert.pem")
+	wrongCA, err := ioutil.ReadFile("../cert/svr-ca-cert.pem")
+	require.NoError(err)
+	require.NoError(ioutil.WriteFile(caPath, wrongCA, 0600))
+
+	daemon, err := startDaemonProbedAs("chuck", "--client-ca", caPath)
+	require.NoError(err)
+	defer daemon.kill()
+
+	_, err = levity("alice", daemon.addr(), "start", "echo", "hello world")
+	require.Error(err)
+
+	// When the client CA is replaced and the daemon receives a SIGHUP
+	rightCA, err := ioutil.ReadFile("../cert/client-ca-cert.pem")
+	require.NoError(err)
+	require.NoError(ioutil.WriteFile(caPath, rightCA, 0600))
+	require.NoError(daemon.cmd.Process.Signal(syscall.SIGHUP))
+
+	// Expect that Alice's certificate is eventually accepted, without the
+	// daemon having been restarted
+	require.Eventually(func() bool {
+		_, err := levity("alice", daemon.addr(), "start", "echo", "hello world")
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// mintToken creates a JWKS file containing a fresh ed25519 key, and a token
+// for the given login signed by that key
+func mintToken(t *testing.T, login string) (jwksPath string, token string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	jwksPath = path.Join(t.TempDir(), "jwks.json")
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"ci","x":"%s"}]}`, b64(pub))
+	require.NoError(t, ioutil.WriteFile(jwksPath, []byte(jwks), 0600))
+
+	header := b64([]byte(`{"alg":"EdDSA","kid":"ci","typ":"JWT"}`))
+	claims := b64([]byte(fmt.Sprintf(`{"sub":"%s","exp":%d}`, login, time.Now().Add(time.Hour).Unix())))
+	signature := ed25519.Sign(priv, []byte(header+"."+claims))
+	return jwksPath, header + "." + claims + "." + b64(signature)
+}
+
+func Test_Client_AuthenticatesWithToken(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server that accepts bearer tokens
+	jwksPath, token := mintToken(t, "ci-runner")
+	daemon, err := startDaemon("--jwks", jwksPath)
+	require.NoError(err)
+	defer daemon.kill()
+
+	// When I start a task using only a token to authenticate
+	client := exec.Command("levity",
+		"-a", daemon.addr(), "--ca", "../cert/svr-ca-cert.pem",
+		"start", "echo", "hello world")
+	client.Env = append(os.Environ(), "LEVITY_TOKEN="+token)
+	output, err := client.Output()
+	require.NoError(err)
+	taskID := strings.TrimSpace(string(output))
+
+	// Expect that the task belongs to the token's user, and not to some
+	// other user with a certificate
+	_, err = levity("alice", daemon.addr(), "query", taskID)
+	require.Error(err)
+
+	// ... and that a client with neither a token nor a certificate is
+	// rejected
+	client = exec.Command("levity",
+		"-a", daemon.addr(), "--ca", "../cert/svr-ca-cert.pem",
+		"start", "echo", "hello world")
+	client.Env = append(os.Environ(), "LEVITY_TOKEN=")
+	err = client.Run()
+	require.Error(err)
+}
+
+func Test_Daemon_WritesAuditLog(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server with an audit log
+	auditLogPath := path.Join(t.TempDir(), "audit.log")
+	daemon, err := startDaemon("--audit-log", auditLogPath)
+	require.NoError(err)
+	defer daemon.kill()
+
+	// When I start a task, and query a task that doesn't exist
+	taskID, err := levity("alice", daemon.addr(), "start", "echo", "hello world")
+	require.NoError(err)
+	_, err = levity("alice", daemon.addr(), "query", "no-such-task")
+	require.Error(err)
+
+	// Expect that both requests are recorded in the audit log
+	content, err := ioutil.ReadFile(auditLogPath)
+	require.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(lines, 2)
+
+	var start, query map[string]interface{}
+	require.NoError(json.Unmarshal([]byte(lines[0]), &start))
+	require.NoError(json.Unmarshal([]byte(lines[1]), &query))
+
+	require.Equal("alice", start["user"])
+	require.Equal("start", start["action"])
+	require.Equal(taskID, start["task_id"])
+	require.Equal("echo", start["binary"])
+	require.Equal([]interface{}{"hello world"}, start["args"])
+	require.Equal("OK", start["code"])
+	require.NotEmpty(start["certificate_sha256"])
+	require.Contains(start["peer"], "127.0.0.1:")
+
+	require.Equal("query", query["action"])
+	require.Equal("no-such-task", query["task_id"])
+	require.Equal("NotFound", query["code"])
+}
+
+func Test_Daemon_ServesMetrics(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server with metrics enabled on a free port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	metricsAddr := l.Addr().String()
+	require.NoError(l.Close())
+
+	daemon, err := startDaemon("--metrics-addr", metricsAddr)
+	require.NoError(err)
+	defer daemon.kill()
+
+	// When I start a task and wait for it to finish
+	taskID, err := levity("alice", daemon.addr(), "start", "echo", "hello world")
+	require.NoError(err)
+	require.NoError(awaitTask("alice", taskID, daemon, 5*time.Second))
+
+	// Expect that the metrics endpoint reports the task and the requests
+	// made about it. The task's completion is recorded asynchronously, so
+	// may take a moment to show up.
+	scrape := func() string {
+		resp, err := http.Get("http://" + metricsAddr + "/metrics")
+		require.NoError(err)
+		defer resp.Body.Close()
+		require.Equal(http.StatusOK, resp.StatusCode)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(err)
+		return string(body)
+	}
+
+	finished := `levity_tasks_finished_total{status="Finished"} 1` + "\n"
+	require.Eventually(func() bool {
+		return strings.Contains(scrape(), finished)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	body := scrape()
+	require.Contains(body, "levity_tasks_started_total 1\n")
+	require.Contains(body, "levity_tasks_running 0\n")
+	require.Contains(body, `levity_rpc_requests_total{code="OK",method="/levity.TaskManager/StartTask"} 1`+"\n")
+	require.Contains(body, "levity_registry_tasks 1\n")
+}
+
+func Test_Client_PropagatesTraceContext(t *testing.T) {
+	require := require.New(t)
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceFile := path.Join(t.TempDir(), "spans.json")
+
+	// Given a running levity server
+	daemon, err := startDaemon()
+	require.NoError(err)
+	defer daemon.kill()
+
+	// When I start a task from a client that is itself part of a trace
+	client := exec.Command("levity",
+		"-a", daemon.addr(),
+		"-c", "../cert/alice-cert.pem",
+		"-k", "../cert/alice-key.pem",
+		"--ca", "../cert/svr-ca-cert.pem",
+		"--trace-file", traceFile,
+		"start", "--", "sh", "-c", "echo $TRACEPARENT")
+	client.Env = append(os.Environ(), "TRACEPARENT=00-"+traceID+"-00f067aa0ba902b7-01")
+	output, err := client.Output()
+	require.NoError(err)
+	taskID := strings.TrimSpace(string(output))
+	require.NoError(awaitTask("alice", taskID, daemon, 5*time.Second))
+
+	// Expect that the task was handed a trace context in the same trace
+	stdout, err := levity("alice", daemon.addr(), "logs", taskID)
+	require.NoError(err)
+	require.Regexp("^00-"+traceID+"-[0-9a-f]{16}-01$", stdout)
+
+	// ... and that the client exported its own spans in that trace, too
+	spans, err := ioutil.ReadFile(traceFile)
+	require.NoError(err)
+	require.Contains(string(spans), traceID)
+	require.Contains(string(spans), `"Name":"levity start"`)
+}
+
+func Test_Daemon_ReportsHealth(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server
+	daemon, err := startDaemon()
+	require.NoError(err)
+	defer daemon.kill()
+
+	conn, err := daemon.dial("alice")
+	require.NoError(err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Expect that the TaskManager service is reported as serving
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx,
+		&healthpb.HealthCheckRequest{Service: "levity.TaskManager"})
+	require.NoError(err)
+	require.Equal(healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	// ... and that it can be discovered by reflection
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	require.NoError(err)
+	require.NoError(stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	reflected, err := stream.Recv()
+	require.NoError(err)
+
+	var services []string
+	for _, service := range reflected.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	require.Contains(services, "levity.TaskManager")
+	require.Contains(services, "grpc.health.v1.Health")
+
+	// When the daemon is asked to terminate, with no requests in flight
+	require.NoError(conn.Close())
+	require.NoError(daemon.cmd.Process.Signal(syscall.SIGTERM))
+
+	// Expect that it exits cleanly
+	require.NoError(daemon.cmd.Wait())
+}
+
+func Test_Daemon_StopsTasks_OnShutdown(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server with a long-running task, which tells
+	// us its PID
+	daemon, err := startDaemon("--shutdown-policy", "signal")
+	require.NoError(err)
+	defer daemon.kill()
+
+	taskID, err := levity("alice", daemon.addr(), "start", "--", "sh", "-c", "echo $$; exec sleep 60")
+	require.NoError(err)
+
+	var pid int
+	require.Eventually(func() bool {
+		stdout, err := levity("alice", daemon.addr(), "logs", taskID)
+		if err != nil || stdout == "" {
+			return false
+		}
+		pid, err = strconv.Atoi(stdout)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// When the daemon is asked to terminate
+	require.NoError(daemon.cmd.Process.Signal(syscall.SIGTERM))
+
+	// Expect that it exits cleanly and promptly...
+	exited := make(chan error, 1)
+	go func() { exited <- daemon.cmd.Wait() }()
+	select {
+	case err := <-exited:
+		require.NoError(err)
+	case <-time.After(10 * time.Second):
+		require.FailNow("Timed out waiting for daemon to exit")
+	}
+
+	// ... having stopped the task rather than leaving it orphaned
+	require.Equal(syscall.ESRCH, syscall.Kill(pid, 0))
+}
+
+func Test_Daemon_DetachesTasks_OnShutdown(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server with a task that keeps writing to its
+	// stdout, and leaves a trail in a file that we can watch
+	daemon, err := startDaemon("--shutdown-policy", "detach")
+	require.NoError(err)
+	defer daemon.kill()
+
+	trail := filepath.Join(t.TempDir(), "trail")
+	taskID, err := levity("alice", daemon.addr(), "start", "--", "sh", "-c",
+		"echo $$; while echo tick; do echo tick >> "+trail+"; sleep 0.1; done")
+	require.NoError(err)
+
+	var pid int
+	require.Eventually(func() bool {
+		stdout, err := levity("alice", daemon.addr(), "logs", taskID)
+		if err != nil || stdout == "" {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.SplitN(stdout, "\n", 2)[0])
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	defer syscall.Kill(pid, syscall.SIGKILL)
+
+	// When the daemon is asked to terminate, and exits
+	require.NoError(daemon.cmd.Process.Signal(syscall.SIGTERM))
+	exited := make(chan error, 1)
+	go func() { exited <- daemon.cmd.Wait() }()
+	select {
+	case err := <-exited:
+		require.NoError(err)
+	case <-time.After(10 * time.Second):
+		require.FailNow("Timed out waiting for daemon to exit")
+	}
+
+	// Expect that the task carries on writing to its stdout, rather than
+	// being killed when nobody is reading it any more
+	trailSize := func() int64 {
+		info, err := os.Stat(trail)
+		require.NoError(err)
+		return info.Size()
+	}
+	before := trailSize()
+	require.Eventually(func() bool { return trailSize() > before+50 }, 5*time.Second, 100*time.Millisecond)
+}
+
+func Test_Daemon_ReadsConfigFile(t *testing.T) {
+	require := require.New(t)
+
+	// Given a levity server configured from a file to also listen on a Unix
+	// socket, and to run at most one task per user
+	dir := t.TempDir()
+	socket := path.Join(dir, "levityd.sock")
+	configFile := path.Join(dir, "levityd.yaml")
+	require.NoError(ioutil.WriteFile(configFile, []byte(fmt.Sprintf(`
+listeners: ["unix:%s"]
+limits:
+  max_tasks_per_user: 1
+`, socket)), 0600))
+
+	daemon, err := startDaemon("--config", configFile)
+	require.NoError(err)
+	defer daemon.kill()
+
+	// When I start a task via the Unix socket
+	taskID, err := levity("alice", "unix://"+socket, "start", "sleep", "30")
+	require.NoError(err)
+	defer levity("alice", daemon.addr(), "signal", taskID)
+
+	// Expect that it is visible via the TCP listener too
+	status, err := levity("alice", daemon.addr(), "query", taskID)
+	require.NoError(err)
+	require.Contains(status, "Running")
+
+	// ... and that the configured limit applies
+	_, err = levity("alice", daemon.addr(), "start", "sleep", "30")
+	require.Error(err)
+}
+
+func Test_Daemon_IdentifiesLocalClients(t *testing.T) {
+	require := require.New(t)
+	me, err := user.Current()
+	require.NoError(err)
+
+	// Given a levity server with a local socket
+	dir := t.TempDir()
+	socket := path.Join(dir, "local.sock")
+	auditLog := path.Join(dir, "audit.log")
+	daemon, err := startDaemon("--local-socket", socket, "--audit-log", auditLog)
+	require.NoError(err)
+	defer daemon.kill()
+
+	// ... that's only open to the daemon's own user and group
+	info, err := os.Stat(socket)
+	require.NoError(err)
+	require.Equal(os.FileMode(0660), info.Mode().Perm())
+
+	// When I start a task via the local socket, without any credentials
+	taskID, err := levityLocal(socket, "start", "sleep", "30")
+	require.NoError(err)
+	defer levityLocal(socket, "signal", taskID)
+
+	// Expect that I can manage it via the socket
+	status, err := levityLocal(socket, "query", taskID)
+	require.NoError(err)
+	require.Contains(status, "Running")
+
+	// ... and that it's owned by my local user, rather than anyone with a
+	// certificate
+	_, err = levity("alice", daemon.addr(), "query", taskID)
+	require.Error(err)
+	require.Equal(77, err.(*exec.ExitError).ExitCode())
+
+	records, err := ioutil.ReadFile(auditLog)
+	require.NoError(err)
+	require.Contains(string(records), fmt.Sprintf(`"user":%q`, me.Username))
+}
+
+func Test_Daemon_LocalSocketInUse(t *testing.T) {
+	require := require.New(t)
+
+	// Given a levity server with a local socket that only its own user may
+	// connect to
+	socket := path.Join(t.TempDir(), "local.sock")
+	daemon, err := startDaemon("--local-socket", socket, "--local-socket-mode", "600")
+	require.NoError(err)
+	defer daemon.kill()
+
+	info, err := os.Stat(socket)
+	require.NoError(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// When I start a second server on the same local socket
+	output, err := exec.Command("levityd", "127.0.0.1:0",
+		"--certificate", "../cert/svr-cert.pem",
+		"--key", "../cert/svr-key.pem",
+		"--client-ca", "../cert/client-ca-cert.pem",
+		"--local-socket", socket).CombinedOutput()
+
+	// Expect it to refuse to start, rather than take the socket over
+	require.Error(err)
+	require.Contains(string(output), "already in use")
+
+	// ... and the first server to still be reachable through it
+	_, err = levityLocal(socket, "query", "--all")
+	require.NoError(err)
+}
+
+func Test_Client_UsesProfile(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running levity server, and a client config file with a
+	// (default) profile for connecting to it as alice, and another profile
+	// that points somewhere else
+	daemon, err := startDaemon()
+	require.NoError(err)
+	defer daemon.kill()
+
+	certs, err := filepath.Abs("../cert")
+	require.NoError(err)
+	configFile := path.Join(t.TempDir(), "config.yaml")
+	require.NoError(ioutil.WriteFile(configFile, []byte(fmt.Sprintf(`
+default_profile: alice
+profiles:
+  alice:
+    address: %[1]s
+    certificate: %[2]s/alice-cert.pem
+    key: %[2]s/alice-key.pem
+    ca: %[2]s/svr-ca-cert.pem
+  elsewhere:
+    address: 127.0.0.1:1
+    certificate: %[2]s/alice-cert.pem
+    key: %[2]s/alice-key.pem
+    ca: %[2]s/svr-ca-cert.pem
+    timeout: 2s
+`, daemon.addr(), certs)), 0600))
+
+	// When I start a task giving nothing but the config file
+	taskID, err := runLevity("--config", configFile, "start", "sleep", "30")
+	require.NoError(err)
+	defer runLevity("--config", configFile, "signal", taskID)
+
+	// Expect that it was started as alice, using the default profile
+	status, err := levity("alice", daemon.addr(), "query", taskID)
+	require.NoError(err)
+	require.Contains(status, "Running")
+
+	// ... that another profile can be chosen from the environment
+	client := exec.Command("levity", "query", taskID)
+	client.Env = append(os.Environ(), "LEVITY_CONFIG="+configFile, "LEVITY_PROFILE=elsewhere")
+	err = client.Run()
+	require.Error(err)
+	require.Equal(69, err.(*exec.ExitError).ExitCode())
+
+	// ... and that flags take precedence over the profile
+	status, err = runLevity("--config", configFile, "--profile", "elsewhere",
+		"--address", daemon.addr(), "query", taskID)
+	require.NoError(err)
+	require.Contains(status, "Running")
+}
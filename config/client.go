@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile holds the settings for connecting to a levity server. Any setting
+// left empty is left to the client's command line (or its defaults).
+type Profile struct {
+	Address     string        `yaml:"address"`
+	Certificate string        `yaml:"certificate"`
+	Key         string        `yaml:"key"`
+	CA          string        `yaml:"ca"`
+	TokenFile   string        `yaml:"token_file"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// Client describes the layout of the client's configuration file, which
+// holds a set of named server profiles, e.g.
+//
+//	default_profile: prod
+//
+//	profiles:
+//	  prod:
+//	    address: levity.example.com:4321
+//	    certificate: alice-cert.pem
+//	    key: alice-key.pem
+//	    ca: levity-ca.pem
+//	    timeout: 10s
+//
+//	  local:
+//	    address: unix:///run/levityd/local.sock
+//
+// Relative paths are taken to be relative to the directory holding the file.
+type Client struct {
+	DefaultProfile string              `yaml:"default_profile"`
+	Profiles       map[string]*Profile `yaml:"profiles"`
+}
+
+// DefaultClientPath locates the client's configuration file in the user's
+// configuration directory, i.e. `~/.config/levity/config.yaml` (or under
+// `$XDG_CONFIG_HOME`, if set)
+func DefaultClientPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "levity", "config.yaml"), nil
+}
+
+// LoadClient reads the client's configuration from a YAML file. Unknown
+// settings are an error, as they are most likely typos.
+func LoadClient(path string) (*Client, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Client
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&c); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if c.DefaultProfile != "" && c.Profiles[c.DefaultProfile] == nil {
+		return nil, fmt.Errorf("%s: default profile %q is not defined", path, c.DefaultProfile)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, p := range c.Profiles {
+		if p == nil {
+			return nil, fmt.Errorf("%s: profile %q is empty", path, name)
+		}
+		p.Certificate = resolve(dir, p.Certificate)
+		p.Key = resolve(dir, p.Key)
+		p.CA = resolve(dir, p.CA)
+		p.TokenFile = resolve(dir, p.TokenFile)
+	}
+
+	return &c, nil
+}
+
+// Profile looks up the named profile, or the default profile if the name is
+// empty. Returns nil if no name is given and there is no default profile.
+func (c *Client) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+		if name == "" {
+			return nil, nil
+		}
+	}
+
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("No such profile %q (expected one of %s)", name, c.profileNames())
+	}
+	return p, nil
+}
+
+func (c *Client) profileNames() string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
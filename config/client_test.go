@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testClientConfig = `
+default_profile: prod
+
+profiles:
+  prod:
+    address: levity.example.com:4321
+    certificate: alice-cert.pem
+    key: /etc/levity/alice-key.pem
+    ca: ../ca.pem
+    timeout: 10s
+
+  local:
+    address: unix:///run/levityd/local.sock
+`
+
+func TestLoadClient(t *testing.T) {
+	require := require.New(t)
+	filename := writeFile(t, testClientConfig)
+	dir := path.Dir(filename)
+
+	// Given a client configuration file
+	uut, err := LoadClient(filename)
+	require.NoError(err)
+
+	// Expect that the default profile is used if none is named, with
+	// relative paths resolved against the file's directory
+	p, err := uut.Profile("")
+	require.NoError(err)
+	require.Equal(&Profile{
+		Address:     "levity.example.com:4321",
+		Certificate: path.Join(dir, "alice-cert.pem"),
+		Key:         "/etc/levity/alice-key.pem",
+		CA:          path.Join(path.Dir(dir), "ca.pem"),
+		Timeout:     10 * time.Second,
+	}, p)
+
+	// ... that other profiles can be selected by name
+	p, err = uut.Profile("local")
+	require.NoError(err)
+	require.Equal(&Profile{Address: "unix:///run/levityd/local.sock"}, p)
+
+	// ... and that unknown profiles are an error
+	_, err = uut.Profile("staging")
+	require.Error(err)
+	require.Contains(err.Error(), "local, prod")
+}
+
+func TestLoadClient_NoDefault(t *testing.T) {
+	uut, err := LoadClient(writeFile(t, "profiles:\n  local:\n    address: unix:///tmp/sock\n"))
+	require.NoError(t, err)
+
+	p, err := uut.Profile("")
+	require.NoError(t, err)
+	require.Nil(t, p)
+}
+
+func TestLoadClient_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown setting", content: "profiles:\n  prod:\n    adress: localhost:1234\n"},
+		{name: "undefined default", content: "default_profile: prod\nprofiles:\n  local: {address: localhost:1234}\n"},
+		{name: "empty profile", content: "profiles:\n  prod:\n"},
+		{name: "bad timeout", content: "profiles:\n  prod: {timeout: soon}\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadClient(writeFile(t, tc.content))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDefaultClientPath(t *testing.T) {
+	require.NoError(t, os.Setenv("XDG_CONFIG_HOME", "/home/alice/.config"))
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	path, err := DefaultClientPath()
+	require.NoError(t, err)
+	require.Equal(t, "/home/alice/.config/levity/config.yaml", path)
+}
@@ -0,0 +1,303 @@
+// Package config loads the daemon's settings from a YAML configuration file,
+// and merges them with those given on its command line and in its
+// environment. It also loads the server profiles used by the client.
+//
+// Every setting in the file corresponds to one of the daemon's command-line
+// flags, and may also be set with an environment variable named after the
+// flag (e.g. `--client-ca` can be set with `LEVITYD_CLIENT_CA`). Flags take
+// precedence over environment variables, which take precedence over the
+// file.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to the (upper-cased) name of a flag to find the
+// environment variable that sets it
+const EnvPrefix = "LEVITYD_"
+
+// Daemon describes the layout of the daemon's configuration file, e.g.
+//
+//	listeners: ["0.0.0.0:8443", "unix:/run/levityd/tls.sock"]
+//	local_socket: /run/levityd/local.sock
+//
+//	tls:
+//	  certificate: /etc/levityd/svr-cert.pem
+//	  key: /etc/levityd/svr-key.pem
+//	  client_ca: /etc/levityd/client-ca.pem
+//
+//	limits:
+//	  max_tasks: 100
+//
+//	logs:
+//	  max_bytes: 1048576
+//	  retention: 24h
+//
+// Each field is tagged with the name of the flag it corresponds to. Fields
+// tagged as paths are taken to be relative to the directory holding the file.
+// Anything left out of the file is left to the flag's environment variable or
+// default.
+type Daemon struct {
+	Listeners        []string    `yaml:"listeners" flag:"listen"`
+	LocalSocket      string      `yaml:"local_socket" flag:"local-socket,path"`
+	LocalSocketMode  string      `yaml:"local_socket_mode" flag:"local-socket-mode"`
+	LocalSocketGroup string      `yaml:"local_socket_group" flag:"local-socket-group"`
+	TLS              TLS         `yaml:"tls"`
+	Claims           Claims      `yaml:"claims"`
+	Tokens           Tokens      `yaml:"tokens"`
+	Policies         Policies    `yaml:"policies"`
+	Environment      Environment `yaml:"environment"`
+	Limits           Limits      `yaml:"limits"`
+	Logs             Logs        `yaml:"logs"`
+	AuditLog         string      `yaml:"audit_log" flag:"audit-log"`
+	MetricsAddr      string      `yaml:"metrics_addr" flag:"metrics-addr"`
+	Tracing          Tracing     `yaml:"tracing"`
+	ShutdownPolicy   string      `yaml:"shutdown_policy" flag:"shutdown-policy"`
+
+	// dir is the directory holding the configuration file
+	dir string
+}
+
+// TLS locates the daemon's TLS material, and the data used to check client
+// certificates for revocation
+type TLS struct {
+	Certificate string   `yaml:"certificate" flag:"certificate,path"`
+	Key         string   `yaml:"key" flag:"key,path"`
+	ClientCA    string   `yaml:"client_ca" flag:"client-ca,path"`
+	CRLs        []string `yaml:"crls" flag:"crl,path"`
+	DenyList    string   `yaml:"deny_list" flag:"deny-list,path"`
+}
+
+// Claims describes where group and role claims are taken from in client
+// certificates
+type Claims struct {
+	Groups []string `yaml:"groups" flag:"group-claim"`
+	Roles  []string `yaml:"roles" flag:"role-claim"`
+}
+
+// Tokens describes how bearer tokens are validated
+type Tokens struct {
+	JWKS        string `yaml:"jwks" flag:"jwks,path"`
+	Issuer      string `yaml:"issuer" flag:"token-issuer"`
+	Audience    string `yaml:"audience" flag:"token-audience"`
+	LoginClaim  string `yaml:"login_claim" flag:"token-login-claim"`
+	GroupsClaim string `yaml:"group_claim" flag:"token-group-claim"`
+	RolesClaim  string `yaml:"role_claim" flag:"token-role-claim"`
+}
+
+// Policies locates the command and authorisation policy files
+type Policies struct {
+	Command       string `yaml:"command" flag:"command-policy,path"`
+	Authorisation string `yaml:"authorisation" flag:"auth-policy,path"`
+}
+
+// Environment describes how the base environment for tasks is built
+type Environment struct {
+	Inherit *bool    `yaml:"inherit" flag:"inherit-env"`
+	Pass    []string `yaml:"pass" flag:"pass-env"`
+	File    string   `yaml:"file" flag:"env-file,path"`
+	Deny    []string `yaml:"deny" flag:"deny-env"`
+}
+
+// Limits bounds the number of tasks that may run at once
+type Limits struct {
+	MaxTasks        *int `yaml:"max_tasks" flag:"max-tasks"`
+	MaxTasksPerUser *int `yaml:"max_tasks_per_user" flag:"max-tasks-per-user"`
+}
+
+// Logs describes how much task output is kept, and for how long
+type Logs struct {
+	MaxBytes  *int           `yaml:"max_bytes" flag:"max-log-bytes"`
+	Retention *time.Duration `yaml:"retention" flag:"log-retention"`
+}
+
+// Tracing describes where trace spans are exported to
+type Tracing struct {
+	OTLPEndpoint string `yaml:"otlp_endpoint" flag:"otlp-endpoint"`
+	OTLPInsecure *bool  `yaml:"otlp_insecure" flag:"otlp-insecure"`
+	File         string `yaml:"file" flag:"trace-file,path"`
+}
+
+// Load reads the daemon's configuration from a YAML file. Unknown settings
+// are an error, as they are most likely typos.
+func Load(path string) (*Daemon, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var d Daemon
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	// NB: An empty file is a perfectly good (if pointless) configuration
+	if err := decoder.Decode(&d); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	d.dir, err = filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// EnvVar names the environment variable that sets the named flag
+func EnvVar(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// Apply fills in any flags that weren't given on the command line, first from
+// their environment variables, and then from the configuration file (which
+// may be nil, if there isn't one). Values from environment variables are
+// parsed exactly as if they had been given on the command line.
+func Apply(flags *pflag.FlagSet, file *Daemon) error {
+	var settings map[string][]string
+	if file != nil {
+		settings = file.settings()
+	}
+
+	var failure error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if failure != nil || f.Changed {
+			return
+		}
+
+		if value, ok := os.LookupEnv(EnvVar(f.Name)); ok {
+			if err := flags.Set(f.Name, value); err != nil {
+				failure = fmt.Errorf("%s: %v", EnvVar(f.Name), err)
+			}
+			return
+		}
+
+		if values, ok := settings[f.Name]; ok {
+			if err := set(f, values); err != nil {
+				failure = fmt.Errorf("configuration for --%s: %v", f.Name, err)
+			}
+		}
+	})
+
+	return failure
+}
+
+// set replaces a flag's value with one from the configuration file
+func set(f *pflag.Flag, values []string) error {
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		if err := slice.Replace(values); err != nil {
+			return err
+		}
+	} else {
+		if len(values) != 1 {
+			return fmt.Errorf("expected a single value, got %d", len(values))
+		}
+		if err := f.Value.Set(values[0]); err != nil {
+			return err
+		}
+	}
+
+	f.Changed = true
+	return nil
+}
+
+// settings collects the values set in the file, keyed by the name of the
+// flag they correspond to
+func (d *Daemon) settings() map[string][]string {
+	settings := make(map[string][]string)
+	d.collect(reflect.ValueOf(d).Elem(), settings)
+	return settings
+}
+
+func (d *Daemon) collect(v reflect.Value, settings map[string][]string) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		tag, tagged := v.Type().Field(i).Tag.Lookup("flag")
+		if !tagged {
+			if field.Kind() == reflect.Struct {
+				d.collect(field, settings)
+			}
+			continue
+		}
+
+		values := format(field)
+		if values == nil {
+			continue
+		}
+
+		name := tag
+		if n := strings.Index(tag, ","); n >= 0 {
+			name = tag[:n]
+			if tag[n+1:] == "path" {
+				for j, value := range values {
+					values[j] = d.resolve(value)
+				}
+			}
+		}
+		settings[name] = values
+	}
+}
+
+// format renders a setting as it would be written on the command line, or
+// nil if it was not set
+func format(field reflect.Value) []string {
+	switch value := field.Interface().(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+
+	case []string:
+		if value == nil {
+			return nil
+		}
+		return append([]string{}, value...)
+
+	case *bool:
+		if value == nil {
+			return nil
+		}
+		return []string{strconv.FormatBool(*value)}
+
+	case *int:
+		if value == nil {
+			return nil
+		}
+		return []string{strconv.Itoa(*value)}
+
+	case *time.Duration:
+		if value == nil {
+			return nil
+		}
+		return []string{value.String()}
+	}
+
+	panic(fmt.Sprintf("config: unsupported setting type %s", field.Type()))
+}
+
+// resolve makes a path from the configuration file relative to the
+// directory holding the file, rather than the daemon's working directory
+func (d *Daemon) resolve(path string) string {
+	return resolve(d.dir, path)
+}
+
+// resolve makes a relative path relative to the given directory
+func resolve(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
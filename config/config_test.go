@@ -0,0 +1,171 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, content string) string {
+	filename := path.Join(t.TempDir(), "levityd.yaml")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(content), 0600))
+	return filename
+}
+
+// testFlags is a cut-down version of the daemon's flags
+type testFlags struct {
+	set         *pflag.FlagSet
+	listen      []string
+	certificate string
+	clientCA    string
+	crls        []string
+	inheritEnv  bool
+	maxTasks    int
+	retention   time.Duration
+}
+
+func newTestFlags() *testFlags {
+	f := &testFlags{set: pflag.NewFlagSet("test", pflag.ContinueOnError)}
+	f.set.StringSliceVar(&f.listen, "listen", []string{}, "")
+	f.set.StringVar(&f.certificate, "certificate", "./cert/svr-cert.pem", "")
+	f.set.StringVar(&f.clientCA, "client-ca", "", "")
+	f.set.StringSliceVar(&f.crls, "crl", []string{}, "")
+	f.set.BoolVar(&f.inheritEnv, "inherit-env", false, "")
+	f.set.IntVar(&f.maxTasks, "max-tasks", 0, "")
+	f.set.DurationVar(&f.retention, "log-retention", 0, "")
+	return f
+}
+
+const testConfig = `
+listeners: ["127.0.0.1:8443", "unix:/run/levityd.sock"]
+
+tls:
+  certificate: /etc/levityd/cert.pem
+  client_ca: ca.pem
+  crls: [one.crl, /etc/levityd/two.crl]
+
+environment:
+  inherit: true
+
+limits:
+  max_tasks: 10
+
+logs:
+  retention: 1h30m
+`
+
+func TestLoad(t *testing.T) {
+	require := require.New(t)
+	filename := writeFile(t, testConfig)
+	dir := path.Dir(filename)
+
+	// Given a configuration file
+	file, err := Load(filename)
+	require.NoError(err)
+
+	// When I apply it to a set of flags that weren't given on the command
+	// line
+	flags := newTestFlags()
+	require.NoError(flags.set.Parse([]string{}))
+	require.NoError(Apply(flags.set, file))
+
+	// Expect that the flags take their values from the file, with relative
+	// paths resolved against the file's directory
+	require.Equal([]string{"127.0.0.1:8443", "unix:/run/levityd.sock"}, flags.listen)
+	require.Equal("/etc/levityd/cert.pem", flags.certificate)
+	require.Equal(path.Join(dir, "ca.pem"), flags.clientCA)
+	require.Equal([]string{path.Join(dir, "one.crl"), "/etc/levityd/two.crl"}, flags.crls)
+	require.True(flags.inheritEnv)
+	require.Equal(10, flags.maxTasks)
+	require.Equal(90*time.Minute, flags.retention)
+}
+
+func TestApply_Precedence(t *testing.T) {
+	require := require.New(t)
+	file, err := Load(writeFile(t, testConfig))
+	require.NoError(err)
+
+	// Given some settings in the environment, some on the command line, and
+	// some in both
+	require.NoError(os.Setenv("LEVITYD_MAX_TASKS", "20"))
+	defer os.Unsetenv("LEVITYD_MAX_TASKS")
+	require.NoError(os.Setenv("LEVITYD_LISTEN", "127.0.0.1:1234,127.0.0.1:5678"))
+	defer os.Unsetenv("LEVITYD_LISTEN")
+
+	flags := newTestFlags()
+	require.NoError(flags.set.Parse([]string{"--listen", "0.0.0.0:443", "--inherit-env=false"}))
+
+	// When I apply the configuration file
+	require.NoError(Apply(flags.set, file))
+
+	// Expect that the command line wins over the environment, which wins
+	// over the file
+	require.Equal([]string{"0.0.0.0:443"}, flags.listen)
+	require.False(flags.inheritEnv)
+	require.Equal(20, flags.maxTasks)
+	require.Equal(90*time.Minute, flags.retention)
+}
+
+func TestApply_NoFile(t *testing.T) {
+	require := require.New(t)
+
+	// Given no configuration file, but a setting in the environment
+	require.NoError(os.Setenv("LEVITYD_CLIENT_CA", "/etc/ca.pem"))
+	defer os.Unsetenv("LEVITYD_CLIENT_CA")
+
+	// When I apply the (missing) configuration
+	flags := newTestFlags()
+	require.NoError(flags.set.Parse([]string{}))
+	require.NoError(Apply(flags.set, nil))
+
+	// Expect that the environment is still honoured, and everything else
+	// left at its default
+	require.Equal("/etc/ca.pem", flags.clientCA)
+	require.Equal("./cert/svr-cert.pem", flags.certificate)
+	require.Equal(0, flags.maxTasks)
+}
+
+func TestApply_InvalidEnvironment(t *testing.T) {
+	require.NoError(t, os.Setenv("LEVITYD_MAX_TASKS", "lots"))
+	defer os.Unsetenv("LEVITYD_MAX_TASKS")
+
+	flags := newTestFlags()
+	require.NoError(t, flags.set.Parse([]string{}))
+	err := Apply(flags.set, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "LEVITYD_MAX_TASKS")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown setting", content: "tls:\n  certificat: cert.pem\n"},
+		{name: "wrong type", content: "limits:\n  max_tasks: lots\n"},
+		{name: "bad duration", content: "logs:\n  retention: forever\n"},
+		{name: "malformed", content: "listeners: [\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(writeFile(t, tc.content))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoad_Empty(t *testing.T) {
+	file, err := Load(writeFile(t, ""))
+	require.NoError(t, err)
+	require.Empty(t, file.settings())
+}
+
+func TestEnvVar(t *testing.T) {
+	require.Equal(t, "LEVITYD_MAX_TASKS_PER_USER", EnvVar("max-tasks-per-user"))
+}
@@ -0,0 +1,135 @@
+// Package environment builds the set of environment variables handed to a
+// task, layering the variables supplied by the client on top of a base
+// environment configured by the server operator.
+package environment
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultDenied lists the variables that clients may not set unless the
+// server is configured otherwise. All of these allow the caller to inject
+// code into the process being launched, bypassing any restrictions on what
+// binaries may be run.
+var DefaultDenied = []string{"LD_PRELOAD", "LD_LIBRARY_PATH", "LD_AUDIT"}
+
+// DeniedVariable is an error type indicating that a client attempted to set
+// an environment variable that the server does not allow to be overridden.
+type DeniedVariable struct {
+	name string
+}
+
+func (e *DeniedVariable) Error() string {
+	return fmt.Sprintf("Setting environment variable %s is not permitted", e.name)
+}
+
+// Policy describes how a task's environment is constructed. Once created, a
+// Policy is immutable and may be shared between goroutines.
+type Policy struct {
+	base   map[string]string
+	denied map[string]struct{}
+}
+
+// New creates a policy that starts every task with a copy of the given base
+// environment, and refuses any request that tries to set one of the denied
+// variables.
+func New(base map[string]string, denied ...string) *Policy {
+	p := &Policy{
+		base:   make(map[string]string, len(base)),
+		denied: make(map[string]struct{}, len(denied)),
+	}
+
+	for k, v := range base {
+		p.base[k] = v
+	}
+
+	for _, name := range denied {
+		p.denied[name] = struct{}{}
+	}
+
+	return p
+}
+
+// Apply merges the client-supplied variables over the base environment,
+// returning a new map. The request is rejected with a DeniedVariable error if
+// it tries to set any of the policy's denied variables.
+func (p *Policy) Apply(env map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(p.base)+len(env))
+	for k, v := range p.base {
+		result[k] = v
+	}
+
+	for k, v := range env {
+		if _, denied := p.denied[k]; denied {
+			return nil, &DeniedVariable{name: k}
+		}
+		result[k] = v
+	}
+
+	return result, nil
+}
+
+// Inherit captures the entire environment of the current process.
+func Inherit() map[string]string {
+	return parse(os.Environ())
+}
+
+// Allow captures only the named variables from the environment of the
+// current process. Variables that are not set are skipped.
+func Allow(names ...string) map[string]string {
+	result := make(map[string]string, len(names))
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// Load reads an environment from a file containing one `NAME=value` pair per
+// line. Blank lines and lines starting with `#` are ignored. Values are taken
+// verbatim; no quote removal or variable substitution is performed.
+func Load(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, lineNumber)
+		}
+		result[parts[0]] = parts[1]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func parse(env []string) map[string]string {
+	result := make(map[string]string, len(env))
+	for _, s := range env {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) == 2 && parts[0] != "" {
+			result[parts[0]] = parts[1]
+		}
+	}
+	return result
+}
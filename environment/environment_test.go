@@ -0,0 +1,89 @@
+package environment
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMergesOverBase(t *testing.T) {
+	require := require.New(t)
+
+	// Given a policy with a base environment
+	uut := New(map[string]string{"PATH": "/usr/bin", "LANG": "C"})
+
+	// When I apply a request that overrides one variable and adds another
+	env, err := uut.Apply(map[string]string{"LANG": "en_AU.UTF-8", "FOO": "BAR"})
+
+	// Expect that the request values take precedence over the base
+	require.NoError(err)
+	require.Equal(
+		map[string]string{"PATH": "/usr/bin", "LANG": "en_AU.UTF-8", "FOO": "BAR"},
+		env)
+}
+
+func TestApplyDoesNotModifyBase(t *testing.T) {
+	require := require.New(t)
+
+	uut := New(map[string]string{"LANG": "C"})
+	_, err := uut.Apply(map[string]string{"LANG": "en_AU.UTF-8"})
+	require.NoError(err)
+
+	env, err := uut.Apply(nil)
+	require.NoError(err)
+	require.Equal(map[string]string{"LANG": "C"}, env)
+}
+
+func TestApplyRejectsDeniedVariable(t *testing.T) {
+	require := require.New(t)
+
+	// Given a policy that denies LD_PRELOAD, even though the base sets it
+	uut := New(map[string]string{"LD_PRELOAD": "libsafe.so"}, DefaultDenied...)
+
+	// When a client attempts to override it
+	env, err := uut.Apply(map[string]string{"LD_PRELOAD": "/tmp/evil.so"})
+
+	// Expect the request to be refused
+	require.IsType(&DeniedVariable{}, err)
+	require.Nil(env)
+}
+
+func TestAllowSkipsUnsetVariables(t *testing.T) {
+	require := require.New(t)
+	require.NoError(os.Setenv("LEVITY_TEST_SET", "yes"))
+	require.NoError(os.Unsetenv("LEVITY_TEST_UNSET"))
+	defer os.Unsetenv("LEVITY_TEST_SET")
+
+	env := Allow("LEVITY_TEST_SET", "LEVITY_TEST_UNSET")
+	require.Equal(map[string]string{"LEVITY_TEST_SET": "yes"}, env)
+}
+
+func TestLoad(t *testing.T) {
+	require := require.New(t)
+	filename := path.Join(t.TempDir(), "env")
+	content := "# comment\n\nPATH=/usr/local/bin:/usr/bin\nEMPTY=\nQUZZ=EMBEDDED=EQUALS\n"
+	require.NoError(ioutil.WriteFile(filename, []byte(content), 0600))
+
+	env, err := Load(filename)
+
+	require.NoError(err)
+	require.Equal(
+		map[string]string{
+			"PATH":  "/usr/local/bin:/usr/bin",
+			"EMPTY": "",
+			"QUZZ":  "EMBEDDED=EQUALS",
+		},
+		env)
+}
+
+func TestLoadRejectsMalformedLine(t *testing.T) {
+	require := require.New(t)
+	filename := path.Join(t.TempDir(), "env")
+	require.NoError(ioutil.WriteFile(filename, []byte("PATH=/usr/bin\nnonsense\n"), 0600))
+
+	_, err := Load(filename)
+	require.Error(err)
+}
@@ -0,0 +1,132 @@
+// Package health reports the daemon's health via the standard GRPC health
+// checking protocol (`grpc.health.v1.Health`), based on a set of internal
+// checks that are run periodically.
+package health
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ServiceName is the name of the health checking service, e.g. for
+// exempting it from authentication
+const ServiceName = "grpc.health.v1.Health"
+
+// Check reports whether some part of the daemon is healthy. Checks should
+// give up (and report the daemon as unhealthy) if the context expires.
+type Check func(ctx context.Context) error
+
+// Monitor runs a set of checks, and reports the daemon (as a whole, and
+// each of the named services) as serving only if all of them pass. Once the
+// monitor has been shut down it reports the daemon as not serving,
+// regardless of the checks.
+type Monitor struct {
+	server   *grpchealth.Server
+	services []string
+
+	lock    sync.Mutex
+	checks  map[string]Check
+	healthy bool
+}
+
+// NewMonitor creates a health monitor for the named services. The daemon is
+// reported as not serving until the checks have been run for the first time.
+func NewMonitor(services ...string) *Monitor {
+	m := &Monitor{
+		server:   grpchealth.NewServer(),
+		services: append([]string{""}, services...),
+		checks:   make(map[string]Check),
+	}
+	m.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	return m
+}
+
+// AddCheck adds a named check to the set that the daemon's health depends on
+func (m *Monitor) AddCheck(name string, check Check) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.checks[name] = check
+}
+
+// Register adds the health checking service to a GRPC server
+func (m *Monitor) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, m.server)
+}
+
+func (m *Monitor) setStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range m.services {
+		m.server.SetServingStatus(service, status)
+	}
+}
+
+// CheckNow runs all of the checks, updating the reported status to match
+// and returning the first failure (in name order), if any. Each check is
+// given at most `timeout` to complete.
+func (m *Monitor) CheckNow(ctx context.Context, timeout time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := make([]string, 0, len(m.checks))
+	for name := range m.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failure error
+	for _, name := range names {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := m.checks[name](checkCtx)
+		cancel()
+		if err != nil {
+			failure = fmt.Errorf("%s: %v", name, err)
+			break
+		}
+	}
+
+	if failure != nil {
+		if m.healthy {
+			log.Printf("Health check failed: %v", failure)
+		}
+		m.healthy = false
+		m.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+		return failure
+	}
+
+	if !m.healthy {
+		log.Printf("Health checks passed")
+	}
+	m.healthy = true
+	m.setStatus(healthpb.HealthCheckResponse_SERVING)
+	return nil
+}
+
+// Run checks the daemon's health every `interval` until the context is
+// cancelled. Blocks until then, so will usually be run in its own goroutine.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			_ = m.CheckNow(ctx, interval)
+		}
+	}
+}
+
+// Shutdown permanently reports the daemon as not serving, e.g. so that load
+// balancers stop sending it new requests while it shuts down
+func (m *Monitor) Shutdown() {
+	m.server.Shutdown()
+}
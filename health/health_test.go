@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func status(t *testing.T, m *Monitor, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := m.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func TestMonitor(t *testing.T) {
+	require := require.New(t)
+	var failure error
+
+	// Given a monitor with a check that I can control
+	uut := NewMonitor("levity.TaskManager")
+	uut.AddCheck("test", func(context.Context) error { return failure })
+
+	// Expect that the daemon is not serving before it has been checked
+	require.Equal(healthpb.HealthCheckResponse_NOT_SERVING, status(t, uut, ""))
+
+	// ... that it is serving once the checks pass
+	require.NoError(uut.CheckNow(context.Background(), time.Second))
+	require.Equal(healthpb.HealthCheckResponse_SERVING, status(t, uut, ""))
+	require.Equal(healthpb.HealthCheckResponse_SERVING, status(t, uut, "levity.TaskManager"))
+
+	// ... that it stops serving if a check fails
+	failure = errors.New("boom")
+	require.Error(uut.CheckNow(context.Background(), time.Second))
+	require.Equal(healthpb.HealthCheckResponse_NOT_SERVING, status(t, uut, ""))
+	require.Equal(healthpb.HealthCheckResponse_NOT_SERVING, status(t, uut, "levity.TaskManager"))
+
+	// ... and that it stays out of service once shut down, even if the
+	// checks pass again
+	failure = nil
+	uut.Shutdown()
+	require.NoError(uut.CheckNow(context.Background(), time.Second))
+	require.Equal(healthpb.HealthCheckResponse_NOT_SERVING, status(t, uut, ""))
+}
+
+func TestMonitor_CheckTimeout(t *testing.T) {
+	require := require.New(t)
+
+	// Given a check that never completes by itself
+	uut := NewMonitor()
+	uut.AddCheck("stuck", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	// Expect that the check is abandoned (and fails) once it times out
+	err := uut.CheckNow(context.Background(), 10*time.Millisecond)
+	require.Error(err)
+	require.Contains(err.Error(), "stuck")
+	require.Equal(healthpb.HealthCheckResponse_NOT_SERVING, status(t, uut, ""))
+}
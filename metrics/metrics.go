@@ -0,0 +1,189 @@
+// Package metrics collects Prometheus metrics about the levity daemon: the
+// RPCs it serves, the tasks it runs and the resources they consume.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tcsc/levity/registry"
+	"github.com/tcsc/levity/task"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "levity"
+
+// Metrics holds the daemon's metrics, and the Prometheus registry they are
+// exported from. It implements `taskmanager.TaskObserver`, so that it can be
+// told about tasks starting and finishing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	rpcRequests *prometheus.CounterVec
+	rpcDuration *prometheus.HistogramVec
+
+	tasksStarted  prometheus.Counter
+	tasksFinished *prometheus.CounterVec
+	tasksRunning  prometheus.Gauge
+	taskDuration  prometheus.Histogram
+}
+
+// New creates the daemon's metrics. The sizes of the task registry and the
+// output captured by its tasks are sampled from `tasks` whenever the metrics
+// are scraped.
+func New(tasks *registry.Registry) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		rpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rpc_requests_total",
+			Help:      "RPCs handled, by method and GRPC status code.",
+		}, []string{"method", "code"}),
+
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rpc_duration_seconds",
+			Help:      "Time taken to handle RPCs, by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+
+		tasksStarted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tasks_started_total",
+			Help:      "Tasks started successfully.",
+		}),
+
+		tasksFinished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tasks_finished_total",
+			Help:      "Tasks finished, by final status.",
+		}, []string{"status"}),
+
+		tasksRunning: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "tasks_running",
+			Help:      "Tasks currently running.",
+		}),
+
+		// Tasks can run for anything from milliseconds to days, so the
+		// buckets cover 100ms to ~7 hours
+		taskDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "task_duration_seconds",
+			Help:      "Time tasks ran for.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.rpcRequests,
+		m.rpcDuration,
+		m.tasksStarted,
+		m.tasksFinished,
+		m.tasksRunning,
+		m.taskDuration,
+		&registryCollector{tasks: tasks},
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// TaskStarted implements `taskmanager.TaskObserver`
+func (m *Metrics) TaskStarted(t *task.Task) {
+	m.tasksStarted.Inc()
+	m.tasksRunning.Inc()
+}
+
+// TaskFinished implements `taskmanager.TaskObserver`
+func (m *Metrics) TaskFinished(t *task.Task, runtime time.Duration) {
+	statusCode, _ := t.Status()
+	m.tasksRunning.Dec()
+	m.tasksFinished.WithLabelValues(statusCode.String()).Inc()
+	m.taskDuration.Observe(runtime.Seconds())
+}
+
+func (m *Metrics) observeRPC(method string, start time.Time, err error) {
+	m.rpcRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// UnaryServerInterceptor counts and times every unary request. It should be
+// installed first, so that requests rejected by later interceptors (e.g.
+// for failing authentication) are counted too.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeRPC(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor counts and times every streaming request, in the
+// same way as `UnaryServerInterceptor`.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		start := time.Now()
+		err := handler(srv, stream)
+		m.observeRPC(info.FullMethod, start, err)
+		return err
+	}
+}
+
+// registryCollector samples the task registry when the metrics are scraped,
+// rather than tracking every change to it
+type registryCollector struct {
+	tasks *registry.Registry
+}
+
+var (
+	registrySizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "registry_tasks"),
+		"Tasks held in the registry, whether running or not.",
+		nil, nil)
+
+	outputBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "task_output_bytes"),
+		"Bytes of task output currently held in memory, by stream.",
+		[]string{"stream"}, nil)
+)
+
+func (c *registryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- registrySizeDesc
+	ch <- outputBytesDesc
+}
+
+func (c *registryCollector) Collect(ch chan<- prometheus.Metric) {
+	var stdout, stderr int
+	c.tasks.Range(func(_ string, t *task.Task) {
+		out, err := t.OutputSize()
+		stdout += out
+		stderr += err
+	})
+
+	ch <- prometheus.MustNewConstMetric(registrySizeDesc, prometheus.GaugeValue, float64(c.tasks.Len()))
+	ch <- prometheus.MustNewConstMetric(outputBytesDesc, prometheus.GaugeValue, float64(stdout), "stdout")
+	ch <- prometheus.MustNewConstMetric(outputBytesDesc, prometheus.GaugeValue, float64(stderr), "stderr")
+}
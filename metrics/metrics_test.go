@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/registry"
+	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRPCInterceptors(t *testing.T) {
+	require := require.New(t)
+	uut := New(registry.New())
+
+	// Given a set of requests with different outcomes
+	unary := uut.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/levity.TaskManager/QueryTask"}
+	for _, err := range []error{nil, nil, status.Error(codes.NotFound, "nope")} {
+		_, _ = unary(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, err
+		})
+	}
+
+	stream := uut.StreamServerInterceptor()
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/levity.TaskManager/FetchLogs"}
+	_ = stream(nil, nil, streamInfo, func(interface{}, grpc.ServerStream) error {
+		return errors.New("boom")
+	})
+
+	// Expect that each request is counted by method and code
+	require.Equal(2.0, testutil.ToFloat64(uut.rpcRequests.WithLabelValues(info.FullMethod, "OK")))
+	require.Equal(1.0, testutil.ToFloat64(uut.rpcRequests.WithLabelValues(info.FullMethod, "NotFound")))
+	require.Equal(1.0, testutil.ToFloat64(uut.rpcRequests.WithLabelValues(streamInfo.FullMethod, "Unknown")))
+
+	// ... and timed by method
+	require.Equal(2, testutil.CollectAndCount(uut.rpcDuration))
+}
+
+func TestTaskObserver(t *testing.T) {
+	require := require.New(t)
+	uut := New(registry.New())
+
+	// Given a task that has run to completion
+	tsk := task.New(user.New("alice"), "true", ".", nil)
+	require.NoError(tsk.Start())
+	uut.TaskStarted(tsk)
+	require.Equal(1.0, testutil.ToFloat64(uut.tasksRunning))
+
+	select {
+	case <-tsk.Done():
+	case <-time.After(5 * time.Second):
+		require.Fail("Timed out waiting for task to finish")
+	}
+
+	// When the metrics are told it has finished
+	uut.TaskFinished(tsk, 2*time.Second)
+
+	// Expect that it is counted by its final status, and is no longer
+	// running
+	require.Equal(1.0, testutil.ToFloat64(uut.tasksStarted))
+	require.Equal(1.0, testutil.ToFloat64(uut.tasksFinished.WithLabelValues("Finished")))
+	require.Equal(0.0, testutil.ToFloat64(uut.tasksRunning))
+	require.Equal(1, testutil.CollectAndCount(uut.taskDuration))
+}
+
+func TestRegistryCollector(t *testing.T) {
+	require := require.New(t)
+
+	// Given a registry holding a task that has written some output
+	tasks := registry.New()
+	tsk := task.New(user.New("alice"), "echo", ".", nil, "hello")
+	require.NoError(tsk.Start())
+	<-tsk.Done()
+	tasks.Register(tsk)
+
+	uut := New(tasks)
+
+	// When the metrics are scraped
+	w := httptest.NewRecorder()
+	uut.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	// Expect that the registry size and captured output are reported
+	body := w.Body.String()
+	require.Contains(body, "levity_registry_tasks 1\n")
+	require.Contains(body, `levity_task_output_bytes{stream="stdout"} 6`+"\n")
+	require.Contains(body, `levity_task_output_bytes{stream="stderr"} 0`+"\n")
+
+	// ... along with the standard process metrics
+	require.Contains(body, "process_start_time_seconds")
+}
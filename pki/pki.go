@@ -0,0 +1,280 @@
+// Package pki implements a minimal certificate authority for issuing the
+// client and server certificates that levity uses for mutual TLS.
+package pki
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// clockSkew is how far into the past certificates are backdated, so that
+// they are immediately usable on hosts whose clocks are slightly behind
+const clockSkew = 5 * time.Minute
+
+// Usage describes what an issued certificate may be used for
+type Usage int
+
+const (
+	// ClientCertificate identifies a user to the levity daemon
+	ClientCertificate Usage = iota
+
+	// ServerCertificate identifies the levity daemon to its clients
+	ServerCertificate
+)
+
+// CA is a certificate authority: a self-signed certificate and the private
+// key that goes with it.
+type CA struct {
+	Certificate *x509.Certificate
+	Key         ed25519.PrivateKey
+}
+
+// Request describes a certificate to be issued by a CA
+type Request struct {
+	// CommonName is the user's login for client certificates, or the
+	// server's primary host name for server certificates
+	CommonName string
+
+	Organisation        []string
+	OrganisationalUnits []string
+
+	// Hosts lists the DNS names and IP addresses that a server certificate
+	// is valid for. Included as subject alternative names.
+	Hosts []string
+
+	// URIs are included as subject alternative names, e.g. to carry group
+	// or role claims
+	URIs []*url.URL
+
+	Usage Usage
+	TTL   time.Duration
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// NewCA generates a new ed25519 CA key and self-signed certificate, valid
+// for the given period.
+func NewCA(commonName string, organisationalUnit string, ttl time.Duration) (*CA, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:         commonName,
+			OrganizationalUnit: []string{organisationalUnit},
+		},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(ttl),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		MaxPathLenZero:        true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{Certificate: cert, Key: priv}, nil
+}
+
+// LoadCA reads a CA certificate and its private key from PEM files
+func LoadCA(certPath, keyPath string) (*CA, error) {
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s: no certificate found", certPath)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", certPath, err)
+	}
+
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s: not a CA certificate", certPath)
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ = pem.Decode(keyPEM)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: no private key found", keyPath)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", keyPath, err)
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 private key", keyPath)
+	}
+
+	if !edKey.Public().(ed25519.PublicKey).Equal(cert.PublicKey) {
+		return nil, errors.New("CA private key does not match certificate")
+	}
+
+	return &CA{Certificate: cert, Key: edKey}, nil
+}
+
+// Save writes the CA certificate and private key to PEM files. The private
+// key file is only readable by its owner.
+func (ca *CA) Save(certPath, keyPath string) error {
+	return WriteKeyPair(ca.Certificate, ca.Key, certPath, keyPath)
+}
+
+// Issue generates a new ed25519 key and a certificate for it, signed by the
+// CA. The certificate will not outlive the CA.
+func (ca *CA) Issue(req Request) (*x509.Certificate, ed25519.PrivateKey, error) {
+	if req.CommonName == "" {
+		return nil, nil, errors.New("A certificate requires a common name")
+	}
+
+	if req.TTL <= 0 {
+		return nil, nil, errors.New("A certificate requires a positive TTL")
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(req.TTL)
+	if notAfter.After(ca.Certificate.NotAfter) {
+		notAfter = ca.Certificate.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:         req.CommonName,
+			Organization:       req.Organisation,
+			OrganizationalUnit: req.OrganisationalUnits,
+		},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		URIs:                  req.URIs,
+	}
+
+	switch req.Usage {
+	case ClientCertificate:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	case ServerCertificate:
+		if len(req.Hosts) == 0 {
+			return nil, nil, errors.New("A server certificate requires at least one host")
+		}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		for _, host := range req.Hosts {
+			if ip := net.ParseIP(host); ip != nil {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			} else {
+				template.DNSNames = append(template.DNSNames, host)
+			}
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("Unknown certificate usage %d", req.Usage)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, pub, ca.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, priv, nil
+}
+
+// WriteKeyPair writes a certificate and its private key to PEM files, in the
+// format expected by `tls.LoadX509KeyPair`. The private key file is only
+// readable by its owner, even if it replaces a file that wasn't.
+func WriteKeyPair(cert *x509.Certificate, key ed25519.PrivateKey, certPath, keyPath string) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := replaceFile(keyPath, keyPEM, 0600); err != nil {
+		return err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	return replaceFile(certPath, certPEM, 0644)
+}
+
+// replaceFile writes a file with the given permissions, replacing any
+// existing file. Unlike ioutil.WriteFile, the permissions apply even if the
+// file already exists, as the content is written to a new file which is
+// then renamed over the old one.
+func replaceFile(filename string, content []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,144 @@
+package pki
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func verify(ca *CA, cert *x509.Certificate, usage x509.ExtKeyUsage, host string) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate)
+	_, err := cert.Verify(x509.VerifyOptions{
+		DNSName:   host,
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{usage},
+	})
+	return err
+}
+
+func TestIssue_ClientCertificate(t *testing.T) {
+	require := require.New(t)
+
+	// Given a CA
+	ca, err := NewCA("ClientCA", "levity", 24*time.Hour)
+	require.NoError(err)
+
+	// When I issue a client certificate
+	cert, _, err := ca.Issue(Request{
+		CommonName:          "alice",
+		OrganisationalUnits: []string{"client"},
+		TTL:                 time.Hour,
+	})
+	require.NoError(err)
+
+	// Expect that it identifies the user and verifies as a client
+	// certificate, but not a server one
+	require.Equal("alice", cert.Subject.CommonName)
+	require.Equal([]string{"client"}, cert.Subject.OrganizationalUnit)
+	require.NoError(verify(ca, cert, x509.ExtKeyUsageClientAuth, ""))
+	require.Error(verify(ca, cert, x509.ExtKeyUsageServerAuth, ""))
+	require.WithinDuration(time.Now().Add(time.Hour), cert.NotAfter, time.Minute)
+}
+
+func TestIssue_ServerCertificate(t *testing.T) {
+	require := require.New(t)
+	ca, err := NewCA("ServerCA", "levity", 24*time.Hour)
+	require.NoError(err)
+
+	cert, _, err := ca.Issue(Request{
+		CommonName: "localhost",
+		Hosts:      []string{"localhost", "127.0.0.1"},
+		Usage:      ServerCertificate,
+		TTL:        time.Hour,
+	})
+	require.NoError(err)
+
+	require.Equal([]string{"localhost"}, cert.DNSNames)
+	require.Len(cert.IPAddresses, 1)
+	require.True(cert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+	require.NoError(verify(ca, cert, x509.ExtKeyUsageServerAuth, "localhost"))
+	require.NoError(verify(ca, cert, x509.ExtKeyUsageServerAuth, "127.0.0.1"))
+	require.Error(verify(ca, cert, x509.ExtKeyUsageServerAuth, "::1"))
+
+	// A server certificate without any hosts is useless
+	_, _, err = ca.Issue(Request{CommonName: "localhost", Usage: ServerCertificate, TTL: time.Hour})
+	require.Error(err)
+}
+
+func TestIssue_DoesNotOutliveCA(t *testing.T) {
+	require := require.New(t)
+	ca, err := NewCA("ClientCA", "levity", time.Hour)
+	require.NoError(err)
+
+	cert, _, err := ca.Issue(Request{CommonName: "alice", TTL: 24 * time.Hour})
+	require.NoError(err)
+	require.Equal(ca.Certificate.NotAfter, cert.NotAfter)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	caCertPath := filepath.Join(dir, "ca-cert.pem")
+	caKeyPath := filepath.Join(dir, "ca-key.pem")
+
+	// Given a CA that has been written to disk
+	ca, err := NewCA("ClientCA", "levity", 24*time.Hour)
+	require.NoError(err)
+	require.NoError(ca.Save(caCertPath, caKeyPath))
+
+	// When I load it again and issue a certificate with it
+	loaded, err := LoadCA(caCertPath, caKeyPath)
+	require.NoError(err)
+
+	cert, key, err := loaded.Issue(Request{CommonName: "alice", TTL: time.Hour})
+	require.NoError(err)
+	certPath := filepath.Join(dir, "alice-cert.pem")
+	keyPath := filepath.Join(dir, "alice-key.pem")
+	require.NoError(WriteKeyPair(cert, key, certPath, keyPath))
+
+	// Expect that the certificate was signed by the original CA, and that
+	// the key pair is usable for TLS
+	require.NoError(verify(ca, cert, x509.ExtKeyUsageClientAuth, ""))
+	_, err = tls.LoadX509KeyPair(certPath, keyPath)
+	require.NoError(err)
+
+	// ... and that a CA whose key doesn't match its certificate is rejected
+	_, err = LoadCA(caCertPath, keyPath)
+	require.Error(err)
+}
+
+func TestWriteKeyPair_ReplacesPermissions(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "alice-cert.pem")
+	keyPath := filepath.Join(dir, "alice-key.pem")
+
+	// Given an existing key file that anyone can read
+	require.NoError(ioutil.WriteFile(keyPath, []byte("old key"), 0644))
+	require.NoError(os.Chmod(keyPath, 0644))
+
+	// When I overwrite it with a new key pair
+	ca, err := NewCA("ClientCA", "levity", 24*time.Hour)
+	require.NoError(err)
+	cert, key, err := ca.Issue(Request{CommonName: "alice", TTL: time.Hour})
+	require.NoError(err)
+	require.NoError(WriteKeyPair(cert, key, certPath, keyPath))
+
+	// Expect that the new key is only readable by its owner, and that no
+	// temporary files are left behind
+	info, err := os.Stat(keyPath)
+	require.NoError(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(err)
+	require.Len(entries, 2)
+}
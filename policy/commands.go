@@ -0,0 +1,284 @@
+// Package policy implements the configurable rules that the server uses to
+// decide what its users are allowed to do.
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tcsc/levity/user"
+	"gopkg.in/yaml.v3"
+)
+
+// Everyone is a wildcard that matches any user when used in a rule's user
+// list.
+const Everyone = "*"
+
+// CommandDenied is an error type indicating that a user tried to launch a
+// command not permitted by the server's command policy.
+type CommandDenied struct {
+	binary string
+	reason string
+}
+
+func (e *CommandDenied) Error() string {
+	return fmt.Sprintf("Command %s denied: %s", e.binary, e.reason)
+}
+
+// commandRule describes a single rule in a command allowlist file, e.g.
+//
+//	rules:
+//	  - users: [alice, bob]
+//	    binaries: [/usr/bin/make, /usr/local/bin/*]
+//	    args: ['-j[0-9]+', '[a-z]+']
+//	    working_dirs: [/srv/build/*]
+//
+// Binaries and working directories are shell-style glob patterns matched
+// against absolute paths, with any symlinks resolved. Symlinks in patterns
+// without any wildcards are resolved when the file is loaded, so a rule may
+// name a binary by a symlink, but patterns with wildcards must name the real
+// directories. Each argument must fully match at least one of the argument
+// regular expressions. An empty list of arguments or working directories
+// places no restriction on them.
+type commandRule struct {
+	Users       []string `yaml:"users"`
+	Binaries    []string `yaml:"binaries"`
+	Args        []string `yaml:"args"`
+	WorkingDirs []string `yaml:"working_dirs"`
+}
+
+type commandFile struct {
+	Rules []commandRule `yaml:"rules"`
+}
+
+// compiledRule is a commandRule that has been validated and pre-processed for
+// matching
+type compiledRule struct {
+	users       map[string]struct{}
+	binaries    []string
+	args        []*regexp.Regexp
+	workingDirs []string
+}
+
+// CommandAllowlist is a command policy that only allows users to launch the
+// binaries explicitly listed for them. Once created, a CommandAllowlist is
+// immutable and may be shared between goroutines.
+type CommandAllowlist struct {
+	rules []compiledRule
+}
+
+// LoadCommandAllowlist reads a command allowlist from a YAML file.
+func LoadCommandAllowlist(path string) (*CommandAllowlist, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file commandFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	allowlist := &CommandAllowlist{rules: make([]compiledRule, 0, len(file.Rules))}
+	for i, rule := range file.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %v", path, i+1, err)
+		}
+		allowlist.rules = append(allowlist.rules, compiled)
+	}
+
+	return allowlist, nil
+}
+
+func compileRule(rule commandRule) (compiledRule, error) {
+	result := compiledRule{users: make(map[string]struct{}, len(rule.Users))}
+
+	if len(rule.Users) == 0 {
+		return result, fmt.Errorf("no users specified")
+	}
+	for _, u := range rule.Users {
+		result.users[u] = struct{}{}
+	}
+
+	if err := checkPatterns(rule.Binaries); err != nil {
+		return result, err
+	}
+	result.binaries = resolvePatterns(rule.Binaries)
+
+	if err := checkPatterns(rule.WorkingDirs); err != nil {
+		return result, err
+	}
+	result.workingDirs = resolvePatterns(rule.WorkingDirs)
+
+	for _, expr := range rule.Args {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return result, err
+		}
+		result.args = append(result.args, re)
+	}
+
+	return result, nil
+}
+
+// checkPatterns validates a set of path glob patterns
+func checkPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			return fmt.Errorf("path %q is not absolute", pattern)
+		}
+
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// resolvePatterns resolves any symlinks in patterns that name a single path,
+// so that they match the resolved paths that commands are checked with.
+// Patterns with wildcards, or that name paths that don't exist (yet), are
+// left as they are.
+func resolvePatterns(patterns []string) []string {
+	result := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			if resolved, err := filepath.EvalSymlinks(pattern); err == nil {
+				pattern = resolved
+			}
+		}
+		result = append(result, pattern)
+	}
+	return result
+}
+
+func matchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *compiledRule) appliesTo(u *user.User) bool {
+	if _, ok := rule.users[Everyone]; ok {
+		return true
+	}
+	_, ok := rule.users[u.Login()]
+	return ok
+}
+
+// check tests a resolved command against the rule, returning a description
+// of the problem if the rule does not allow it
+func (rule *compiledRule) check(binary, workingDir string, args []string) string {
+	if !matchAny(rule.binaries, binary) {
+		return "binary not permitted"
+	}
+
+	if len(rule.workingDirs) > 0 && !matchAny(rule.workingDirs, workingDir) {
+		return fmt.Sprintf("working directory %s not permitted", workingDir)
+	}
+
+	if len(rule.args) > 0 {
+	nextArg:
+		for _, arg := range args {
+			for _, re := range rule.args {
+				if re.MatchString(arg) {
+					continue nextArg
+				}
+			}
+			return fmt.Sprintf("argument %q not permitted", arg)
+		}
+	}
+
+	return ""
+}
+
+// Check tests if the user may launch the given command. The binary and
+// working directory are resolved to absolute paths, without any symlinks, and
+// it is these resolved paths that are checked against the allowlist and
+// returned to the caller. Callers should launch the returned binary in the
+// returned directory, rather than the original ones, to be sure that the
+// command run is the one that was checked.
+func (allowlist *CommandAllowlist) Check(
+	u *user.User, binary, workingDir string, args []string) (string, string, error) {
+
+	resolvedBinary, resolvedDir, err := resolve(binary, workingDir)
+	if err != nil {
+		return "", "", &CommandDenied{binary: binary, reason: err.Error()}
+	}
+
+	// Report the problem from the most relevant rule, i.e. one that at
+	// least allows the binary, if there is one.
+	reason := "binary not permitted"
+	for i := range allowlist.rules {
+		rule := &allowlist.rules[i]
+		if !rule.appliesTo(u) {
+			continue
+		}
+
+		problem := rule.check(resolvedBinary, resolvedDir, args)
+		if problem == "" {
+			return resolvedBinary, resolvedDir, nil
+		}
+
+		if matchAny(rule.binaries, resolvedBinary) {
+			reason = problem
+		}
+	}
+
+	return "", "", &CommandDenied{binary: resolvedBinary, reason: reason}
+}
+
+// resolve converts a binary and working directory, as supplied by a client,
+// into absolute paths with any symlinks resolved, so that a symlink can't
+// point somewhere other than the path that was checked. Binaries without a
+// path separator are looked up on the server's search path, mirroring the
+// behaviour of `exec.Command`.
+func resolve(binary, workingDir string) (string, string, error) {
+	dir := workingDir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", "", err
+		}
+		dir = wd
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !strings.Contains(binary, string(filepath.Separator)) {
+		path, err := exec.LookPath(binary)
+		if err != nil {
+			return "", "", err
+		}
+		binary = path
+	}
+
+	if !filepath.IsAbs(binary) {
+		binary = filepath.Join(dir, binary)
+	}
+
+	// NB: EvalSymlinks also cleans the path
+	binary, err = filepath.EvalSymlinks(binary)
+	if err != nil {
+		return "", "", err
+	}
+
+	return binary, dir, nil
+}
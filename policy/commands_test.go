@@ -0,0 +1,202 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/user"
+)
+
+var (
+	alice = user.New("alice")
+	bob   = user.New("bob")
+)
+
+func writeFile(t *testing.T, content string) string {
+	filename := path.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(content), 0600))
+	return filename
+}
+
+func loadAllowlist(t *testing.T, content string) *CommandAllowlist {
+	allowlist, err := LoadCommandAllowlist(writeFile(t, content))
+	require.NoError(t, err)
+	return allowlist
+}
+
+// makeTree creates the given files (and their directories) under a fresh
+// temporary directory, and returns the directory's path with any symlinks
+// resolved
+func makeTree(t *testing.T, files ...string) string {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	for _, f := range files {
+		filename := filepath.Join(root, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0700))
+		require.NoError(t, ioutil.WriteFile(filename, nil, 0700))
+	}
+	return root
+}
+
+func TestCommandAllowlist_ResolvesBinary(t *testing.T) {
+	require := require.New(t)
+	sh, err := exec.LookPath("sh")
+	require.NoError(err)
+	realSh, err := filepath.EvalSymlinks(sh)
+	require.NoError(err)
+
+	// Given an allowlist that lets alice run the shell by its absolute path
+	uut := loadAllowlist(t, `
+rules:
+  - users: [alice]
+    binaries: [`+sh+`]
+`)
+
+	// When alice asks to run the shell by name
+	binary, dir, err := uut.Check(alice, "sh", "/", []string{"-c", "true"})
+
+	// Expect that the request is allowed, and the resolved paths returned
+	require.NoError(err)
+	require.Equal(realSh, binary)
+	require.Equal("/", dir)
+
+	// ... but that bob may not run it
+	_, _, err = uut.Check(bob, "sh", "/", nil)
+	require.IsType(&CommandDenied{}, err)
+}
+
+func TestCommandAllowlist_Everyone(t *testing.T) {
+	require := require.New(t)
+	root := makeTree(t, "bin/env", "local/bin/env")
+	uut := loadAllowlist(t, `
+rules:
+  - users: ["*"]
+    binaries: [`+root+`/bin/*]
+`)
+
+	_, _, err := uut.Check(bob, root+"/bin/env", "/", nil)
+	require.NoError(err)
+
+	_, _, err = uut.Check(bob, root+"/local/../bin/env", "/", nil)
+	require.NoError(err)
+
+	_, _, err = uut.Check(bob, root+"/local/bin/env", "/", nil)
+	require.IsType(&CommandDenied{}, err)
+}
+
+func TestCommandAllowlist_RelativeBinary(t *testing.T) {
+	require := require.New(t)
+	root := makeTree(t, "build/tools/make", "tools/make")
+	uut := loadAllowlist(t, `
+rules:
+  - users: [alice]
+    binaries: [`+root+`/build/tools/*]
+`)
+
+	binary, _, err := uut.Check(alice, "./tools/make", root+"/build", nil)
+	require.NoError(err)
+	require.Equal(root+"/build/tools/make", binary)
+
+	_, _, err = uut.Check(alice, "../tools/make", root+"/build", nil)
+	require.IsType(&CommandDenied{}, err)
+}
+
+func TestCommandAllowlist_WorkingDir(t *testing.T) {
+	require := require.New(t)
+	root := makeTree(t, "bin/make", "build/levity/Makefile", "etc/passwd")
+	uut := loadAllowlist(t, `
+rules:
+  - users: [alice]
+    binaries: [`+root+`/bin/make]
+    working_dirs: [`+root+`/build, `+root+`/build/*]
+`)
+
+	_, dir, err := uut.Check(alice, root+"/bin/make", root+"/build/levity", nil)
+	require.NoError(err)
+	require.Equal(root+"/build/levity", dir)
+
+	_, _, err = uut.Check(alice, root+"/bin/make", root+"/build/../etc", nil)
+	require.Error(err)
+	require.Contains(err.Error(), "working directory "+root+"/etc not permitted")
+}
+
+func TestCommandAllowlist_Args(t *testing.T) {
+	require := require.New(t)
+	root := makeTree(t, "bin/make")
+	uut := loadAllowlist(t, `
+rules:
+  - users: [alice]
+    binaries: [`+root+`/bin/make]
+    args: ['-j[0-9]+', '[a-z]+']
+`)
+
+	_, _, err := uut.Check(alice, root+"/bin/make", "/", []string{"-j4", "all"})
+	require.NoError(err)
+
+	_, _, err = uut.Check(alice, root+"/bin/make", "/", []string{"-j4", "-f/etc/passwd"})
+	require.Error(err)
+	require.Contains(err.Error(), `argument "-f/etc/passwd" not permitted`)
+}
+
+func TestCommandAllowlist_Symlinks(t *testing.T) {
+	require := require.New(t)
+	root := makeTree(t, "allowed/make", "allowed/python3.11", "secret/tool", "build/Makefile")
+	require.NoError(os.Symlink(root+"/secret/tool", root+"/allowed/tool"))
+	require.NoError(os.Symlink(root+"/secret", root+"/build/escape"))
+	require.NoError(os.Symlink(root+"/allowed/python3.11", root+"/python3"))
+
+	// Given an allowlist that lets alice run anything in one directory, and
+	// a binary named by a symlink, in the build area
+	uut := loadAllowlist(t, `
+rules:
+  - users: [alice]
+    binaries: [`+root+`/allowed/*, `+root+`/python3]
+    working_dirs: [`+root+`/build, `+root+`/build/*]
+`)
+
+	// Expect that a symlink in the allowed directory can't be used to run a
+	// binary from elsewhere
+	_, _, err := uut.Check(alice, root+"/allowed/tool", root+"/build", nil)
+	require.IsType(&CommandDenied{}, err)
+	require.Contains(err.Error(), root+"/secret/tool")
+
+	// ... nor can a symlink in the build area be used to work elsewhere
+	_, _, err = uut.Check(alice, root+"/allowed/make", root+"/build/escape", nil)
+	require.Error(err)
+	require.Contains(err.Error(), "working directory "+root+"/secret not permitted")
+
+	// ... but that the binary named by a symlink in the rule may be run by
+	// the same name, and that what it points to is what gets run
+	binary, _, err := uut.Check(alice, root+"/python3", root+"/build", nil)
+	require.NoError(err)
+	require.Equal(root+"/allowed/python3.11", binary)
+}
+
+func TestCommandAllowlist_EmptyDeniesEverything(t *testing.T) {
+	uut := loadAllowlist(t, "rules: []\n")
+	_, _, err := uut.Check(alice, "/bin/true", "/", nil)
+	require.IsType(t, &CommandDenied{}, err)
+}
+
+func TestLoadCommandAllowlist_Invalid(t *testing.T) {
+	testCases := map[string]string{
+		"relative binary": "rules:\n  - users: [alice]\n    binaries: [make]\n",
+		"no users":        "rules:\n  - binaries: [/usr/bin/make]\n",
+		"bad regex":       "rules:\n  - users: [alice]\n    binaries: [/usr/bin/make]\n    args: ['[']\n",
+		"bad pattern":     "rules:\n  - users: [alice]\n    binaries: ['/usr/bin/[']\n",
+		"not yaml":        "rules: {",
+	}
+
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadCommandAllowlist(writeFile(t, content))
+			require.Error(t, err)
+		})
+	}
+}
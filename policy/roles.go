@@ -0,0 +1,218 @@
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/user"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the built-in roles
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+// builtinRoles defines the actions granted by each of the built-in roles.
+// These may be overridden, and others added, in the policy file.
+func builtinRoles() map[string][]task.Action {
+	return map[string][]task.Action{
+		RoleAdmin:    task.Actions,
+		RoleOperator: {task.ActionQuery, task.ActionSignal},
+		RoleViewer:   {task.ActionLogs},
+	}
+}
+
+type actionSet map[task.Action]struct{}
+
+// roleBinding grants a role to a set of users and groups
+type roleBinding struct {
+	Role   string   `yaml:"role"`
+	Users  []string `yaml:"users"`
+	Groups []string `yaml:"groups"`
+}
+
+// roleFile describes the layout of a role policy file, e.g.
+//
+//	groups:
+//	  oncall: [alice, bob]
+//
+//	roles:
+//	  support: [query, logs]
+//
+//	bindings:
+//	  - role: admin
+//	    users: [carol]
+//	  - role: operator
+//	    groups: [oncall]
+//
+// By default any user may start a task. Setting `restrict_start: true` limits
+// this to users holding a role that grants the `start` action.
+type roleFile struct {
+	Groups        map[string][]string `yaml:"groups"`
+	Roles         map[string][]string `yaml:"roles"`
+	Bindings      []roleBinding       `yaml:"bindings"`
+	RestrictStart bool                `yaml:"restrict_start"`
+}
+
+// RolePolicy is an authorisation policy that lets the owner of a task do
+// anything with it, and grants other users access to tasks they don't own
+// via the roles bound to them. Roles apply to every task on the server.
+//
+// A user's groups are those defined for them in the policy file, plus any
+// asserted by their credentials. Roles asserted by a user's credentials are
+// honoured if the policy knows of a role by that name.
+//
+// Once created, a RolePolicy is immutable and may be shared between
+// goroutines.
+type RolePolicy struct {
+	roles         map[string]actionSet
+	userGrants    map[string]actionSet
+	groupGrants   map[string]actionSet
+	memberOf      map[string][]string
+	restrictStart bool
+}
+
+// LoadRolePolicy reads a role-based authorisation policy from a YAML file.
+func LoadRolePolicy(path string) (*RolePolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file roleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	p, err := compileRoles(&file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return p, nil
+}
+
+func compileRoles(file *roleFile) (*RolePolicy, error) {
+	p := &RolePolicy{
+		roles:         make(map[string]actionSet),
+		userGrants:    make(map[string]actionSet),
+		groupGrants:   make(map[string]actionSet),
+		memberOf:      make(map[string][]string),
+		restrictStart: file.RestrictStart,
+	}
+
+	for name, actions := range builtinRoles() {
+		p.roles[name] = newActionSet(actions...)
+	}
+
+	for name, actionNames := range file.Roles {
+		actions := make(actionSet, len(actionNames))
+		for _, actionName := range actionNames {
+			a, ok := task.ParseAction(actionName)
+			if !ok {
+				return nil, fmt.Errorf("role %s: unknown action %q", name, actionName)
+			}
+			actions[a] = struct{}{}
+		}
+		p.roles[name] = actions
+	}
+
+	for group, members := range file.Groups {
+		for _, login := range members {
+			p.memberOf[login] = append(p.memberOf[login], group)
+		}
+	}
+
+	// NB: Bindings may name groups that aren't defined in the policy file,
+	//     as they may be asserted by user credentials instead.
+	for i, binding := range file.Bindings {
+		actions, ok := p.roles[binding.Role]
+		if !ok {
+			return nil, fmt.Errorf("binding %d: unknown role %q", i+1, binding.Role)
+		}
+
+		for _, login := range binding.Users {
+			grant(p.userGrants, login, actions)
+		}
+
+		for _, group := range binding.Groups {
+			grant(p.groupGrants, group, actions)
+		}
+	}
+
+	return p, nil
+}
+
+func newActionSet(actions ...task.Action) actionSet {
+	result := make(actionSet, len(actions))
+	for _, a := range actions {
+		result[a] = struct{}{}
+	}
+	return result
+}
+
+func grant(grants map[string]actionSet, name string, actions actionSet) {
+	granted, ok := grants[name]
+	if !ok {
+		granted = make(actionSet)
+		grants[name] = granted
+	}
+
+	for a := range actions {
+		granted[a] = struct{}{}
+	}
+}
+
+// groupsOf lists all of the groups the user belongs to
+func (p *RolePolicy) groupsOf(u *user.User) []string {
+	defined := p.memberOf[u.Login()]
+	result := make([]string, 0, len(defined))
+	result = append(result, defined...)
+	return append(result, u.Groups()...)
+}
+
+// granted tests if any of the user's roles grants them the action on every
+// task
+func (p *RolePolicy) granted(u *user.User, groups []string, action task.Action) bool {
+	if _, ok := p.userGrants[u.Login()][action]; ok {
+		return true
+	}
+
+	for _, group := range groups {
+		if _, ok := p.groupGrants[group][action]; ok {
+			return true
+		}
+	}
+
+	for _, role := range u.Roles() {
+		if _, ok := p.roles[role][action]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsStart tests if the user may start new tasks. Anyone may, unless
+// starting is restricted to those with a role that grants it.
+func (p *RolePolicy) AllowsStart(u *user.User) bool {
+	return !p.restrictStart || p.granted(u, p.groupsOf(u), task.ActionStart)
+}
+
+// Allows tests if the user may perform the action on the task.
+func (p *RolePolicy) Allows(u *user.User, action task.Action, t *task.Task) bool {
+	// The user starting a task will always be its owner, so starting
+	// has to be handled separately
+	if action == task.ActionStart {
+		return p.AllowsStart(u)
+	}
+
+	groups := p.groupsOf(u)
+	return p.granted(u, groups, action) ||
+		u.Is(t.Owner()) ||
+		t.IsSharedWith(u.Login(), groups, action)
+}
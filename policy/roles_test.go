@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/user"
+)
+
+var (
+	carol = user.New("carol")
+	dave  = user.New("dave")
+	erin  = user.New("erin")
+)
+
+func loadRoles(t *testing.T, content string) *RolePolicy {
+	p, err := LoadRolePolicy(writeFile(t, content))
+	require.NoError(t, err)
+	return p
+}
+
+const testRoles = `
+groups:
+  oncall: [bob, dave]
+
+roles:
+  support: [query, logs]
+
+bindings:
+  - role: admin
+    users: [carol]
+  - role: operator
+    groups: [oncall]
+  - role: viewer
+    users: [dave]
+  - role: support
+    users: [erin]
+`
+
+func TestRolePolicy_OwnerMayDoAnything(t *testing.T) {
+	uut := loadRoles(t, testRoles)
+	alicesTask := task.New(alice, "true", "", nil)
+
+	for _, action := range task.Actions {
+		require.True(t, uut.Allows(alice, action, alicesTask), action)
+	}
+}
+
+func TestRolePolicy_Roles(t *testing.T) {
+	uut := loadRoles(t, testRoles)
+	alicesTask := task.New(alice, "true", "", nil)
+
+	type testCase struct {
+		user   *user.User
+		action task.Action
+		expect bool
+	}
+
+	testCases := []testCase{
+		// carol is an admin, and may do anything
+		{user: carol, action: task.ActionQuery, expect: true},
+		{user: carol, action: task.ActionLogs, expect: true},
+		{user: carol, action: task.ActionSignal, expect: true},
+		{user: carol, action: task.ActionDelete, expect: true},
+		{user: carol, action: task.ActionAttach, expect: true},
+
+		// bob is an operator via the oncall group
+		{user: bob, action: task.ActionQuery, expect: true},
+		{user: bob, action: task.ActionLogs, expect: false},
+		{user: bob, action: task.ActionSignal, expect: true},
+
+		// dave is both an operator and a viewer
+		{user: dave, action: task.ActionQuery, expect: true},
+		{user: dave, action: task.ActionLogs, expect: true},
+		{user: dave, action: task.ActionSignal, expect: true},
+
+		// erin has the custom support role
+		{user: erin, action: task.ActionQuery, expect: true},
+		{user: erin, action: task.ActionLogs, expect: true},
+		{user: erin, action: task.ActionSignal, expect: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.user.Login()+"/"+string(tc.action), func(t *testing.T) {
+			require.Equal(t, tc.expect, uut.Allows(tc.user, tc.action, alicesTask))
+		})
+	}
+}
+
+func TestRolePolicy_NoRoles(t *testing.T) {
+	uut := loadRoles(t, "")
+	alicesTask := task.New(alice, "true", "", nil)
+
+	for _, action := range task.Actions {
+		if action == task.ActionStart {
+			continue
+		}
+		require.False(t, uut.Allows(bob, action, alicesTask), action)
+	}
+}
+
+func TestRolePolicy_SharedWithGroup(t *testing.T) {
+	require := require.New(t)
+	uut := loadRoles(t, testRoles)
+
+	// Given a task shared with the oncall group
+	alicesTask := task.New(alice, "true", "", nil)
+	alicesTask.Share(nil, []string{"oncall"}, task.ActionLogs)
+
+	// Expect that members of the group may read the logs...
+	require.True(uut.Allows(bob, task.ActionLogs, alicesTask))
+
+	// ... and that non-members may not
+	require.False(uut.Allows(user.New("frank"), task.ActionLogs, alicesTask))
+}
+
+func TestRolePolicy_CredentialClaims(t *testing.T) {
+	require := require.New(t)
+	uut := loadRoles(t, testRoles+`
+  - role: viewer
+    groups: [support-staff]
+`)
+	alicesTask := task.New(alice, "true", "", nil)
+
+	// A user whose credentials put them in a group bound to a role gets
+	// the role's permissions
+	frank := user.NewWithClaims("frank", []string{"support-staff"}, nil)
+	require.True(uut.Allows(frank, task.ActionLogs, alicesTask))
+	require.False(uut.Allows(frank, task.ActionSignal, alicesTask))
+
+	// ... as does one whose credentials assert a known role directly
+	grace := user.NewWithClaims("grace", nil, []string{RoleOperator})
+	require.True(uut.Allows(grace, task.ActionSignal, alicesTask))
+	require.False(uut.Allows(grace, task.ActionLogs, alicesTask))
+
+	// ... but unknown roles grant nothing
+	heidi := user.NewWithClaims("heidi", nil, []string{"superuser"})
+	require.False(uut.Allows(heidi, task.ActionQuery, alicesTask))
+
+	// ... and claimed groups count when a task is shared with them
+	alicesTask.Share(nil, []string{"auditors"}, task.ActionQuery)
+	ivan := user.NewWithClaims("ivan", []string{"auditors"}, nil)
+	require.True(uut.Allows(ivan, task.ActionQuery, alicesTask))
+}
+
+func TestRolePolicy_Start(t *testing.T) {
+	require := require.New(t)
+	bobsTask := task.New(bob, "true", "", nil)
+	carolsTask := task.New(carol, "true", "", nil)
+
+	// By default, anyone may start a task
+	uut := loadRoles(t, testRoles)
+	require.True(uut.AllowsStart(bob))
+	require.True(uut.Allows(bob, task.ActionStart, bobsTask))
+
+	// ... but when starting is restricted, only those with a role that
+	// grants it may do so
+	uut = loadRoles(t, testRoles+"restrict_start: true\n")
+	require.False(uut.AllowsStart(bob))
+	require.False(uut.Allows(bob, task.ActionStart, bobsTask))
+	require.True(uut.AllowsStart(carol))
+	require.True(uut.Allows(carol, task.ActionStart, carolsTask))
+}
+
+func TestLoadRolePolicy_Invalid(t *testing.T) {
+	testCases := map[string]string{
+		"unknown role":   "bindings:\n  - role: superuser\n    users: [bob]\n",
+		"unknown action": "roles:\n  destroyer: [delete-everything]\n",
+		"not yaml":       "bindings: [",
+	}
+
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadRolePolicy(writeFile(t, content))
+			require.Error(t, err)
+		})
+	}
+}
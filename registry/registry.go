@@ -62,9 +62,27 @@ func (registry *Registry) Register(t *task.Task) string {
 	return handle
 }
 
+// Remove forgets about the task with the given handle, if it exists. The
+// task itself is unaffected.
+func (registry *Registry) Remove(handle string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	delete(registry.db, handle)
+}
+
 // Len fetches the number of tasks stored in the registry
 func (registry *Registry) Len() int {
 	registry.lock.RLock()
 	defer registry.lock.RUnlock()
 	return len(registry.db)
 }
+
+// Range calls `f` for every task in the registry. The registry is locked for
+// the duration, so `f` must not call back into the registry.
+func (registry *Registry) Range(f func(id string, t *task.Task)) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	for id, t := range registry.db {
+		f(id, t)
+	}
+}
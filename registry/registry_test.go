@@ -27,3 +27,37 @@ func TestLookupNonExistantTask(t *testing.T) {
 	task := uut.Lookup("no-such-task")
 	require.Nil(task)
 }
+
+func TestRange(t *testing.T) {
+	require := require.New(t)
+	uut := New()
+
+	a := task.New(user.New("alice"), "true", ".", nil)
+	b := task.New(user.New("bob"), "true", ".", nil)
+	idA := uut.Register(a)
+	idB := uut.Register(b)
+
+	seen := make(map[string]*task.Task)
+	uut.Range(func(id string, t *task.Task) {
+		seen[id] = t
+	})
+
+	require.Equal(map[string]*task.Task{idA: a, idB: b}, seen)
+}
+
+func TestRemove(t *testing.T) {
+	require := require.New(t)
+	uut := New()
+
+	a := task.New(user.New("alice"), "true", ".", nil)
+	b := task.New(user.New("bob"), "true", ".", nil)
+	idA := uut.Register(a)
+	idB := uut.Register(b)
+
+	uut.Remove(idA)
+	uut.Remove("no-such-task")
+
+	require.Nil(uut.Lookup(idA))
+	require.Same(b, uut.Lookup(idB))
+	require.Equal(1, uut.Len())
+}
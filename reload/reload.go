@@ -0,0 +1,70 @@
+// Package reload provides a simple mechanism for noticing that configuration
+// files have changed on disk.
+package reload
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultInterval is a reasonable default for how often to poll files for
+// changes.
+const DefaultInterval = 10 * time.Second
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+func snapshot(paths []string) []fileState {
+	result := make([]fileState, len(paths))
+	for i, path := range paths {
+		result[i] = stat(path)
+	}
+	return result
+}
+
+// Watch polls the files at the given paths, calling `onChange` whenever any
+// of them is modified, created or removed. Watch blocks until the context is
+// cancelled, so will usually be run in its own goroutine.
+//
+// Polling is crude, but is portable and doesn't need any extra dependencies.
+// The files being watched are expected to change rarely.
+func Watch(ctx context.Context, interval time.Duration, onChange func(), paths ...string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := snapshot(paths)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			current := snapshot(paths)
+			for i := range current {
+				if current[i] != previous[i] {
+					log.Printf("Detected change to %s", paths[i])
+					onChange()
+					break
+				}
+			}
+			previous = current
+		}
+	}
+}
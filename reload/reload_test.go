@@ -0,0 +1,41 @@
+package reload
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	require := require.New(t)
+	filename := path.Join(t.TempDir(), "config")
+	require.NoError(ioutil.WriteFile(filename, []byte("one"), 0600))
+
+	// Given a watcher on a file
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go Watch(ctx, 10*time.Millisecond, func() { changes <- struct{}{} }, filename)
+
+	// Expect that nothing is reported while the file is unchanged
+	select {
+	case <-changes:
+		require.FailNow("Unexpected change reported")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// When the file is modified
+	require.NoError(ioutil.WriteFile(filename, []byte("three"), 0600))
+
+	// Expect that the change is reported
+	select {
+	case <-changes:
+	case <-time.After(1 * time.Second):
+		require.FailNow("Timed out waiting for change")
+	}
+}
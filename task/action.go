@@ -0,0 +1,52 @@
+package task
+
+// Action identifies an operation that a user may perform on a task, for the
+// purposes of authorisation.
+type Action string
+
+const (
+	// ActionStart launches a new task
+	ActionStart Action = "start"
+
+	// ActionQuery fetches the task status
+	ActionQuery Action = "query"
+
+	// ActionLogs fetches the task output
+	ActionLogs Action = "logs"
+
+	// ActionSignal asks the task to quit
+	ActionSignal Action = "signal"
+
+	// ActionShare grants other users permission to act on the task
+	ActionShare Action = "share"
+
+	// ActionDelete removes a task record from the server. There is no API
+	// for this yet, but policies may already refer to it.
+	ActionDelete Action = "delete"
+
+	// ActionAttach connects to a running task's input and output streams.
+	// There is no API for this yet, but policies may already refer to it.
+	ActionAttach Action = "attach"
+)
+
+// Actions lists every action that may be performed on a task
+var Actions = []Action{
+	ActionStart,
+	ActionQuery,
+	ActionLogs,
+	ActionSignal,
+	ActionShare,
+	ActionDelete,
+	ActionAttach,
+}
+
+// ParseAction converts an action name into an Action, returning false if the
+// name does not identify a known action.
+func ParseAction(name string) (Action, bool) {
+	for _, a := range Actions {
+		if string(a) == name {
+			return a, true
+		}
+	}
+	return "", false
+}
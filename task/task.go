@@ -7,12 +7,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/tracing"
 	"github.com/tcsc/levity/user"
 )
 
@@ -24,28 +29,88 @@ var ErrInvalidState = errors.New("task in invalid state for operation")
 
 // streamReader catches the output from one of a Cmd's output streams (i.e.
 // stdout or stderr) and writes it out to a byte buffer in a Task, under
-// a write lock.
+// a write lock. If the buffer has a size limit, any output beyond it is
+// discarded. Anyone waiting on the Task's output is woken whenever data is
+// kept.
 type streamReader struct {
-	lock *sync.RWMutex
-	dst  *bytes.Buffer
+	lock    *sync.RWMutex
+	dst     *bytes.Buffer
+	limit   *int
+	written *chan struct{}
 }
 
 func (r *streamReader) Write(b []byte) (n int, err error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
-	return r.dst.Write(b)
+
+	// NB: Always claim to have written everything, even if some of it was
+	//     discarded. Otherwise the process would see a failed write on its
+	//     output stream.
+	kept := b
+	if limit := *r.limit; limit > 0 {
+		room := limit - r.dst.Len()
+		if room < 0 {
+			room = 0
+		}
+		if len(kept) > room {
+			kept = kept[:room]
+		}
+	}
+	if len(kept) > 0 {
+		r.dst.Write(kept)
+		close(*r.written)
+		*r.written = make(chan struct{})
+	}
+	return len(b), nil
+}
+
+// output connects one of the process's output streams to a streamReader.
+// The task creates the pipe itself, rather than leaving it to exec, so that
+// it can hand the read end on to another process when detaching.
+type output struct {
+	reader *streamReader
+	pipe   *os.File
+}
+
+// grants records the actions that users other than the owner may perform on
+// a task, keyed by login (or group) name
+type grants map[string]map[Action]struct{}
+
+func (g grants) add(name string, actions []Action) {
+	granted, ok := g[name]
+	if !ok {
+		granted = make(map[Action]struct{}, len(actions))
+		g[name] = granted
+	}
+
+	for _, a := range actions {
+		granted[a] = struct{}{}
+	}
+}
+
+func (g grants) allows(name string, action Action) bool {
+	_, ok := g[name][action]
+	return ok
 }
 
 // Task represents a task that has been invoked by the API server.
 type Task struct {
-	lock       sync.RWMutex
-	owner      *user.User
-	cmd        *exec.Cmd
-	stdout     bytes.Buffer
-	stderr     bytes.Buffer
-	statusCode api.TaskStatusCode
-	exitCode   int
-	done       chan struct{}
+	lock        sync.RWMutex
+	owner       *user.User
+	labels      map[string]string
+	userGrants  grants
+	groupGrants grants
+	cmd         *exec.Cmd
+	stdout      bytes.Buffer
+	stderr      bytes.Buffer
+	outputs     []*output
+	copying     sync.WaitGroup
+	outputLimit int
+	written     chan struct{}
+	statusCode  api.TaskStatusCode
+	exitCode    int
+	signal      syscall.Signal
+	done        chan struct{}
 }
 
 // New creates (but does not start) new task
@@ -59,23 +124,114 @@ func New(owner *user.User, binary string, workingDir string, env map[string]stri
 	// wrap it in a Task to provide locking, and bind the output streams to readers
 	// that will capture the stream data and write it to the given buffers
 	t := Task{
-		owner:      owner,
-		cmd:        cmd,
-		statusCode: api.TaskStatusCode_NotStarted,
-		done:       make(chan struct{}),
-		exitCode:   int(InvalidExitCode),
+		owner:       owner,
+		userGrants:  make(grants),
+		groupGrants: make(grants),
+		cmd:         cmd,
+		statusCode:  api.TaskStatusCode_NotStarted,
+		written:     make(chan struct{}),
+		done:        make(chan struct{}),
+		exitCode:    int(InvalidExitCode),
 	}
-	t.cmd.Stdout = &streamReader{lock: &t.lock, dst: &t.stdout}
-	t.cmd.Stderr = &streamReader{lock: &t.lock, dst: &t.stderr}
+	t.outputs = []*output{
+		{reader: &streamReader{lock: &t.lock, dst: &t.stdout, limit: &t.outputLimit, written: &t.written}},
+		{reader: &streamReader{lock: &t.lock, dst: &t.stderr, limit: &t.outputLimit, written: &t.written}},
+	}
+
+	// Each task runs in a process group of its own, so that signals meant
+	// for the server (e.g. an interrupt from its terminal) don't reach it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	return &t
 }
 
+// LimitOutput caps the number of bytes of stdout and stderr (each) that the
+// task will hold on to. Output beyond the limit is discarded. Zero means no
+// limit, which is the default.
+func (t *Task) LimitOutput(maxBytes int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.outputLimit = maxBytes
+}
+
+// SetLabels replaces the labels attached to the task, which identify it for
+// the purposes of selecting it along with other tasks
+func (t *Task) SetLabels(labels map[string]string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.labels = make(map[string]string, len(labels))
+	for k, v := range labels {
+		t.labels[k] = v
+	}
+}
+
+// Labels creates and returns a copy of the labels attached to the task
+func (t *Task) Labels() map[string]string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	result := make(map[string]string, len(t.labels))
+	for k, v := range t.labels {
+		result[k] = v
+	}
+	return result
+}
+
+// HasLabels tests if the task has all of the given labels, with the same
+// values
+func (t *Task) HasLabels(labels map[string]string) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for k, v := range labels {
+		if actual, ok := t.labels[k]; !ok || actual != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Owner fetches a reference to the task's owner.
 func (t *Task) Owner() *user.User {
 	return t.owner
 }
 
+// Share grants the listed users and groups permission to perform the given
+// actions on the task, in addition to any permissions they already have.
+func (t *Task) Share(logins []string, groups []string, actions ...Action) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, login := range logins {
+		t.userGrants.add(login, actions)
+	}
+
+	for _, group := range groups {
+		t.groupGrants.add(group, actions)
+	}
+}
+
+// IsSharedWith tests if the task has been shared with the named user, or any
+// of the groups they belong to, for the given action. Note that this does not
+// consider the task owner; the owner is not recorded as a share.
+func (t *Task) IsSharedWith(login string, groups []string, action Action) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.userGrants.allows(login, action) {
+		return true
+	}
+
+	for _, group := range groups {
+		if t.groupGrants.allows(group, action) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Start starts the task running
 func (t *Task) Start() error {
 	t.lock.Lock()
@@ -85,13 +241,45 @@ func (t *Task) Start() error {
 		return ErrInvalidState
 	}
 
+	// The process gets the write end of each pipe, and we read from the
+	// other end until every process holding the write end has closed it
+	writers := make([]*os.File, 0, len(t.outputs))
+	defer func() {
+		for _, w := range writers {
+			w.Close()
+		}
+	}()
+	for _, o := range t.outputs {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.closeOutputs()
+			return err
+		}
+		o.pipe = r
+		writers = append(writers, w)
+	}
+	t.cmd.Stdout = writers[0]
+	t.cmd.Stderr = writers[1]
+
 	err := t.cmd.Start()
 	if err != nil {
+		t.closeOutputs()
 		return err
 	}
 
 	t.statusCode = api.TaskStatusCode_Running
 
+	for _, o := range t.outputs {
+		t.copying.Add(1)
+		go func(o *output) {
+			defer t.copying.Done()
+			// NB: The streamReader never fails, so the copy only ends when
+			//     the pipe is closed at the other end, or the task is
+			//     detached
+			_, _ = io.Copy(o.reader, o.pipe)
+		}(o)
+	}
+
 	// The `monitor` will wait on the underlying process to complete,
 	// perform some post-exit bookeeping and then exit as well.
 	go func() {
@@ -133,9 +321,10 @@ func (t *Task) Signal(ctx context.Context) error {
 		return nil
 	}
 
-	// Signal the task to quit
+	// Signal the task to quit, along with anything it has started, which
+	// would otherwise be left running (and holding its output streams open)
 	t.statusCode = api.TaskStatusCode_Signalled
-	err := t.cmd.Process.Signal(syscall.SIGTERM)
+	err := t.signalGroup(syscall.SIGTERM)
 	if err != nil {
 		return err
 	}
@@ -150,7 +339,9 @@ func monitorSignalContext(ctx context.Context, t *Task) {
 	case <-ctx.Done():
 		// The context (or owner) has decided its time to stop waiting for the
 		// process to die naturally, so it's now time to shoot it.
+		_, span := tracing.Tracer().Start(ctx, "task.kill")
 		t.brutalKill()
+		span.End()
 
 	case <-t.Done():
 		// The task has exited naturally before the timeout expired. No
@@ -182,7 +373,7 @@ func (t *Task) brutalKill() {
 	}
 
 	t.statusCode = api.TaskStatusCode_BrutallyKilled
-	err := t.cmd.Process.Kill()
+	err := t.signalGroup(syscall.SIGKILL)
 	if err != nil {
 		// Seems a bit excessive to panic here; The process just may have
 		// exited between us deciding to kill it and us actually doing it.
@@ -192,6 +383,13 @@ func (t *Task) brutalKill() {
 	}
 }
 
+// signalGroup sends a signal to every process in the task's process group.
+// The group outlives the task's own process for as long as anything it
+// started is still running.
+func (t *Task) signalGroup(sig syscall.Signal) error {
+	return syscall.Kill(-t.cmd.Process.Pid, sig)
+}
+
 func cloneSlice(src []byte) []byte {
 	result := make([]byte, len(src))
 	copy(result, src)
@@ -212,6 +410,25 @@ func (t *Task) Stderr() []byte {
 	return cloneSlice(t.stderr.Bytes())
 }
 
+// OutputSize fetches the number of bytes of stdout and stderr data captured
+// so far, without copying it.
+func (t *Task) OutputSize() (stdout int, stderr int) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.stdout.Len(), t.stderr.Len()
+}
+
+// OutputSince creates and returns copies of any stdout and stderr data beyond
+// the given offsets, along with a channel that will be closed when more data
+// is captured. Once the task is Done, all of its output has been captured.
+func (t *Task) OutputSince(stdoutOffset int, stderrOffset int) (stdout []byte, stderr []byte, more <-chan struct{}) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return cloneSlice(t.stdout.Bytes()[stdoutOffset:]),
+		cloneSlice(t.stderr.Bytes()[stderrOffset:]),
+		t.written
+}
+
 // monitor is executed in a goroutine and moves the process exit code (in the
 // form of an *os.Process) into place when the underlying process has exited
 func (t *Task) monitor() error {
@@ -219,6 +436,7 @@ func (t *Task) monitor() error {
 	// of the IO streams to be flushed and closed, otherwise we have a data
 	// race on the Cmd that we wrap.
 	var exitCode int
+	var signal syscall.Signal
 	switch err := t.cmd.Wait().(type) {
 	case nil:
 		exitCode = t.cmd.ProcessState.ExitCode()
@@ -226,25 +444,127 @@ func (t *Task) monitor() error {
 	case *exec.ExitError:
 		// For our purposes, this counts as a clean exit
 		exitCode = err.ProcessState.ExitCode()
+		if ws, ok := err.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			signal = ws.Signal()
+		}
 
 	default:
 		return err
 	}
 
+	// Make sure all of the output has been captured before reporting that
+	// the task is over
+	t.copying.Wait()
+
 	// Now that we *know* the underlying process has finished, we can clean
 	// up the Cmd while we have it locked, averting the data race
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
+	t.closeOutputs()
+
 	if t.statusCode != api.TaskStatusCode_BrutallyKilled {
 		t.statusCode = api.TaskStatusCode_Finished
 	}
 	t.exitCode = exitCode
+	t.signal = signal
 	close(t.done)
 
 	return nil
 }
 
+// closeOutputs closes the read ends of the task's output pipes. Must be
+// called with the lock held.
+func (t *Task) closeOutputs() {
+	for _, o := range t.outputs {
+		if o.pipe != nil {
+			o.pipe.Close()
+			o.pipe = nil
+		}
+	}
+}
+
+// drainPaths are the places to look for the `cat` used to drain a detached
+// task's output. It's looked for in fixed locations, rather than on the
+// server's PATH, so that what runs doesn't depend on the server's
+// environment.
+var drainPaths = []string{"/bin/cat", "/usr/bin/cat"}
+
+// drainBinary finds the `cat` used to drain a detached task's output
+func drainBinary() (string, error) {
+	for _, p := range drainPaths {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("Can't detach task: no cat to drain its output (looked in %s)",
+		strings.Join(drainPaths, ", "))
+}
+
+// Detach prepares a running task to outlive the server. The server stops
+// capturing the task's output, and hands its output pipes to helper
+// processes that discard whatever is written to them, so that the task can
+// carry on writing after the server has exited, rather than being killed by
+// SIGPIPE. Any output written before the task was detached is kept.
+func (t *Task) Detach() error {
+	cat, err := drainBinary()
+	if err != nil {
+		return err
+	}
+
+	// Stop copying the output, so that the server doesn't compete with the
+	// helpers for it. NB: The copies take the lock to store what they read,
+	// so we have to let go of it while waiting for them to finish.
+	t.lock.Lock()
+	if !t.isRunning() {
+		t.lock.Unlock()
+		return nil
+	}
+	for _, o := range t.outputs {
+		if err := o.pipe.SetReadDeadline(time.Now()); err != nil {
+			t.lock.Unlock()
+			return err
+		}
+	}
+	t.lock.Unlock()
+	t.copying.Wait()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The task may have finished while we were waiting, in which case its
+	// output pipes have already been closed
+	if !t.isRunning() {
+		return nil
+	}
+
+	for _, o := range t.outputs {
+		drain := exec.Command(cat)
+		drain.Stdin = o.pipe
+		drain.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := drain.Start(); err != nil {
+			return err
+		}
+
+		// The drain outlives us, so there's nothing to wait for
+		if err := drain.Process.Release(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isRunning tests if the task's process is still running (or has been
+// signalled, but not yet exited). Must be called with the lock held.
+func (t *Task) isRunning() bool {
+	switch t.statusCode {
+	case api.TaskStatusCode_Running, api.TaskStatusCode_Signalled:
+		return true
+	}
+	return false
+}
+
 func formatEnvironment(env map[string]string) []string {
 	result := make([]string, 0, len(env))
 	for k, v := range env {
@@ -261,6 +581,14 @@ func (t *Task) ExitCode() int {
 	return t.exitCode
 }
 
+// TerminatingSignal returns the signal that terminated the task process, or zero if it
+// is still running or exited normally.
+func (t *Task) TerminatingSignal() syscall.Signal {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.signal
+}
+
 // Status fetches the tasks status and exit code (if any). -1 indicated that
 // no exit code is available yet.
 func (t *Task) Status() (api.TaskStatusCode, int) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"syscall"
 	"testing"
 	"time"
 
@@ -118,6 +119,34 @@ func TestCaptureStderr(t *testing.T) {
 	)
 }
 
+func TestLimitOutput(t *testing.T) {
+	require := require.New(t)
+
+	// Given a task with limited output that writes more than the limit, in
+	// several pieces
+	uut := New(
+		alice,
+		"bash",
+		"",
+		map[string]string{},
+		"-c",
+		"for i in 1 2 3 4; do echo -n 0123456789; 1>&2 echo -n abc; done; echo done",
+	)
+	uut.LimitOutput(25)
+	require.NoError(uut.Start())
+
+	// When I let the task run to completion
+	require.NoError(await(uut, 1*time.Second))
+
+	// Expect that only the start of the output was kept, and that the task
+	// was unaffected by the rest being thrown away
+	require.Equal([]byte("0123456789012345678901234"), uut.Stdout())
+	require.Equal([]byte("abcabcabcabc"), uut.Stderr())
+	statusCode, exitCode := uut.Status()
+	require.Equal(api.TaskStatusCode_Finished, statusCode)
+	require.Equal(0, exitCode)
+}
+
 func TestNonZeroExitCode(t *testing.T) {
 	require := require.New(t)
 	assert := assert.New(t)
@@ -257,8 +286,59 @@ func TestSignalTimeout(t *testing.T) {
 	// with a status indicating that it was brutally killed
 	require.NoError(await(uut, 2*time.Second))
 
-	// also expect that it will have the "brutal kill" status
+	// also expect that it will have the "brutal kill" status, and that it
+	// was killed with SIGKILL
 	assert.Equal(api.TaskStatusCode_BrutallyKilled, uut.statusCode)
+	assert.Equal(syscall.SIGKILL, uut.TerminatingSignal())
+}
+
+func TestTerminatingSignal(t *testing.T) {
+	require := require.New(t)
+
+	// Given a task that exits normally
+	uut := New(alice, "true", "", nil)
+	require.NoError(uut.Start())
+	require.NoError(await(uut, 2*time.Second))
+
+	// Expect that it has no terminating signal
+	require.Equal(syscall.Signal(0), uut.TerminatingSignal())
+
+	// Given a task that is killed by a signal
+	uut = New(alice, "sh", "", nil, "-c", "kill -TERM $$")
+	require.NoError(uut.Start())
+	require.NoError(await(uut, 2*time.Second))
+
+	// Expect that the signal is recorded
+	require.Equal(syscall.SIGTERM, uut.TerminatingSignal())
+	require.Equal(int(InvalidExitCode), uut.ExitCode())
+}
+
+func TestOutputSince(t *testing.T) {
+	require := require.New(t)
+
+	// Given a task that writes to stdout, then stderr, a little while later
+	uut := New(alice, "sh", "", nil, "-c", "echo one; sleep 0.2; echo two >&2")
+	stdout, stderr, more := uut.OutputSince(0, 0)
+	require.Empty(stdout)
+	require.Empty(stderr)
+	require.NoError(uut.Start())
+
+	// Expect to be woken when the first output arrives
+	select {
+	case <-more:
+	case <-time.After(2 * time.Second):
+		require.Fail("Timed out waiting for output")
+	}
+	stdout, stderr, _ = uut.OutputSince(0, 0)
+	require.Equal("one\n", string(stdout))
+	require.Empty(stderr)
+
+	// When the task has finished, expect only the output beyond the given
+	// offsets to be returned
+	require.NoError(await(uut, 2*time.Second))
+	stdout, stderr, _ = uut.OutputSince(len("one\n"), 0)
+	require.Empty(stdout)
+	require.Equal("two\n", string(stderr))
 }
 func TestEnvironment(t *testing.T) {
 	assert := assert.New(t)
@@ -303,3 +383,128 @@ func TestWorkingDir(t *testing.T) {
 	// The task's stdout should indicate that pwd was run under "/"
 	assert.Equal([]byte("/\n"), uut.Stdout())
 }
+
+func TestShare(t *testing.T) {
+	assert := assert.New(t)
+
+	// Given a task that has been shared with some users and groups
+	uut := New(alice, "true", "", nil)
+	uut.Share([]string{"bob"}, nil, ActionQuery, ActionLogs)
+	uut.Share([]string{"bob"}, []string{"oncall"}, ActionSignal)
+
+	// Expect that grants accumulate for the named users
+	assert.True(uut.IsSharedWith("bob", nil, ActionQuery))
+	assert.True(uut.IsSharedWith("bob", nil, ActionLogs))
+	assert.True(uut.IsSharedWith("bob", nil, ActionSignal))
+
+	// ... that group members get the group's grants only
+	assert.True(uut.IsSharedWith("carol", []string{"staff", "oncall"}, ActionSignal))
+	assert.False(uut.IsSharedWith("carol", []string{"staff", "oncall"}, ActionLogs))
+
+	// ... and that nobody else gets anything
+	assert.False(uut.IsSharedWith("carol", nil, ActionSignal))
+	assert.False(uut.IsSharedWith("alice", nil, ActionQuery))
+}
+
+func TestLabels(t *testing.T) {
+	require := require.New(t)
+
+	// Given a task with some labels
+	labels := map[string]string{"env": "ci", "job": "1234"}
+	uut := New(alice, "true", "", nil)
+	uut.SetLabels(labels)
+
+	// Expect that changing the original labels doesn't affect the task
+	labels["env"] = "prod"
+	require.Equal(map[string]string{"env": "ci", "job": "1234"}, uut.Labels())
+
+	// Expect that the task matches any subset of its labels
+	require.True(uut.HasLabels(nil))
+	require.True(uut.HasLabels(map[string]string{"env": "ci"}))
+	require.True(uut.HasLabels(map[string]string{"env": "ci", "job": "1234"}))
+
+	// ... but not labels with other values, or that it doesn't have
+	require.False(uut.HasLabels(map[string]string{"env": "prod"}))
+	require.False(uut.HasLabels(map[string]string{"owner": "alice"}))
+}
+
+func TestProcessGroup(t *testing.T) {
+	require := require.New(t)
+
+	// Given a running task
+	uut := New(alice, "sleep", "", nil, "60")
+	require.NoError(uut.Start())
+	defer uut.cmd.Process.Kill()
+
+	// Expect it to lead a process group of its own, so that signals sent to
+	// the server's process group don't reach it
+	pgid, err := syscall.Getpgid(uut.cmd.Process.Pid)
+	require.NoError(err)
+	require.Equal(uut.cmd.Process.Pid, pgid)
+}
+
+func TestSignalProcessGroup(t *testing.T) {
+	require := require.New(t)
+
+	// Given a task that starts a child process that ignores SIGTERM, and
+	// holds the task's output open after the task itself has exited
+	uut := New(alice, "sh", "", nil, "-c", "(trap '' TERM; sleep 60) & echo Ready; exec sleep 60")
+	require.NoError(uut.Start())
+	for !sliceContains(uut.Stdout(), []byte("Ready")) {
+		<-time.After(10 * time.Millisecond)
+	}
+
+	// When we signal it to quit, brutally killing it after a short wait
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	require.NoError(uut.Signal(ctx))
+
+	// Expect that the child is killed along with the task, so that the task
+	// finishes rather than waiting forever for its output to be closed
+	require.NoError(await(uut, 5*time.Second))
+	require.Equal(api.TaskStatusCode_BrutallyKilled, uut.statusCode)
+}
+
+func TestDetach(t *testing.T) {
+	require := require.New(t)
+
+	// Given a task that writes output until it's told to quit
+	uut := New(alice, "sh", "", nil, "-c", "while true; do echo tick; sleep 0.05; done")
+	require.NoError(uut.Start())
+	require.Eventually(func() bool { return len(uut.Stdout()) > 0 }, 2*time.Second, 10*time.Millisecond)
+
+	// When I detach it
+	require.NoError(uut.Detach())
+
+	// Expect that its output is no longer captured, so that none of it is
+	// split between us and the process draining it
+	before := len(uut.Stdout())
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(before, len(uut.Stdout()))
+
+	// ... and that it carries on running, and can still be stopped
+	state, _ := uut.Status()
+	require.Equal(api.TaskStatusCode_Running, state)
+	require.NoError(uut.Signal(context.Background()))
+	require.NoError(await(uut, 2*time.Second))
+
+	// ... and that detaching a finished task does nothing
+	require.NoError(uut.Detach())
+}
+
+func TestDetach_NoDrain(t *testing.T) {
+	require := require.New(t)
+
+	// Given a system without a cat to drain output with
+	defer func(paths []string) { drainPaths = paths }(drainPaths)
+	drainPaths = []string{"/no/such/cat"}
+
+	uut := New(alice, "sleep", "", nil, "60")
+	require.NoError(uut.Start())
+	defer uut.signalGroup(syscall.SIGKILL)
+
+	// When I detach a task, expect a clear error saying why
+	err := uut.Detach()
+	require.Error(err)
+	require.Contains(err.Error(), "/no/such/cat")
+}
@@ -0,0 +1,176 @@
+package taskmanager
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// selected is a task picked out by a TaskSelector, or the reason that a task
+// named by the selector couldn't be acted on
+type selected struct {
+	id   string
+	task *task.Task
+	err  error
+}
+
+// selectTasks finds the tasks matching a selector that the user may perform
+// the given action on. Tasks named by ID that don't exist, or that the user
+// may not act on, are reported with an error. When selecting all tasks, those
+// the user may not act on are quietly left out, so as not to reveal that they
+// exist. The tasks are ordered by ID.
+func (server *Server) selectTasks(u *user.User, sel *api.TaskSelector, action task.Action) ([]selected, error) {
+	if len(sel.GetTaskIds()) == 0 && !sel.GetAll() {
+		return nil, invalidRequest("No tasks selected: give some task IDs, or select all tasks")
+	}
+
+	var candidates []selected
+	if sel.GetAll() {
+		server.registry.Range(func(id string, t *task.Task) {
+			if server.authPolicy.Allows(u, action, t) {
+				candidates = append(candidates, selected{id: id, task: t})
+			}
+		})
+	} else {
+		seen := make(map[string]bool, len(sel.GetTaskIds()))
+		for _, handle := range sel.GetTaskIds() {
+			id := handle.GetId()
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			t, err := server.lookup(u, id, action)
+			candidates = append(candidates, selected{id: id, task: t, err: err})
+		}
+	}
+
+	results := make([]selected, 0, len(candidates))
+	for _, c := range candidates {
+		if c.err != nil || matches(c.task, sel) {
+			results = append(results, c)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].id < results[j].id })
+	return results, nil
+}
+
+// matches tests if a task has the labels and status that a selector asks for
+func matches(t *task.Task, sel *api.TaskSelector) bool {
+	if !t.HasLabels(sel.GetLabels()) {
+		return false
+	}
+
+	if len(sel.GetStatuses()) == 0 {
+		return true
+	}
+
+	status, _ := t.Status()
+	for _, s := range sel.GetStatuses() {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// taskError describes an error for a single task in a batch response, in the
+// same terms as it would be reported for a single-task request
+func taskError(err error) *api.TaskError {
+	if err == nil {
+		return nil
+	}
+
+	st := status.Convert(err)
+	result := &api.TaskError{
+		Code:    int32(st.Code()),
+		Message: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			result.Reason = info.Reason
+			result.Metadata = info.Metadata
+		}
+	}
+	return result
+}
+
+// QueryTasks fetches information about every task matching a selector
+//
+// Expects that a User instance has been injected into the context,
+// representing the client's identity. Requests without one are rejected as
+// unauthenticated.
+func (server *Server) QueryTasks(
+	ctx context.Context, req *api.QueryTasksRequest) (*api.QueryTasksResponse, error) {
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := server.selectTasks(user, req.GetSelector(), task.ActionQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &api.QueryTasksResponse{
+		Tasks: make([]*api.TaskStatus, 0, len(tasks)),
+	}
+	for _, s := range tasks {
+		result := &api.TaskStatus{
+			TaskId: &api.TaskHandle{Id: s.id},
+			Error:  taskError(s.err),
+		}
+		if s.err == nil {
+			result.Status = queryResponse(s.task)
+		}
+		response.Tasks = append(response.Tasks, result)
+	}
+
+	return response, nil
+}
+
+// SignalTasks requests that every task matching a selector should be
+// stopped, in the same way as SignalTask
+//
+// Expects that a User instance has been injected into the context,
+// representing the client's identity. Requests without one are rejected as
+// unauthenticated.
+func (server *Server) SignalTasks(
+	ctx context.Context, req *api.SignalTasksRequest) (*api.SignalTasksResponse, error) {
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := server.selectTasks(user, req.GetSelector(), task.ActionSignal)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &api.SignalTasksResponse{
+		Tasks: make([]*api.SignalResult, 0, len(tasks)),
+	}
+	for _, s := range tasks {
+		err := s.err
+		if err == nil {
+			err = signalTask(ctx, s.id, s.task)
+			if errors.Is(err, task.ErrInvalidState) {
+				err = &InvalidState{id: s.id, action: task.ActionSignal}
+			}
+		}
+
+		response.Tasks = append(response.Tasks, &api.SignalResult{
+			TaskId: &api.TaskHandle{Id: s.id},
+			Error:  taskError(err),
+		})
+	}
+
+	return response, nil
+}
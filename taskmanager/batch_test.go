@@ -0,0 +1,245 @@
+package taskmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startLabelledTask starts a task that runs until it's signalled, and
+// arranges for it to be killed at the end of the test
+func startLabelledTask(t *testing.T, uut *Server, ctx context.Context, labels map[string]string) string {
+	req := startTask("sleep", "60")
+	req.Labels = labels
+	response, err := uut.StartTask(ctx, req)
+	require.NoError(t, err)
+
+	id := response.TaskId.Id
+	running := uut.registry.Lookup(id)
+	t.Cleanup(func() { killTask(running) })
+	return id
+}
+
+func handles(ids ...string) []*api.TaskHandle {
+	result := make([]*api.TaskHandle, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, &api.TaskHandle{Id: id})
+	}
+	return result
+}
+
+func Test_QueryTasks_ByID(t *testing.T) {
+	require := require.New(t)
+	ctxAlice := user.NewContext(context.Background(), alice)
+	ctxBob := user.NewContext(context.Background(), bob)
+
+	// Given a task manager with tasks started by Alice and Bob
+	uut := New()
+	aliceTask := startLabelledTask(t, uut, ctxAlice, map[string]string{"job": "build"})
+	bobTask := startLabelledTask(t, uut, ctxBob, nil)
+
+	// When Alice queries both tasks, a task that doesn't exist, and her own
+	// task again
+	response, err := uut.QueryTasks(ctxAlice, &api.QueryTasksRequest{
+		Selector: &api.TaskSelector{TaskIds: handles(aliceTask, bobTask, "none-such", aliceTask)},
+	})
+	require.NoError(err)
+
+	// Expect a single result for each task
+	require.Len(response.Tasks, 3)
+	results := make(map[string]*api.TaskStatus)
+	for _, r := range response.Tasks {
+		results[r.TaskId.Id] = r
+	}
+
+	// ... with the status of Alice's task
+	require.Nil(results[aliceTask].Error)
+	require.Equal(api.TaskStatusCode_Running, results[aliceTask].Status.StatusCode)
+	require.Equal(map[string]string{"job": "build"}, results[aliceTask].Status.Labels)
+
+	// ... and the same errors as querying the others one at a time would give
+	require.Nil(results[bobTask].Status)
+	require.Equal(int32(codes.PermissionDenied), results[bobTask].Error.Code)
+	require.Equal(ReasonAccessDenied, results[bobTask].Error.Reason)
+
+	require.Nil(results["none-such"].Status)
+	require.Equal(int32(codes.NotFound), results["none-such"].Error.Code)
+	require.Equal(ReasonNoSuchTask, results["none-such"].Error.Reason)
+	require.Equal("none-such", results["none-such"].Error.Metadata["task_id"])
+}
+
+func Test_QueryTasks_BySelector(t *testing.T) {
+	require := require.New(t)
+	ctxAlice := user.NewContext(context.Background(), alice)
+	ctxBob := user.NewContext(context.Background(), bob)
+
+	// Given a task manager with a mix of labelled tasks, some of which have
+	// finished, and some of which belong to Bob
+	uut := New()
+	build := startLabelledTask(t, uut, ctxAlice, map[string]string{"job": "build", "env": "prod"})
+	startLabelledTask(t, uut, ctxAlice, map[string]string{"job": "test"})
+	startLabelledTask(t, uut, ctxBob, map[string]string{"job": "build"})
+
+	finishedReq := startTask("true")
+	finishedReq.Labels = map[string]string{"job": "build"}
+	response, err := uut.StartTask(ctxAlice, finishedReq)
+	require.NoError(err)
+	finished := response.TaskId.Id
+	require.NoError(await(uut.registry.Lookup(finished), 1*time.Second))
+
+	testCases := []struct {
+		name     string
+		selector *api.TaskSelector
+		expected []string
+	}{
+		{
+			name:     "by label",
+			selector: &api.TaskSelector{All: true, Labels: map[string]string{"job": "build"}},
+			expected: []string{build, finished},
+		},
+		{
+			name: "by label and status",
+			selector: &api.TaskSelector{
+				All:      true,
+				Labels:   map[string]string{"job": "build"},
+				Statuses: []api.TaskStatusCode{api.TaskStatusCode_Running},
+			},
+			expected: []string{build},
+		},
+		{
+			name:     "by several labels",
+			selector: &api.TaskSelector{All: true, Labels: map[string]string{"job": "build", "env": "prod"}},
+			expected: []string{build},
+		},
+		{
+			name: "by ID and status",
+			selector: &api.TaskSelector{
+				TaskIds:  handles(build, finished),
+				Statuses: []api.TaskStatusCode{api.TaskStatusCode_Finished},
+			},
+			expected: []string{finished},
+		},
+		{
+			name:     "nothing matching",
+			selector: &api.TaskSelector{All: true, Labels: map[string]string{"job": "deploy"}},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// When Alice queries the tasks matching the selector
+			response, err := uut.QueryTasks(ctxAlice, &api.QueryTasksRequest{Selector: tc.selector})
+			require.NoError(err)
+
+			// Expect only her own tasks that match, and no errors for the
+			// tasks she may not see
+			ids := []string{}
+			for _, r := range response.Tasks {
+				require.Nil(r.Error)
+				ids = append(ids, r.TaskId.Id)
+			}
+			require.ElementsMatch(tc.expected, ids)
+		})
+	}
+}
+
+func Test_QueryTasks_NothingSelected(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+	uut := New()
+
+	// When I query without naming any tasks, or asking for all of them
+	_, err := uut.QueryTasks(ctx, &api.QueryTasksRequest{
+		Selector: &api.TaskSelector{Labels: map[string]string{"job": "build"}},
+	})
+
+	// Expect the request to be rejected, rather than read as "everything"
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func Test_SignalTasks(t *testing.T) {
+	require := require.New(t)
+	ctxAlice := user.NewContext(context.Background(), alice)
+	ctxBob := user.NewContext(context.Background(), bob)
+
+	// Given a task manager with tasks started by Alice and Bob
+	uut := New()
+	first := startLabelledTask(t, uut, ctxAlice, nil)
+	second := startLabelledTask(t, uut, ctxAlice, nil)
+	bobTask := startLabelledTask(t, uut, ctxBob, nil)
+
+	// When Alice signals all of her running tasks
+	response, err := uut.SignalTasks(ctxAlice, &api.SignalTasksRequest{
+		Selector: &api.TaskSelector{
+			All:      true,
+			Statuses: []api.TaskStatusCode{api.TaskStatusCode_Running},
+		},
+	})
+
+	// Expect both of her tasks to have been signalled
+	require.NoError(err)
+	ids := []string{}
+	for _, r := range response.Tasks {
+		require.Nil(r.Error)
+		ids = append(ids, r.TaskId.Id)
+	}
+	require.ElementsMatch([]string{first, second}, ids)
+	require.NoError(await(uut.registry.Lookup(first), 1*time.Second))
+	require.NoError(await(uut.registry.Lookup(second), 1*time.Second))
+
+	// ... and Bob's task to have been left alone
+	state, _ := uut.registry.Lookup(bobTask).Status()
+	require.Equal(api.TaskStatusCode_Running, state)
+}
+
+func Test_SignalTasks_PartialFailure(t *testing.T) {
+	require := require.New(t)
+	ctxAlice := user.NewContext(context.Background(), alice)
+	ctxBob := user.NewContext(context.Background(), bob)
+
+	// Given a task started by Alice and one started by Bob
+	uut := New()
+	aliceTask := startLabelledTask(t, uut, ctxAlice, nil)
+	bobTask := startLabelledTask(t, uut, ctxBob, nil)
+
+	// When Alice signals both of them by ID
+	response, err := uut.SignalTasks(ctxAlice, &api.SignalTasksRequest{
+		Selector: &api.TaskSelector{TaskIds: handles(aliceTask, bobTask)},
+	})
+	require.NoError(err)
+	require.Len(response.Tasks, 2)
+
+	// Expect her task to be signalled, and Bob's to be refused
+	for _, r := range response.Tasks {
+		switch r.TaskId.Id {
+		case aliceTask:
+			require.Nil(r.Error)
+		case bobTask:
+			require.Equal(ReasonAccessDenied, r.Error.Reason)
+		}
+	}
+	require.NoError(await(uut.registry.Lookup(aliceTask), 1*time.Second))
+
+	state, _ := uut.registry.Lookup(bobTask).Status()
+	require.Equal(api.TaskStatusCode_Running, state)
+}
+
+func Test_TaskError(t *testing.T) {
+	require := require.New(t)
+
+	// Expect that an error carries its reason and metadata across
+	err := taskError(&InvalidState{id: "1234", action: task.ActionSignal})
+	require.Equal(int32(codes.FailedPrecondition), err.Code)
+	require.Equal(ReasonInvalidState, err.Reason)
+	require.Equal("1234", err.Metadata["task_id"])
+
+	require.Nil(taskError(nil))
+}
@@ -0,0 +1,201 @@
+package taskmanager
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/tcsc/levity/environment"
+	"github.com/tcsc/levity/policy"
+	"github.com/tcsc/levity/task"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain identifies levity as the source of the `ErrorInfo` details
+// attached to the errors returned by the Server
+const ErrorDomain = "levity"
+
+// The reasons reported in the `ErrorInfo` details attached to the errors
+// returned by the Server, so that clients can tell them apart without having
+// to parse the error message
+const (
+	ReasonNoSuchTask        = "NO_SUCH_TASK"
+	ReasonAccessDenied      = "ACCESS_DENIED"
+	ReasonInvalidState      = "INVALID_STATE"
+	ReasonCommandDenied     = "COMMAND_DENIED"
+	ReasonEnvironmentDenied = "ENVIRONMENT_DENIED"
+	ReasonStartFailed       = "START_FAILED"
+	ReasonInvalidRequest    = "INVALID_REQUEST"
+	ReasonShuttingDown      = "SHUTTING_DOWN"
+	ReasonTooManyTasks      = "TOO_MANY_TASKS"
+)
+
+// newStatus creates a GRPC status with an `ErrorInfo` detail describing the
+// error
+func newStatus(code codes.Code, message string, reason string, metadata map[string]string) *status.Status {
+	st := status.New(code, message)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: metadata,
+	})
+	if err != nil {
+		// Can only happen if the details can't be marshalled, in which case
+		// the bare status is better than nothing
+		return st
+	}
+	return detailed
+}
+
+// NoSuchTask is an error type indicating that the requested task does not
+// exist
+type NoSuchTask struct {
+	id string
+}
+
+func (e *NoSuchTask) Error() string {
+	return fmt.Sprintf("No such task: %s", e.id)
+}
+
+// GRPCStatus reports a NoSuchTask error to GRPC clients as NotFound
+func (e *NoSuchTask) GRPCStatus() *status.Status {
+	return newStatus(codes.NotFound, e.Error(), ReasonNoSuchTask,
+		map[string]string{"task_id": e.id})
+}
+
+// AccessDenied is an error type indicating that the caller does not have
+// sufficient privileges on the specified task to perform the requested
+// operation
+type AccessDenied struct {
+	id     string
+	action task.Action
+}
+
+func (e *AccessDenied) Error() string {
+	if e.id == "" {
+		return fmt.Sprintf("Access denied: %s not permitted", e.action)
+	}
+	return fmt.Sprintf("Access denied: %s not permitted on task %s", e.action, e.id)
+}
+
+// GRPCStatus reports an AccessDenied error to GRPC clients as
+// PermissionDenied
+func (e *AccessDenied) GRPCStatus() *status.Status {
+	metadata := map[string]string{"action": string(e.action)}
+	if e.id != "" {
+		metadata["task_id"] = e.id
+	}
+	return newStatus(codes.PermissionDenied, e.Error(), ReasonAccessDenied, metadata)
+}
+
+// InvalidState is an error type indicating that the requested operation
+// can't be performed on a task in its current state, e.g. signalling a task
+// that hasn't started yet
+type InvalidState struct {
+	id     string
+	action task.Action
+}
+
+func (e *InvalidState) Error() string {
+	return fmt.Sprintf("Task %s is in the wrong state for %s", e.id, e.action)
+}
+
+// GRPCStatus reports an InvalidState error to GRPC clients as
+// FailedPrecondition
+func (e *InvalidState) GRPCStatus() *status.Status {
+	return newStatus(codes.FailedPrecondition, e.Error(), ReasonInvalidState,
+		map[string]string{"task_id": e.id, "action": string(e.action)})
+}
+
+// ShuttingDown is an error type indicating that the server is shutting down,
+// and so will not start any new tasks
+type ShuttingDown struct{}
+
+func (e *ShuttingDown) Error() string {
+	return "Server is shutting down"
+}
+
+// GRPCStatus reports a ShuttingDown error to GRPC clients as Unavailable, so
+// that they know to try again elsewhere (or later)
+func (e *ShuttingDown) GRPCStatus() *status.Status {
+	return newStatus(codes.Unavailable, e.Error(), ReasonShuttingDown, nil)
+}
+
+// TooManyTasks is an error type indicating that starting another task would
+// exceed one of the server's limits on running tasks, either in total or for
+// the requesting user
+type TooManyTasks struct {
+	limit   int
+	perUser bool
+}
+
+func (e *TooManyTasks) Error() string {
+	if e.perUser {
+		return fmt.Sprintf("Too many tasks: at most %d may run per user", e.limit)
+	}
+	return fmt.Sprintf("Too many tasks: at most %d may run at once", e.limit)
+}
+
+// GRPCStatus reports a TooManyTasks error to GRPC clients as
+// ResourceExhausted
+func (e *TooManyTasks) GRPCStatus() *status.Status {
+	scope := "server"
+	if e.perUser {
+		scope = "user"
+	}
+	return newStatus(codes.ResourceExhausted, e.Error(), ReasonTooManyTasks,
+		map[string]string{"limit": strconv.Itoa(e.limit), "scope": scope})
+}
+
+// requestError wraps an error from one of the lower layers with the GRPC
+// status that should be reported to the client. The original error is still
+// available via `errors.As` and friends.
+type requestError struct {
+	code     codes.Code
+	reason   string
+	metadata map[string]string
+	err      error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func (e *requestError) GRPCStatus() *status.Status {
+	return newStatus(e.code, e.Error(), e.reason, e.metadata)
+}
+
+// invalidRequest reports a malformed request as InvalidArgument
+func invalidRequest(format string, args ...interface{}) error {
+	return &requestError{
+		code:   codes.InvalidArgument,
+		reason: ReasonInvalidRequest,
+		err:    fmt.Errorf(format, args...),
+	}
+}
+
+// startFailed classifies an error encountered while trying to start a task
+// for the binary
+func startFailed(binary string, err error) error {
+	metadata := map[string]string{"binary": binary}
+
+	var commandDenied *policy.CommandDenied
+	if errors.As(err, &commandDenied) {
+		return &requestError{codes.PermissionDenied, ReasonCommandDenied, metadata, err}
+	}
+
+	var deniedVariable *environment.DeniedVariable
+	if errors.As(err, &deniedVariable) {
+		return &requestError{codes.PermissionDenied, ReasonEnvironmentDenied, metadata, err}
+	}
+
+	// Anything else means that the command couldn't be launched as
+	// specified, e.g. the binary doesn't exist, or isn't executable
+	return &requestError{codes.InvalidArgument, ReasonStartFailed, metadata, err}
+}
@@ -0,0 +1,103 @@
+package taskmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/task"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorInfo extracts the ErrorInfo detail from a GRPC error
+func errorInfo(t *testing.T, err error) *errdetails.ErrorInfo {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	require.FailNow(t, "No ErrorInfo in status")
+	return nil
+}
+
+func TestErrorStatus(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		code     codes.Code
+		reason   string
+		metadata map[string]string
+	}{
+		{
+			name:     "no such task",
+			err:      &NoSuchTask{id: "some-task"},
+			code:     codes.NotFound,
+			reason:   ReasonNoSuchTask,
+			metadata: map[string]string{"task_id": "some-task"},
+		},
+		{
+			name:     "access denied",
+			err:      &AccessDenied{id: "some-task", action: task.ActionLogs},
+			code:     codes.PermissionDenied,
+			reason:   ReasonAccessDenied,
+			metadata: map[string]string{"task_id": "some-task", "action": "logs"},
+		},
+		{
+			name:     "start denied",
+			err:      &AccessDenied{action: task.ActionStart},
+			code:     codes.PermissionDenied,
+			reason:   ReasonAccessDenied,
+			metadata: map[string]string{"action": "start"},
+		},
+		{
+			name:     "invalid state",
+			err:      &InvalidState{id: "some-task", action: task.ActionSignal},
+			code:     codes.FailedPrecondition,
+			reason:   ReasonInvalidState,
+			metadata: map[string]string{"task_id": "some-task", "action": "signal"},
+		},
+		{
+			name:   "invalid request",
+			err:    invalidRequest("No actions to share"),
+			code:   codes.InvalidArgument,
+			reason: ReasonInvalidRequest,
+		},
+		{
+			name:   "shutting down",
+			err:    &ShuttingDown{},
+			code:   codes.Unavailable,
+			reason: ReasonShuttingDown,
+		},
+		{
+			name:     "too many tasks",
+			err:      &TooManyTasks{limit: 10},
+			code:     codes.ResourceExhausted,
+			reason:   ReasonTooManyTasks,
+			metadata: map[string]string{"limit": "10", "scope": "server"},
+		},
+		{
+			name:     "too many tasks for user",
+			err:      &TooManyTasks{limit: 2, perUser: true},
+			code:     codes.ResourceExhausted,
+			reason:   ReasonTooManyTasks,
+			metadata: map[string]string{"limit": "2", "scope": "user"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			require.Equal(tc.code, status.Code(tc.err))
+			require.Equal(tc.err.Error(), status.Convert(tc.err).Message())
+
+			info := errorInfo(t, tc.err)
+			require.Equal(ErrorDomain, info.Domain)
+			require.Equal(tc.reason, info.Reason)
+			require.Equal(len(tc.metadata), len(info.Metadata))
+			for k, v := range tc.metadata {
+				require.Equal(v, info.Metadata[k])
+			}
+		})
+	}
+}
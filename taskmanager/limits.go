@@ -0,0 +1,96 @@
+package taskmanager
+
+import (
+	"time"
+
+	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/user"
+)
+
+// Limits bounds the resources that the server's tasks may consume. A zero
+// value for any limit means that there is no limit.
+type Limits struct {
+	// MaxTasks is the maximum number of tasks that may run at once
+	MaxTasks int
+
+	// MaxTasksPerUser is the maximum number of tasks that any one user may
+	// have running at once
+	MaxTasksPerUser int
+
+	// MaxOutputBytes is the maximum amount of stdout and stderr (each) held
+	// for each task. Any output beyond this is discarded.
+	MaxOutputBytes int
+}
+
+// WithLimits bounds the resources that tasks may consume. By default there
+// are no limits.
+func WithLimits(limits Limits) Option {
+	return func(server *Server) {
+		server.limits = limits
+	}
+}
+
+// WithRetention sets how long a task (and its output) is kept once it has
+// finished, after which it is forgotten. By default tasks are kept for as
+// long as the server runs.
+func WithRetention(retention time.Duration) Option {
+	return func(server *Server) {
+		server.retention = retention
+	}
+}
+
+// taskCounts tracks the number of tasks running on the server, in total and
+// for each user. Guarded by the Server's `countLock`.
+type taskCounts struct {
+	total  int
+	byUser map[string]int
+}
+
+// reserve claims a place for a new task owned by the user, failing if that
+// would take the server over any of its limits. The place must be given back
+// with `release` when the task finishes (or fails to start).
+func (server *Server) reserve(u *user.User) error {
+	server.countLock.Lock()
+	defer server.countLock.Unlock()
+
+	if max := server.limits.MaxTasks; max > 0 && server.counts.total >= max {
+		return &TooManyTasks{limit: max}
+	}
+
+	login := u.Login()
+	if max := server.limits.MaxTasksPerUser; max > 0 && server.counts.byUser[login] >= max {
+		return &TooManyTasks{limit: max, perUser: true}
+	}
+
+	server.counts.total++
+	server.counts.byUser[login]++
+	return nil
+}
+
+// release gives back the place reserved for a task owned by the user
+func (server *Server) release(u *user.User) {
+	server.countLock.Lock()
+	defer server.countLock.Unlock()
+
+	login := u.Login()
+	server.counts.total--
+	server.counts.byUser[login]--
+	if server.counts.byUser[login] == 0 {
+		delete(server.counts.byUser, login)
+	}
+}
+
+// expire waits for a task to finish, gives back its place, and (if a
+// retention period has been set) removes it from the registry once that
+// period has passed
+func (server *Server) expire(id string, t *task.Task) {
+	<-t.Done()
+	server.release(t.Owner())
+
+	if server.retention <= 0 {
+		return
+	}
+	time.AfterFunc(server.retention, func() {
+		server.registry.Remove(id)
+	})
+}
@@ -0,0 +1,120 @@
+package taskmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_Limits_MaxTasks(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server that will only run one task at a time, which is already
+	// running a task
+	uut := New(WithLimits(Limits{MaxTasks: 1}))
+	running := startSleeper(t, uut, "30")
+
+	// When anybody tries to start another
+	bobCtx := user.NewContext(context.Background(), bob)
+	_, err := uut.StartTask(bobCtx, startTask("true"))
+
+	// Expect that it is refused
+	require.Equal(codes.ResourceExhausted, status.Code(err))
+	require.IsType(&TooManyTasks{}, err)
+	require.Equal(1, uut.registry.Len())
+
+	// ... until the running task has finished
+	killTask(running)
+	require.NoError(await(running, 1*time.Second))
+	require.Eventually(func() bool {
+		_, err := uut.StartTask(bobCtx, startTask("true"))
+		return err == nil
+	}, 1*time.Second, 10*time.Millisecond)
+}
+
+func Test_Limits_MaxTasksPerUser(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server that will only run one task per user, where alice
+	// already has a task running
+	uut := New(WithLimits(Limits{MaxTasksPerUser: 1}))
+	running := startSleeper(t, uut, "30")
+	defer killTask(running)
+
+	// When alice tries to start another
+	aliceCtx := user.NewContext(context.Background(), alice)
+	_, err := uut.StartTask(aliceCtx, startTask("true"))
+
+	// Expect that it is refused
+	require.Equal(codes.ResourceExhausted, status.Code(err))
+
+	// ... but that bob may still start one
+	bobCtx := user.NewContext(context.Background(), bob)
+	_, err = uut.StartTask(bobCtx, startTask("true"))
+	require.NoError(err)
+}
+
+func Test_Limits_FailedStartIsNotCounted(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server that will only run one task at a time
+	uut := New(WithLimits(Limits{MaxTasks: 1}))
+	ctx := user.NewContext(context.Background(), alice)
+
+	// When a task fails to start
+	_, err := uut.StartTask(ctx, startTask("no-such-binary"))
+	require.Error(err)
+
+	// Expect that another task may still be started
+	running := startSleeper(t, uut, "30")
+	killTask(running)
+}
+
+func Test_Limits_MaxOutputBytes(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server that only keeps a little of each task's output
+	uut := New(WithLimits(Limits{MaxOutputBytes: 10}))
+	ctx := user.NewContext(context.Background(), alice)
+
+	// When a task writes more than that
+	response, err := uut.StartTask(ctx, startTask("sh", "-c",
+		"echo this is a long line on stdout; 1>&2 echo this is stderr"))
+	require.NoError(err)
+	require.NoError(await(uut.registry.Lookup(response.TaskId.Id), 1*time.Second))
+
+	// Expect that only the start of the output was kept
+	logs, err := uut.FetchLogs(ctx, &api.FetchLogsRequest{TaskId: response.TaskId})
+	require.NoError(err)
+	require.Equal("this is a ", string(logs.Stdout))
+	require.Equal("this is st", string(logs.Stderr))
+}
+
+func Test_Retention(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server that forgets about tasks shortly after they finish
+	uut := New(WithRetention(50 * time.Millisecond))
+	ctx := user.NewContext(context.Background(), alice)
+
+	// When a task finishes
+	response, err := uut.StartTask(ctx, startTask("true"))
+	require.NoError(err)
+	require.NoError(await(uut.registry.Lookup(response.TaskId.Id), 1*time.Second))
+
+	// Expect that it is still available immediately afterwards, but is
+	// eventually forgotten
+	_, err = uut.QueryTask(ctx, &api.QueryTaskRequest{TaskId: response.TaskId})
+	require.NoError(err)
+
+	require.Eventually(func() bool {
+		_, err := uut.QueryTask(ctx, &api.QueryTaskRequest{TaskId: response.TaskId})
+		return status.Code(err) == codes.NotFound
+	}, 1*time.Second, 10*time.Millisecond)
+}
@@ -2,85 +2,275 @@ package taskmanager
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/environment"
 	"github.com/tcsc/levity/registry"
 	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/tracing"
 	"github.com/tcsc/levity/user"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
-// NoSuchTask is an error type indicating that the requested task does not
-// exist
-type NoSuchTask struct {
-	id string
+// AuthorisationPolicy abstracts out the authorisation policy and permissions
+// model, deciding whether a user may start tasks, or perform a given action
+// on an existing task.
+type AuthorisationPolicy interface {
+	AllowsStart(*user.User) bool
+	Allows(*user.User, task.Action, *task.Task) bool
 }
 
-func (e *NoSuchTask) Error() string {
-	return fmt.Sprintf("No such task: %s", e.id)
-}
+// Implements the default authorisation policy: anyone may start a task, but
+// only the creator/owner of a task, and any users (or groups) the owner has
+// explicitly shared it with, may interact with it
+type defaultAuthPolicy struct{}
 
-// AccessDenied is an error type indicating that the caller does not have
-// sufficient privileges on the specified task to perform the requested
-// operation
-type AccessDenied struct {
-	id string
+func (p defaultAuthPolicy) AllowsStart(*user.User) bool {
+	return true
 }
 
-func (e *AccessDenied) Error() string {
-	return fmt.Sprintf("Access denied on task %s", e.id)
+func (p defaultAuthPolicy) Allows(user *user.User, action task.Action, t *task.Task) bool {
+	return user.Is(t.Owner()) || t.IsSharedWith(user.Login(), user.Groups(), action)
 }
 
-// Abstracts out the authorisation policy and permissions model.
-type authorisationPolicy interface {
-	Allows(*user.User, *task.Task) bool
+// CommandPolicy decides which commands a user may launch. On success, Check
+// returns the binary that should actually be executed and the directory to
+// run it in, which may differ from those requested (e.g. resolved to
+// absolute paths).
+type CommandPolicy interface {
+	Check(u *user.User, binary, workingDir string, args []string) (string, string, error)
 }
 
-// Implements the default authorisation policy: only the creator/owner of a
-// task may interact with it
-type defaultAuthPolicy struct{}
+// Implements the default command policy: any user may run anything
+type unrestrictedCommandPolicy struct{}
 
-func (p defaultAuthPolicy) Allows(user *user.User, task *task.Task) bool {
-	return user.Is(task.Owner())
+func (p unrestrictedCommandPolicy) Check(
+	_ *user.User, binary, workingDir string, _ []string) (string, string, error) {
+	return binary, workingDir, nil
 }
 
 // Server is an implementation of the TaskManager API.
 type Server struct {
 	api.UnimplementedTaskManagerServer
-	registry   *registry.Registry
-	authPolicy authorisationPolicy
+	registry      *registry.Registry
+	authPolicy    AuthorisationPolicy
+	commandPolicy CommandPolicy
+	envPolicy     *environment.Policy
+	observers     []TaskObserver
+	limits        Limits
+	retention     time.Duration
+
+	countLock sync.Mutex
+	counts    taskCounts
+
+	// lifecycle guards `draining`. Starting a task holds a read lock
+	// throughout, so that once the server starts draining no more tasks can
+	// sneak into the registry.
+	lifecycle sync.RWMutex
+	draining  bool
+}
+
+// Option configures an optional aspect of a Server
+type Option func(*Server)
+
+// WithEnvironment sets the policy used to construct the environment of each
+// task started by the server. By default tasks receive only the variables
+// supplied by the client, less any in environment.DefaultDenied.
+func WithEnvironment(policy *environment.Policy) Option {
+	return func(server *Server) {
+		server.envPolicy = policy
+	}
+}
+
+// WithAuthorisationPolicy replaces the default authorisation policy, which
+// only allows the owner of a task to interact with it.
+func WithAuthorisationPolicy(policy AuthorisationPolicy) Option {
+	return func(server *Server) {
+		server.authPolicy = policy
+	}
+}
+
+// WithCommandPolicy restricts the commands that users may launch. By default
+// any user may run any command.
+func WithCommandPolicy(policy CommandPolicy) Option {
+	return func(server *Server) {
+		server.commandPolicy = policy
+	}
+}
+
+// WithRegistry supplies the registry that the server records its tasks in,
+// e.g. so that it can be inspected by other parts of the daemon. By default
+// the server creates its own.
+func WithRegistry(r *registry.Registry) Option {
+	return func(server *Server) {
+		server.registry = r
+	}
+}
+
+// TaskObserver is notified of significant events in the lifecycle of tasks
+// started by the server, e.g. for collecting metrics. Notifications may
+// arrive from any goroutine.
+type TaskObserver interface {
+	// TaskStarted is called after a task has started successfully
+	TaskStarted(t *task.Task)
+
+	// TaskFinished is called once a task has finished (for whatever
+	// reason), with the time it ran for
+	TaskFinished(t *task.Task, runtime time.Duration)
+}
+
+// WithObserver registers an observer to be notified about the tasks started
+// by the server
+func WithObserver(observer TaskObserver) Option {
+	return func(server *Server) {
+		server.observers = append(server.observers, observer)
+	}
+}
+
+// New creates and initialises a new Server with default settings, modified
+// by any supplied options
+func New(options ...Option) *Server {
+	server := &Server{
+		registry:      registry.New(),
+		authPolicy:    defaultAuthPolicy{},
+		commandPolicy: unrestrictedCommandPolicy{},
+		envPolicy:     environment.New(nil, environment.DefaultDenied...),
+		counts:        taskCounts{byUser: make(map[string]int)},
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	return server
+}
+
+// Check reports whether the server is able to handle requests, i.e. that
+// its task registry is responsive. Suitable for use as a health check.
+func (server *Server) Check(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		// NB: If the registry really is deadlocked, this goroutine will be
+		//     stuck forever. That's the least of our problems at that point.
+		server.registry.Len()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+
+	case <-ctx.Done():
+		return errors.New("task registry is unresponsive")
+	}
 }
 
-// New creates and initialises a new Server with default settings
-func New() *Server {
-	return &Server{registry: registry.New(), authPolicy: defaultAuthPolicy{}}
+// authenticatedUser fetches the user that the request was authenticated as.
+// The daemon's interceptors are responsible for authenticating requests, so
+// an unauthenticated request getting this far indicates a bug somewhere.
+// Better to reject it than crash the whole daemon, though.
+func authenticatedUser(ctx context.Context) (*user.User, error) {
+	u, ok := user.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Request is not authenticated")
+	}
+	return u, nil
 }
 
 // StartTask attempts to start and register a task with the task manager.
 //
 // Expects that a User instance has been injected into the context,
-// representing the client's identity. Failure to include this will panic
-// the goroutine.
+// representing the client's identity. Requests without one are rejected as
+// unauthenticated.
 func (server *Server) StartTask(ctx context.Context, req *api.StartTaskRequest) (*api.StartTaskResponse, error) {
-	user := user.MustFromContext(ctx)
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the user may start tasks at all before looking at what they
+	// want to run, so that the replies to anyone who may not don't reveal
+	// anything about the command or environment policies
+	if !server.authPolicy.AllowsStart(user) {
+		return nil, &AccessDenied{action: task.ActionStart}
+	}
+
+	server.lifecycle.RLock()
+	defer server.lifecycle.RUnlock()
+	if server.draining {
+		return nil, &ShuttingDown{}
+	}
+
+	for name := range req.GetLabels() {
+		if name == "" {
+			return nil, invalidRequest("Task labels must have a name")
+		}
+	}
+
+	// Make sure the user is allowed to run the command before going any
+	// further, and from here on use the binary and working directory that the
+	// policy actually checked
+	binary, workingDir, err := server.commandPolicy.Check(
+		user, req.GetBinary(), req.GetWorkingDir(), req.GetArgs())
+	if err != nil {
+		return nil, startFailed(req.GetBinary(), err)
+	}
+
+	// Layer the client's environment on top of the server-defined base
+	env, err := server.envPolicy.Apply(req.GetEnvironment())
+	if err != nil {
+		return nil, startFailed(binary, err)
+	}
+
+	// The task's own span covers its entire lifetime, and is handed on to
+	// the process (as TRACEPARENT) so that anything it traces is correlated
+	// with the request that started it
+	runCtx, runSpan := tracing.Tracer().Start(ctx, "task.run",
+		trace.WithAttributes(label.String("levity.binary", binary)))
+	for k, v := range tracing.Environment(runCtx) {
+		env[k] = v
+	}
 
 	t := task.New(
 		user,
-		req.GetBinary(),
-		req.GetWorkingDir(),
-		req.GetEnvironment(),
+		binary,
+		workingDir,
+		env,
 		req.GetArgs()...)
+	t.SetLabels(req.GetLabels())
+
+	if err := server.reserve(user); err != nil {
+		endWithError(runSpan, err)
+		return nil, err
+	}
+	t.LimitOutput(server.limits.MaxOutputBytes)
 
 	// Start the task
-	err := t.Start()
+	_, startSpan := tracing.Tracer().Start(runCtx, "task.start")
+	startTime := time.Now()
+	err = t.Start()
 	if err != nil {
-		return nil, err
+		server.release(user)
+		endWithError(startSpan, err)
+		endWithError(runSpan, err)
+		return nil, startFailed(binary, err)
 	}
+	startSpan.End()
 
 	// record it in the registry
 	id := server.registry.Register(t)
+	runSpan.SetAttributes(label.String("levity.task_id", id))
+	go traceExit(t, runSpan)
+	go server.expire(id, t)
+	server.notifyStarted(t, startTime)
 
 	// Give the caller a handle to their task
 	return &api.StartTaskResponse{
@@ -88,103 +278,277 @@ func (server *Server) StartTask(ctx context.Context, req *api.StartTaskRequest)
 	}, nil
 }
 
+// traceExit records the task's exit on its span, and ends the span
+func traceExit(t *task.Task, span trace.Span) {
+	<-t.Done()
+	statusCode, exitCode := t.Status()
+	span.AddEvent("process exit", trace.WithAttributes(
+		label.Stringer("levity.status", statusCode),
+		label.Int("levity.exit_code", exitCode)))
+	span.End()
+}
+
+// endWithError marks a span as failed, and ends it
+func endWithError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(otelcodes.Error, err.Error())
+	span.End()
+}
+
+// notifyStarted tells any observers that the task has started, and arranges
+// for them to be told when it finishes
+func (server *Server) notifyStarted(t *task.Task, startTime time.Time) {
+	if len(server.observers) == 0 {
+		return
+	}
+
+	for _, observer := range server.observers {
+		observer.TaskStarted(t)
+	}
+
+	go func() {
+		<-t.Done()
+		runtime := time.Since(startTime)
+		for _, observer := range server.observers {
+			observer.TaskFinished(t, runtime)
+		}
+	}()
+}
+
+// lookup finds a task in the registry and checks that the user is allowed to
+// perform the given action on it
+func (server *Server) lookup(user *user.User, taskID string, action task.Action) (*task.Task, error) {
+	t := server.registry.Lookup(taskID)
+	if t == nil {
+		return nil, &NoSuchTask{id: taskID}
+	}
+
+	if !server.authPolicy.Allows(user, action, t) {
+		return nil, &AccessDenied{id: taskID, action: action}
+	}
+
+	return t, nil
+}
+
 // FetchLogs extracts and returns the collected stdout & stderr data from the
 // task
 //
 // Expects that a User instance has been injected into the context,
-// representing the client's identity. Failure to include this will panic
-// the goroutine.
+// representing the client's identity. Requests without one are rejected as
+// unauthenticated.
 func (server *Server) FetchLogs(
 	ctx context.Context, req *api.FetchLogsRequest) (*api.FetchLogsResponse, error) {
-	user := user.MustFromContext(ctx)
-	taskID := req.TaskId.Id
-
-	task := server.registry.Lookup(taskID)
-	if task == nil {
-		return nil, &NoSuchTask{id: taskID}
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
 	}
+	taskID := req.GetTaskId().GetId()
 
-	if !server.authPolicy.Allows(user, task) {
-		return nil, &AccessDenied{id: taskID}
+	t, err := server.lookup(user, taskID, task.ActionLogs)
+	if err != nil {
+		return nil, err
 	}
 
 	response := &api.FetchLogsResponse{
-		Stdout: task.Stdout(),
-		Stderr: task.Stderr(),
+		Stdout: t.Stdout(),
+		Stderr: t.Stderr(),
 	}
 
 	return response, nil
 }
 
+// FollowLogs streams the task's stdout & stderr data to the client as it is
+// written, starting with whatever has been collected already. The stream
+// ends once the task has exited and everything it wrote has been sent.
+//
+// Expects that a User instance has been injected into the context,
+// representing the client's identity. Requests without one are rejected as
+// unauthenticated.
+func (server *Server) FollowLogs(req *api.FollowLogsRequest, stream api.TaskManager_FollowLogsServer) error {
+	ctx := stream.Context()
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return err
+	}
+	taskID := req.GetTaskId().GetId()
+
+	t, err := server.lookup(user, taskID, task.ActionLogs)
+	if err != nil {
+		return err
+	}
+
+	var sentStdout, sentStderr int
+	finished := false
+	for {
+		stdout, stderr, more := t.OutputSince(sentStdout, sentStderr)
+		if len(stdout) > 0 || len(stderr) > 0 {
+			err := stream.Send(&api.FetchLogsResponse{Stdout: stdout, Stderr: stderr})
+			if err != nil {
+				return err
+			}
+			sentStdout += len(stdout)
+			sentStderr += len(stderr)
+		}
+
+		// NB: The task's output is complete once it's done, so the final
+		//     pass above has sent everything
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-more:
+		case <-t.Done():
+			finished = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 // QueryTask fetches information about a given task
 //
 // Expects that a User instance has been injected into the context,
-// representing the client's identity. Failure to include this will panic
-// the goroutine.
+// representing the client's identity. Requests without one are rejected as
+// unauthenticated.
 func (server *Server) QueryTask(
 	ctx context.Context, req *api.QueryTaskRequest) (*api.QueryTaskResponse, error) {
-	user := user.MustFromContext(ctx)
-	taskID := req.TaskId.Id
-
-	task := server.registry.Lookup(taskID)
-	if task == nil {
-		return nil, &NoSuchTask{id: taskID}
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
 	}
+	taskID := req.GetTaskId().GetId()
 
-	if !server.authPolicy.Allows(user, task) {
-		return nil, &AccessDenied{id: taskID}
+	t, err := server.lookup(user, taskID, task.ActionQuery)
+	if err != nil {
+		return nil, err
 	}
 
+	return queryResponse(t), nil
+}
+
+// queryResponse describes the current state of a task
+func queryResponse(t *task.Task) *api.QueryTaskResponse {
 	var exitCode *int32
-	status, taskExitCode := task.Status()
+	status, taskExitCode := t.Status()
 	if status == api.TaskStatusCode_Finished {
 		exitCode = new(int32)
 		(*exitCode) = int32(taskExitCode)
 	}
 
-	response := &api.QueryTaskResponse{
+	var signal *int32
+	sig := t.TerminatingSignal()
+	if sig != 0 && (status == api.TaskStatusCode_Finished || status == api.TaskStatusCode_BrutallyKilled) {
+		signal = new(int32)
+		(*signal) = int32(sig)
+	}
+
+	return &api.QueryTaskResponse{
 		StatusCode: status,
 		ExitCode:   exitCode,
+		Signal:     signal,
+		Labels:     t.Labels(),
 	}
-
-	return response, nil
 }
 
 // SignalTask requests that a task should be stopped
 //
 // Expects that a User instance has been injected into the context,
-// representing the client's identity. Failure to include this will panic
-// the goroutine.
+// representing the client's identity. Requests without one are rejected as
+// unauthenticated.
 func (server *Server) SignalTask(
 	ctx context.Context, req *api.SignalTaskRequest) (*emptypb.Empty, error) {
-	user := user.MustFromContext(ctx)
-	taskID := req.TaskId.Id
-	task := server.registry.Lookup(taskID)
-	if task == nil {
-		return nil, &NoSuchTask{id: taskID}
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taskID := req.GetTaskId().GetId()
+	t, err := server.lookup(user, taskID, task.ActionSignal)
+	if err != nil {
+		return nil, err
 	}
 
-	if !server.authPolicy.Allows(user, task) {
-		return nil, &AccessDenied{id: taskID}
+	err = signalTask(ctx, taskID, t)
+	if errors.Is(err, task.ErrInvalidState) {
+		return nil, &InvalidState{id: taskID, action: task.ActionSignal}
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
 
-	// Note the hardcoded 5s timeout here; this should at the very least be
+// signalTask asks a task to stop, escalating to killing it outright if it
+// doesn't stop within the grace period
+func signalTask(ctx context.Context, taskID string, t *task.Task) error {
+	// The signal span covers everything from the initial signal to the
+	// process exiting, including any escalation along the way
+	_, span := tracing.Tracer().Start(ctx, "task.signal",
+		trace.WithAttributes(label.String("levity.task_id", taskID)))
+
+	// Note the hardcoded grace period here; this should at the very least be
 	// a be parameter of the Server, preferably configurable somehow by the
 	// user. For the sake of this exercise, it's just a hardcoded value.
-	signalCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	signalCtx, cancel := context.WithTimeout(
+		trace.ContextWithSpan(context.Background(), span), signalGracePeriod)
 
 	// Start a goroutine to monitor the task and free up the context when
 	// the task finishes. We can't use the normal `defer cancel()` because the
 	// task will have to live longer than this function call
 	go func() {
-		<-task.Done()
+		<-t.Done()
 		cancel()
+		span.End()
 	}()
 
-	err := task.Signal(signalCtx)
+	return t.Signal(signalCtx)
+}
+
+// sharedActions maps the actions named in a share request onto their task
+// equivalents
+var sharedActions = map[api.TaskAction]task.Action{
+	api.TaskAction_Query:  task.ActionQuery,
+	api.TaskAction_Logs:   task.ActionLogs,
+	api.TaskAction_Signal: task.ActionSignal,
+}
+
+// ShareTask grants other users and groups permission to act on a task
+//
+// Expects that a User instance has been injected into the context,
+// representing the client's identity. Requests without one are rejected as
+// unauthenticated.
+func (server *Server) ShareTask(
+	ctx context.Context, req *api.ShareTaskRequest) (*emptypb.Empty, error) {
+	user, err := authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taskID := req.GetTaskId().GetId()
+
+	if len(req.GetUsers()) == 0 && len(req.GetGroups()) == 0 {
+		return nil, invalidRequest("No users or groups to share task with")
+	}
+
+	if len(req.GetActions()) == 0 {
+		return nil, invalidRequest("No actions to share")
+	}
+
+	actions := make([]task.Action, 0, len(req.GetActions()))
+	for _, a := range req.GetActions() {
+		action, ok := sharedActions[a]
+		if !ok {
+			return nil, invalidRequest("Action %v may not be shared", a)
+		}
+		actions = append(actions, action)
+	}
+
+	t, err := server.lookup(user, taskID, task.ActionShare)
 	if err != nil {
 		return nil, err
 	}
 
+	t.Share(req.GetUsers(), req.GetGroups(), actions...)
+
 	return &emptypb.Empty{}, nil
 }
@@ -13,19 +13,41 @@ package taskmanager
 import (
 	"context"
 	"errors"
+	"log"
 	"os"
 	"path"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/environment"
 	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/tracing"
 	"github.com/tcsc/levity/user"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// TestMain installs a tracer provider, as the daemon does, before any of the
+// tests run. NB: Replacing the global provider partway through would race
+// with tasks left over from earlier tests that are still creating spans.
+func TestMain(m *testing.M) {
+	stopTracing, err := tracing.Init(context.Background(), tracing.Config{ServiceName: "test"})
+	if err != nil {
+		log.Fatalf("Failed to initialise tracing: %v", err)
+	}
+
+	code := m.Run()
+	_ = stopTracing(context.Background())
+	os.Exit(code)
+}
+
 var (
 	alice = user.New("alice")
 	bob   = user.New("bob")
@@ -92,14 +114,165 @@ func Test_StartTask_CommandFailure(t *testing.T) {
 	request := startTask("/no-such-binary")
 	response, err := uut.StartTask(ctx, request)
 
-	// Expect that the request fails
+	// Expect that the request fails as an invalid request
 	require.Error(err)
+	require.Equal(codes.InvalidArgument, status.Code(err))
 	require.Nil(response)
 
 	// ..and that nothing was added to the task registry
 	require.Equal(0, uut.registry.Len())
 }
 
+func Test_StartTask_Environment(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+
+	// Given a TaskManager instance with a base environment
+	uut := New(WithEnvironment(
+		environment.New(map[string]string{"GREETING": "hello", "NAME": "world"})))
+
+	// When I start a task that overrides part of that environment
+	request := startTask("sh", "-c", "echo $GREETING $NAME")
+	request.Environment["NAME"] = "alice"
+	response, err := uut.StartTask(ctx, request)
+	require.NoError(err)
+
+	// Expect that the task sees both the base and the client-supplied values
+	task := uut.registry.Lookup(response.TaskId.Id)
+	require.NoError(await(task, 1*time.Second))
+	require.Equal("hello alice\n", string(task.Stdout()))
+}
+
+func Test_StartTask_Labels(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+	uut := New()
+
+	// When I start a task with some labels
+	request := startTask("true")
+	request.Labels = map[string]string{"job": "build"}
+	response, err := uut.StartTask(ctx, request)
+	require.NoError(err)
+
+	// Expect the labels to be reported when the task is queried
+	queried, err := uut.QueryTask(ctx, &api.QueryTaskRequest{TaskId: response.TaskId})
+	require.NoError(err)
+	require.Equal(map[string]string{"job": "build"}, queried.Labels)
+
+	// ... and that a label without a name is rejected
+	request = startTask("true")
+	request.Labels = map[string]string{"": "build"}
+	_, err = uut.StartTask(ctx, request)
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func Test_StartTask_TraceContext(t *testing.T) {
+	require := require.New(t)
+
+	// Given a request that is part of an existing trace
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithRemoteSpanContext(
+		user.NewContext(context.Background(), alice),
+		trace.SpanContext{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+
+	// When I start a task
+	uut := New()
+	response, err := uut.StartTask(ctx, startTask("sh", "-c", "echo $TRACEPARENT"))
+	require.NoError(err)
+
+	// Expect that the task is handed the trace context, as a child of the
+	// request's span
+	task := uut.registry.Lookup(response.TaskId.Id)
+	require.NoError(await(task, 1*time.Second))
+	traceparent := strings.TrimSpace(string(task.Stdout()))
+	require.Regexp("^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$", traceparent)
+	require.NotContains(traceparent, spanID.String())
+}
+
+func Test_StartTask_DeniedEnvironment(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+
+	// Given a TaskManager instance with the default environment policy
+	uut := New()
+
+	// When I issue a request that attempts to set LD_PRELOAD
+	request := startTask("true")
+	request.Environment["LD_PRELOAD"] = "/tmp/evil.so"
+	response, err := uut.StartTask(ctx, request)
+
+	// Expect that the request is refused, and nothing was started
+	var denied *environment.DeniedVariable
+	require.True(errors.As(err, &denied))
+	require.Equal(codes.PermissionDenied, status.Code(err))
+	require.Nil(response)
+	require.Equal(0, uut.registry.Len())
+}
+
+// denyAll is a command policy that refuses to run anything
+type denyAll struct{}
+
+func (denyAll) Check(_ *user.User, binary, _ string, _ []string) (string, string, error) {
+	return "", "", errors.New("denied")
+}
+
+func Test_StartTask_CommandDenied(t *testing.T) {
+	require := require.New(t)
+	tempDir := t.TempDir()
+	target := path.Join(tempDir, "target")
+	ctx := user.NewContext(context.Background(), alice)
+
+	// Given a TaskManager instance with a restrictive command policy
+	uut := New(WithCommandPolicy(denyAll{}))
+
+	// When I issue a request to start a task...
+	response, err := uut.StartTask(ctx, startTask("touch", target))
+
+	// Expect that the request is refused
+	require.Error(err)
+	require.Nil(response)
+
+	// ... that nothing was added to the task registry, and that the command
+	// was never run
+	require.Equal(0, uut.registry.Len())
+	_, err = os.Stat(target)
+	require.True(os.IsNotExist(err))
+}
+
+// redirect is a command policy that runs `pwd` in a fixed directory,
+// whatever was asked for
+type redirect struct {
+	dir string
+}
+
+func (r redirect) Check(_ *user.User, _, _ string, _ []string) (string, string, error) {
+	return "pwd", r.dir, nil
+}
+
+func Test_StartTask_UsesCheckedCommand(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+	dir := t.TempDir()
+
+	// Given a TaskManager instance with a command policy that resolves the
+	// binary and working directory to something other than was asked for
+	uut := New(WithCommandPolicy(redirect{dir: dir}))
+
+	// When I start a task
+	root := "/"
+	request := startTask("true")
+	request.WorkingDir = &root
+	response, err := uut.StartTask(ctx, request)
+	require.NoError(err)
+
+	// Expect that the binary and working directory the policy checked are
+	// what is run, rather than the ones in the request
+	task := uut.registry.Lookup(response.TaskId.Id)
+	require.NoError(await(task, 1*time.Second))
+	require.Equal(dir, strings.TrimSpace(string(task.Stdout())))
+}
+
 func Test_QueryTask_Signalled(t *testing.T) {
 	require := require.New(t)
 	ctx := user.NewContext(context.Background(), alice)
@@ -123,6 +296,7 @@ func Test_QueryTask_Signalled(t *testing.T) {
 	require.Equal(status.StatusCode, api.TaskStatusCode_Finished)
 	require.NotNil(status.ExitCode)
 	require.Equal(*status.ExitCode, int32(2))
+	require.Nil(status.Signal)
 }
 
 func Test_QueryTask_NonSignalled(t *testing.T) {
@@ -162,11 +336,13 @@ func Test_QueryTask_NonSignalled(t *testing.T) {
 	require.NoError(await(runningTask, 2*time.Second))
 
 	// ... expect that the status has moved to "finished", with an exit
-	// code of -1.
+	// code of -1, and the signal that killed it.
 	status, err = uut.QueryTask(ctx, &api.QueryTaskRequest{TaskId: taskID})
 	require.NoError(err)
 	require.Equal(status.StatusCode, api.TaskStatusCode_Finished)
 	require.Equal(*status.ExitCode, task.InvalidExitCode)
+	require.NotNil(status.Signal)
+	require.Equal(int32(syscall.SIGTERM), *status.Signal)
 }
 
 func Test_QueryTask_SomeoneElsesTask(t *testing.T) {
@@ -265,6 +441,249 @@ func Test_FetchOutput_SomeoneElsesTask(t *testing.T) {
 	require.Nil(logResponse)
 }
 
+// followStream collects the messages sent on a FollowLogs stream
+type followStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	stdout []byte
+	stderr []byte
+	sent   int
+}
+
+func (s *followStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *followStream) Send(msg *api.FetchLogsResponse) error {
+	s.stdout = append(s.stdout, msg.Stdout...)
+	s.stderr = append(s.stderr, msg.Stderr...)
+	s.sent++
+	return nil
+}
+
+func Test_FollowLogs(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+
+	// Given a server with a task that writes output over a period of time
+	uut := New()
+	startResponse, err := uut.StartTask(ctx, startTask("sh", "-c",
+		"for i in 1 2 3; do echo out $i; 1>&2 echo err $i; sleep 0.1; done"))
+	require.NoError(err)
+
+	// When I follow the task's logs
+	stream := &followStream{ctx: ctx}
+	err = uut.FollowLogs(&api.FollowLogsRequest{TaskId: startResponse.TaskId}, stream)
+
+	// Expect that the stream ends cleanly once the task has exited, having
+	// sent all of its output over several messages
+	require.NoError(err)
+	require.Equal("out 1\nout 2\nout 3\n", string(stream.stdout))
+	require.Equal("err 1\nerr 2\nerr 3\n", string(stream.stderr))
+	require.Greater(stream.sent, 1)
+}
+
+func Test_FollowLogs_ClientGoesAway(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+
+	// Given a server with a task that runs forever
+	uut := New()
+	startResponse, err := uut.StartTask(ctx, startTask("sleep", "3600"))
+	require.NoError(err)
+	defer killTask(uut.registry.Lookup(startResponse.TaskId.Id))
+
+	// When I follow the task's logs, and then give up
+	streamCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	stream := &followStream{ctx: streamCtx}
+	err = uut.FollowLogs(&api.FollowLogsRequest{TaskId: startResponse.TaskId}, stream)
+
+	// Expect that the stream ends
+	require.Equal(context.DeadlineExceeded, err)
+}
+
+func Test_FollowLogs_SomeoneElsesTask(t *testing.T) {
+	require := require.New(t)
+	ctxAlice := user.NewContext(context.Background(), alice)
+	ctxBob := user.NewContext(context.Background(), bob)
+
+	// Given a server with a task started by Alice
+	uut := New()
+	startResponse, err := uut.StartTask(ctxAlice, startTask("sh", "-c", "echo secret"))
+	require.NoError(err)
+
+	// When Bob attempts to follow the output....
+	stream := &followStream{ctx: ctxBob}
+	err = uut.FollowLogs(&api.FollowLogsRequest{TaskId: startResponse.TaskId}, stream)
+
+	// expect the request to fail with a "access denied" error, and that we
+	// didn't leak anything
+	require.IsType(&AccessDenied{}, err)
+	require.Zero(stream.sent)
+}
+
+// allowOnly is an authorisation policy that lets anyone perform a given set
+// of actions on any task, and nothing else
+type allowOnly []task.Action
+
+func (p allowOnly) AllowsStart(u *user.User) bool {
+	return p.Allows(u, task.ActionStart, nil)
+}
+
+func (p allowOnly) Allows(_ *user.User, action task.Action, _ *task.Task) bool {
+	for _, a := range p {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_AuthorisationPolicy_CheckedPerAction(t *testing.T) {
+	require := require.New(t)
+	ctxAlice := user.NewContext(context.Background(), alice)
+	ctxBob := user.NewContext(context.Background(), bob)
+
+	// Given a server with a policy that lets anyone start tasks and read
+	// logs, but nothing else, and a task started by Alice
+	uut := New(WithAuthorisationPolicy(
+		allowOnly{task.ActionStart, task.ActionLogs}))
+	startResponse, err := uut.StartTask(ctxAlice, startTask("exit-with-two"))
+	require.NoError(err)
+	taskID := startResponse.TaskId
+	defer killTask(uut.registry.Lookup(taskID.Id))
+
+	// Expect that Bob may fetch the task's logs...
+	_, err = uut.FetchLogs(ctxBob, &api.FetchLogsRequest{TaskId: taskID})
+	require.NoError(err)
+
+	// ... but may neither query nor signal it, and that the errors
+	// name the action that was denied
+	_, err = uut.QueryTask(ctxBob, &api.QueryTaskRequest{TaskId: taskID})
+	require.IsType(&AccessDenied{}, err)
+	require.Equal(task.ActionQuery, err.(*AccessDenied).action)
+	require.Contains(err.Error(), "query")
+
+	_, err = uut.SignalTask(ctxBob, &api.SignalTaskRequest{TaskId: taskID})
+	require.IsType(&AccessDenied{}, err)
+	require.Equal(task.ActionSignal, err.(*AccessDenied).action)
+	require.Contains(err.Error(), "signal")
+}
+
+func Test_StartTask_AccessDenied(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+
+	// Given a server with a policy that doesn't allow anyone to start tasks
+	uut := New(WithAuthorisationPolicy(allowOnly{task.ActionQuery}))
+
+	// When Alice tries to start a task
+	response, err := uut.StartTask(ctx, startTask("true"))
+
+	// Expect the request to be refused, naming the start action
+	require.IsType(&AccessDenied{}, err)
+	require.Equal(task.ActionStart, err.(*AccessDenied).action)
+	require.Nil(response)
+	require.Equal(0, uut.registry.Len())
+}
+
+func Test_StartTask_AccessDeniedFirst(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+
+	// Given a server that doesn't allow anyone to start tasks, and with
+	// command and environment policies that would refuse the request too
+	uut := New(
+		WithAuthorisationPolicy(allowOnly{task.ActionQuery}),
+		WithCommandPolicy(denyAll{}))
+
+	// When Alice tries to start a task that neither policy would allow
+	request := startTask("true")
+	request.Environment["LD_PRELOAD"] = "/tmp/evil.so"
+	_, err := uut.StartTask(ctx, request)
+
+	// Expect the request to be refused because she may not start tasks,
+	// without revealing what the other policies make of it
+	require.IsType(&AccessDenied{}, err)
+	require.Equal(task.ActionStart, err.(*AccessDenied).action)
+}
+
+func Test_ShareTask(t *testing.T) {
+	require := require.New(t)
+	ctxAlice := user.NewContext(context.Background(), alice)
+	ctxBob := user.NewContext(context.Background(), bob)
+
+	// Given a task started by Alice...
+	uut := New()
+	startResponse, err := uut.StartTask(ctxAlice, startTask("exit-with-two"))
+	require.NoError(err)
+	taskID := startResponse.TaskId
+	defer killTask(uut.registry.Lookup(taskID.Id))
+
+	// When Alice shares read access to the task with Bob
+	_, err = uut.ShareTask(ctxAlice, &api.ShareTaskRequest{
+		TaskId:  taskID,
+		Users:   []string{"bob"},
+		Actions: []api.TaskAction{api.TaskAction_Query, api.TaskAction_Logs},
+	})
+	require.NoError(err)
+
+	// Expect that Bob can now query the task and fetch its logs...
+	_, err = uut.QueryTask(ctxBob, &api.QueryTaskRequest{TaskId: taskID})
+	require.NoError(err)
+	_, err = uut.FetchLogs(ctxBob, &api.FetchLogsRequest{TaskId: taskID})
+	require.NoError(err)
+
+	// ... but can neither signal it, nor share it any further
+	_, err = uut.SignalTask(ctxBob, &api.SignalTaskRequest{TaskId: taskID})
+	require.IsType(&AccessDenied{}, err)
+
+	_, err = uut.ShareTask(ctxBob, &api.ShareTaskRequest{
+		TaskId:  taskID,
+		Users:   []string{"chuck"},
+		Actions: []api.TaskAction{api.TaskAction_Query},
+	})
+	require.IsType(&AccessDenied{}, err)
+}
+
+func Test_ShareTask_NothingToShare(t *testing.T) {
+	require := require.New(t)
+	ctx := user.NewContext(context.Background(), alice)
+
+	uut := New()
+	startResponse, err := uut.StartTask(ctx, startTask("exit-with-two"))
+	require.NoError(err)
+	taskID := startResponse.TaskId
+	defer killTask(uut.registry.Lookup(taskID.Id))
+
+	// A request without any users or groups is an error
+	_, err = uut.ShareTask(ctx, &api.ShareTaskRequest{
+		TaskId:  taskID,
+		Actions: []api.TaskAction{api.TaskAction_Query},
+	})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	// ... as is one without any actions
+	_, err = uut.ShareTask(ctx, &api.ShareTaskRequest{
+		TaskId: taskID,
+		Users:  []string{"bob"},
+	})
+	require.Equal(codes.InvalidArgument, status.Code(err))
+
+	// ... or with an action that was left unspecified, or that the server
+	// doesn't know
+	for _, action := range []api.TaskAction{api.TaskAction_TASK_ACTION_UNSPECIFIED, api.TaskAction(42)} {
+		_, err = uut.ShareTask(ctx, &api.ShareTaskRequest{
+			TaskId:  taskID,
+			Users:   []string{"bob"},
+			Actions: []api.TaskAction{action},
+		})
+		require.Equal(codes.InvalidArgument, status.Code(err))
+	}
+	require.False(uut.registry.Lookup(taskID.Id).IsSharedWith("bob", nil, task.ActionQuery))
+}
+
 func Test_FetchOutput_NonExistantTask(t *testing.T) {
 	ctx := user.NewContext(context.Background(), alice)
 	require := require.New(t)
@@ -419,3 +838,97 @@ func killTask(t *task.Task) {
 	_ = t.Signal(ctx)
 	cancel()
 }
+
+func Test_Unauthenticated(t *testing.T) {
+	require := require.New(t)
+	uut := New()
+	ctx := context.Background()
+	taskID := &api.TaskHandle{Id: "some-task"}
+
+	// Given a request context without an authenticated user, expect that
+	// every request is rejected rather than panicking
+	_, err := uut.StartTask(ctx, startTask("true"))
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	_, err = uut.QueryTask(ctx, &api.QueryTaskRequest{TaskId: taskID})
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	_, err = uut.FetchLogs(ctx, &api.FetchLogsRequest{TaskId: taskID})
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	_, err = uut.SignalTask(ctx, &api.SignalTaskRequest{TaskId: taskID})
+	require.Equal(codes.Unauthenticated, status.Code(err))
+
+	_, err = uut.ShareTask(ctx, &api.ShareTaskRequest{TaskId: taskID})
+	require.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+// recordingObserver captures the task lifecycle notifications it receives
+type recordingObserver struct {
+	started  chan *task.Task
+	finished chan time.Duration
+}
+
+func (o *recordingObserver) TaskStarted(t *task.Task) {
+	o.started <- t
+}
+
+func (o *recordingObserver) TaskFinished(t *task.Task, runtime time.Duration) {
+	o.finished <- runtime
+}
+
+func Test_StartTask_NotifiesObservers(t *testing.T) {
+	require := require.New(t)
+	observer := &recordingObserver{
+		started:  make(chan *task.Task, 1),
+		finished: make(chan time.Duration, 1),
+	}
+
+	// Given a TaskManager with an observer attached
+	uut := New(WithObserver(observer))
+	ctx := user.NewContext(context.Background(), alice)
+
+	// When I start a task
+	response, err := uut.StartTask(ctx, startTask("sleep", "0.1"))
+	require.NoError(err)
+
+	// Expect that the observer is told it has started...
+	tsk := uut.registry.Lookup(response.TaskId.Id)
+	require.Same(tsk, <-observer.started)
+
+	// ... and, eventually, that it has finished
+	select {
+	case runtime := <-observer.finished:
+		require.GreaterOrEqual(int64(runtime), int64(100*time.Millisecond))
+	case <-time.After(5 * time.Second):
+		require.Fail("Timed out waiting for task to finish")
+	}
+
+	// Expect also that a task that fails to start is not observed
+	_, err = uut.StartTask(ctx, startTask("/no-such-binary"))
+	require.Error(err)
+	require.Len(observer.started, 0)
+}
+
+func Test_Check(t *testing.T) {
+	require := require.New(t)
+	uut := New()
+	uut.registry.Register(task.New(alice, "true", ".", nil))
+
+	// Expect that a server with a responsive registry is healthy...
+	require.NoError(uut.Check(context.Background()))
+
+	// ... but that one whose registry is stuck is not. A writer waiting on
+	// the registry lock blocks any new readers (including the check) until
+	// the current reader (the `Range` call) finishes.
+	var err error
+	uut.registry.Range(func(string, *task.Task) {
+		go uut.registry.Register(task.New(alice, "true", ".", nil))
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err = uut.Check(ctx)
+	})
+	require.Error(err)
+}
@@ -0,0 +1,114 @@
+package taskmanager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/task"
+)
+
+// signalGracePeriod is how long a signalled task has to exit by itself
+// before it is killed outright
+const signalGracePeriod = 5 * time.Second
+
+// ShutdownPolicy decides what happens to running tasks when the server
+// shuts down
+type ShutdownPolicy string
+
+const (
+	// ShutdownSignal signals every running task, as if its owner had asked
+	// it to stop, and waits for them all to exit
+	ShutdownSignal ShutdownPolicy = "signal"
+
+	// ShutdownDetach leaves running tasks to carry on without the server.
+	// Their output is discarded once the server has exited, and they can't
+	// be managed through levity any more.
+	ShutdownDetach ShutdownPolicy = "detach"
+
+	// ShutdownWait waits for every running task to exit by itself, however
+	// long that takes
+	ShutdownWait ShutdownPolicy = "wait"
+)
+
+// ShutdownPolicies lists the valid shutdown policies
+var ShutdownPolicies = []ShutdownPolicy{ShutdownSignal, ShutdownDetach, ShutdownWait}
+
+// ParseShutdownPolicy validates the name of a shutdown policy
+func ParseShutdownPolicy(name string) (ShutdownPolicy, error) {
+	names := make([]string, 0, len(ShutdownPolicies))
+	for _, p := range ShutdownPolicies {
+		if string(p) == name {
+			return p, nil
+		}
+		names = append(names, string(p))
+	}
+	return "", fmt.Errorf("Unknown shutdown policy %q (expected one of %s)",
+		name, strings.Join(names, ", "))
+}
+
+// Shutdown stops the server from starting any new tasks, and then applies
+// the shutdown policy to any tasks that are still running, blocking until
+// the policy has been carried out or the context expires. The server still
+// answers requests about existing tasks in the meantime.
+func (server *Server) Shutdown(ctx context.Context, policy ShutdownPolicy) error {
+	server.lifecycle.Lock()
+	server.draining = true
+	server.lifecycle.Unlock()
+
+	running := server.runningTasks()
+	log.Printf("Shutting down with %d running task(s), policy %q", len(running), policy)
+
+	switch policy {
+	case ShutdownDetach:
+		failed := 0
+		for id, t := range running {
+			if err := t.Detach(); err != nil {
+				log.Printf("Failed to detach task %s: %v", id, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("Failed to detach %d task(s)", failed)
+		}
+		return nil
+
+	case ShutdownSignal:
+		for id, t := range running {
+			if err := signalTask(ctx, id, t); err != nil {
+				log.Printf("Failed to signal task %s: %v", id, err)
+			}
+		}
+
+	case ShutdownWait:
+
+	default:
+		return fmt.Errorf("Unknown shutdown policy %q", policy)
+	}
+
+	for id, t := range running {
+		select {
+		case <-t.Done():
+		case <-ctx.Done():
+			return fmt.Errorf("Gave up waiting for task %s: %w", id, ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// runningTasks lists the tasks in the registry that are still running
+// (including any that have been signalled but not yet exited)
+func (server *Server) runningTasks() map[string]*task.Task {
+	running := make(map[string]*task.Task)
+	server.registry.Range(func(id string, t *task.Task) {
+		switch status, _ := t.Status(); status {
+		case api.TaskStatusCode_Running, api.TaskStatusCode_Signalled:
+			running[id] = t
+		}
+	})
+	return running
+}
@@ -0,0 +1,109 @@
+package taskmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tcsc/levity/api"
+	"github.com/tcsc/levity/task"
+	"github.com/tcsc/levity/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startSleeper starts a long-running task, returning it for inspection
+func startSleeper(t *testing.T, uut *Server, args ...string) *task.Task {
+	ctx := user.NewContext(context.Background(), alice)
+	response, err := uut.StartTask(ctx, startTask("sleep", args...))
+	require.NoError(t, err)
+	return uut.registry.Lookup(response.TaskId.Id)
+}
+
+func Test_ParseShutdownPolicy(t *testing.T) {
+	require := require.New(t)
+	for _, p := range ShutdownPolicies {
+		parsed, err := ParseShutdownPolicy(string(p))
+		require.NoError(err)
+		require.Equal(p, parsed)
+	}
+
+	_, err := ParseShutdownPolicy("explode")
+	require.Error(err)
+}
+
+func Test_Shutdown_RejectsNewTasks(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server that has been shut down
+	uut := New()
+	require.NoError(uut.Shutdown(context.Background(), ShutdownSignal))
+
+	// When I try to start a task
+	ctx := user.NewContext(context.Background(), alice)
+	_, err := uut.StartTask(ctx, startTask("true"))
+
+	// Expect that it is refused as unavailable, and not started
+	require.Equal(codes.Unavailable, status.Code(err))
+	require.IsType(&ShuttingDown{}, err)
+	require.Equal(0, uut.registry.Len())
+}
+
+func Test_Shutdown_Signal(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server with a running task
+	uut := New()
+	tsk := startSleeper(t, uut, "30")
+
+	// When the server is shut down with the signal policy
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(uut.Shutdown(ctx, ShutdownSignal))
+
+	// Expect that the task was signalled, and has exited
+	require.NoError(await(tsk, 1*time.Second))
+	statusCode, exitCode := tsk.Status()
+	require.Equal(api.TaskStatusCode_Finished, statusCode)
+	require.Equal(-1, exitCode, "Task should have been terminated by a signal")
+}
+
+func Test_Shutdown_Detach(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server with a running task
+	uut := New()
+	tsk := startSleeper(t, uut, "30")
+	defer tsk.Signal(context.Background())
+
+	// When the server is shut down with the detach policy
+	require.NoError(uut.Shutdown(context.Background(), ShutdownDetach))
+
+	// Expect that the task is left running
+	statusCode, _ := tsk.Status()
+	require.Equal(api.TaskStatusCode_Running, statusCode)
+}
+
+func Test_Shutdown_Wait(t *testing.T) {
+	require := require.New(t)
+
+	// Given a server with a short-lived task and a long-lived one
+	uut := New()
+	short := startSleeper(t, uut, "0.1")
+	long := startSleeper(t, uut, "30")
+	defer long.Signal(context.Background())
+
+	// When the server is shut down with the wait policy, but I give up
+	// before the long-lived task finishes
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	err := uut.Shutdown(ctx, ShutdownWait)
+
+	// Expect that the shutdown is abandoned, having waited for the short
+	// task to finish, but without touching the long-lived one
+	require.Error(err)
+	require.NoError(await(short, 1*time.Second))
+	statusCode, _ := long.Status()
+	require.Equal(api.TaskStatusCode_Running, statusCode)
+}
@@ -0,0 +1,154 @@
+// Package tracing configures OpenTelemetry tracing for the levity client and
+// daemon, and carries trace context across the boundaries that GRPC doesn't
+// handle for us: from a CI pipeline into the client, and from the daemon
+// into the tasks it runs.
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagation"
+	sdkexport "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by levity itself, as
+// opposed to those created by the GRPC instrumentation
+const instrumentationName = "github.com/tcsc/levity"
+
+// Config describes where spans are exported to. Spans are only exported if
+// an OTLP endpoint or a file is given, but trace context is propagated (and
+// new trace and span IDs allocated) regardless, so that a task can always be
+// correlated with whatever trace its client was part of.
+type Config struct {
+	// ServiceName is reported as the `service.name` of every span
+	ServiceName string
+
+	// OTLPEndpoint is the `host:port` of an OTLP/GRPC collector
+	OTLPEndpoint string
+
+	// OTLPInsecure disables TLS when talking to the collector
+	OTLPInsecure bool
+
+	// File is the name of a file that spans are appended to as JSON, one
+	// batch per line
+	File string
+}
+
+// Init installs a global tracer provider and W3C trace context propagator
+// as described by the config. The returned function flushes any buffered
+// spans and shuts the exporters down, and should be called before the
+// process exits.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.ServiceNameKey.String(cfg.ServiceName))),
+	}
+
+	var exporters []sdkexport.SpanExporter
+	var files []*os.File
+
+	if cfg.OTLPEndpoint != "" {
+		driverOptions := []otlpgrpc.Option{otlpgrpc.WithEndpoint(cfg.OTLPEndpoint)}
+		if cfg.OTLPInsecure {
+			driverOptions = append(driverOptions, otlpgrpc.WithInsecure())
+		}
+
+		exporter, err := otlp.NewExporter(ctx, otlpgrpc.NewDriver(driverOptions...))
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+
+	if cfg.File != "" {
+		f, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+
+		exporter, err := stdout.NewExporter(stdout.WithWriter(f), stdout.WithoutMetricExport())
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+
+	for _, exporter := range exporters {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	shutdown := func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		for _, f := range files {
+			f.Close()
+		}
+		return err
+	}
+
+	return shutdown, nil
+}
+
+// Tracer creates the tracer for levity's own spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// environmentCarrier adapts a set of environment variables to the carrier
+// interface used by propagators. The W3C header names are upper-cased to
+// match the usual convention for environment variables (e.g. `TRACEPARENT`).
+type environmentCarrier map[string]string
+
+func (c environmentCarrier) Get(key string) string {
+	return c[strings.ToUpper(key)]
+}
+
+func (c environmentCarrier) Set(key string, value string) {
+	c[strings.ToUpper(key)] = value
+}
+
+// Environment describes the trace context in `ctx` as environment variables
+// (`TRACEPARENT` and, if there is any trace state, `TRACESTATE`), suitable
+// for passing on to a child process. Returns an empty map if `ctx` has no
+// valid span context.
+func Environment(ctx context.Context) map[string]string {
+	env := environmentCarrier{}
+	propagation.TraceContext{}.Inject(ctx, env)
+
+	// The propagator always sets the trace state, even when it's empty
+	for k, v := range env {
+		if v == "" {
+			delete(env, k)
+		}
+	}
+	return env
+}
+
+// FromEnvironment extracts any trace context passed to this process in its
+// environment (e.g. by a CI system that sets `TRACEPARENT`), so that the
+// process's spans become part of the same trace.
+func FromEnvironment(ctx context.Context) context.Context {
+	env := environmentCarrier{}
+	for _, name := range []string{"TRACEPARENT", "TRACESTATE"} {
+		if value, ok := os.LookupEnv(name); ok {
+			env[name] = value
+		}
+	}
+	return propagation.TraceContext{}.Extract(ctx, env)
+}
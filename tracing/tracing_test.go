@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestEnvironment_PropagatesTrace(t *testing.T) {
+	require := require.New(t)
+	_, err := Init(context.Background(), Config{ServiceName: "test"})
+	require.NoError(err)
+
+	// Given a process started with a trace context in its environment
+	require.NoError(os.Setenv("TRACEPARENT", traceparent))
+	defer os.Unsetenv("TRACEPARENT")
+
+	// When I create a span under it, and describe that span as an
+	// environment for a child process
+	ctx, span := Tracer().Start(FromEnvironment(context.Background()), "test-span")
+	defer span.End()
+	env := Environment(ctx)
+
+	// Expect that the child is told about the new span, in the same trace
+	require.Len(env, 1)
+	require.Equal(
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.SpanContext().SpanID.String()+"-01",
+		env["TRACEPARENT"])
+}
+
+func TestEnvironment_NoSpan(t *testing.T) {
+	require.Empty(t, Environment(context.Background()))
+}
+
+func TestInit_WritesSpansToFile(t *testing.T) {
+	require := require.New(t)
+	filename := path.Join(t.TempDir(), "spans.json")
+
+	// Given tracing configured to write spans to a file
+	shutdown, err := Init(context.Background(), Config{ServiceName: "test", File: filename})
+	require.NoError(err)
+
+	// When I create a span as part of an existing trace, and shut down
+	require.NoError(os.Setenv("TRACEPARENT", traceparent))
+	defer os.Unsetenv("TRACEPARENT")
+	ctx, span := Tracer().Start(FromEnvironment(context.Background()), "test-span")
+	require.Equal("4bf92f3577b34da6a3ce929d0e0e4736", trace.SpanFromContext(ctx).SpanContext().TraceID.String())
+	span.End()
+	require.NoError(shutdown(context.Background()))
+
+	// Expect that the span was written to the file, in the original trace
+	content, err := ioutil.ReadFile(filename)
+	require.NoError(err)
+	require.Contains(string(content), `"Name":"test-span"`)
+	require.Contains(string(content), "4bf92f3577b34da6a3ce929d0e0e4736")
+}
@@ -2,13 +2,15 @@ package user
 
 import "context"
 
-// User encapsulates an authenticated user in the system. For the purposes
-// of this example it is simply the user's login name, but it's pretty easy
-// to imagine it being expanded to include roles, permissions, etc.
+// User encapsulates an authenticated user in the system: the user's login
+// name, plus any groups and roles asserted by the credentials they presented
+// when authenticating.
 //
 // Once created, a `User` is immutable (hence no locking)
 type User struct {
-	login string
+	login  string
+	groups []string
+	roles  []string
 }
 
 // Login fetches the user's login name
@@ -16,6 +18,18 @@ func (user *User) Login() string {
 	return user.login
 }
 
+// Groups fetches the names of the groups that the user's credentials claim
+// they belong to
+func (user *User) Groups() []string {
+	return append([]string{}, user.groups...)
+}
+
+// Roles fetches the names of the roles that the user's credentials claim
+// they hold
+func (user *User) Roles() []string {
+	return append([]string{}, user.roles...)
+}
+
 // Is tests if two user objects refer to the same underlying user.
 func (user *User) Is(other *User) bool {
 	// if they are literally the same object, then yep, they do
@@ -26,11 +40,22 @@ func (user *User) Is(other *User) bool {
 	return user.login == other.login
 }
 
-// New initialises a user record with
+// New initialises a user record with the given login name, and no group or
+// role claims
 func New(login string) *User {
 	return &User{login: login}
 }
 
+// NewWithClaims initialises a user record with the given login name, group
+// memberships and roles
+func NewWithClaims(login string, groups []string, roles []string) *User {
+	return &User{
+		login:  login,
+		groups: append([]string{}, groups...),
+		roles:  append([]string{}, roles...),
+	}
+}
+
 type userKey struct{}
 
 // NewContext creates a context with a user record attached.
@@ -43,12 +68,3 @@ func FromContext(ctx context.Context) (u *User, ok bool) {
 	u, ok = ctx.Value(userKey{}).(*User)
 	return
 }
-
-// MustFromContext retrieves a user record from the supplied context.
-func MustFromContext(ctx context.Context) *User {
-	u, ok := FromContext(ctx)
-	if !ok {
-		panic("No user record found in context")
-	}
-	return u
-}